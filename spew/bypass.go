@@ -150,3 +150,17 @@ func unsafeReflectValue(v reflect.Value) (rv reflect.Value) {
 	}
 	return rv
 }
+
+// rawBytes returns the raw memory representation of the value the passed
+// pointer points to.  It works by creating a byte slice over the memory
+// occupied by the value which is the size of its type.
+func rawBytes(v reflect.Value) ([]byte, bool) {
+	size := v.Type().Elem().Size()
+	if size == 0 {
+		return []byte{}, true
+	}
+	raw := unsafe.Slice((*byte)(unsafe.Pointer(v.Pointer())), size)
+	buf := make([]byte, size)
+	copy(buf, raw)
+	return buf, true
+}
@@ -36,3 +36,11 @@ const (
 func unsafeReflectValue(v reflect.Value) reflect.Value {
 	return v
 }
+
+// rawBytes typically returns the raw memory representation of the value the
+// passed pointer points to.  However, doing this relies on access to the unsafe
+// package.  This is a stub version which always reports the bytes are not
+// available.
+func rawBytes(v reflect.Value) ([]byte, bool) {
+	return nil, false
+}
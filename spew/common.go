@@ -18,11 +18,20 @@ package spew
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"fmt"
+	"hash/fnv"
 	"io"
+	"math"
+	"path/filepath"
 	"reflect"
+	"runtime"
 	"sort"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 )
 
 // Some constants in the form of bytes to avoid string overhead.  This mirrors
@@ -34,6 +43,7 @@ var (
 	trueBytes             = []byte("true")
 	falseBytes            = []byte("false")
 	interfaceBytes        = []byte("(interface {})")
+	commaBytes            = []byte(",")
 	commaNewlineBytes     = []byte(",\n")
 	newlineBytes          = []byte("\n")
 	openBraceBytes        = []byte("{")
@@ -47,11 +57,24 @@ var (
 	spaceBytes            = []byte(" ")
 	pointerChainBytes     = []byte("->")
 	nilAngleBytes         = []byte("<nil>")
-	maxNewlineBytes       = []byte("<max depth reached>\n")
-	maxShortBytes         = []byte("<max>")
+	nilBytes              = []byte("nil")
+	nullAngleBytes        = []byte("<null>")
+	collectedAngleBytes   = []byte("<collected>")
+	nilValueAngleBytes    = []byte("<nil value>")
+	openHashBytes         = []byte("[hash=")
+	docCommentBytes       = []byte(" // ")
+	seenLabelBytes        = []byte("<seen #")
+	hashBytes             = []byte("#")
+	zeroBytes             = []byte("0")
+	binaryPrefixBytes     = []byte("0b")
+	corruptSliceBytes     = []byte("<corrupt slice>")
+	elemEqualsBytes       = []byte("elem=")
+	valuerBytes           = []byte("(valuer)")
+	maxDepthBytes         = []byte("<max depth reached>")
 	circularBytes         = []byte("<already shown>")
 	circularShortBytes    = []byte("<shown>")
 	invalidAngleBytes     = []byte("<invalid>")
+	omittedAngleBytes     = []byte("<omitted>")
 	openBracketBytes      = []byte("[")
 	closeBracketBytes     = []byte("]")
 	percentBytes          = []byte("%")
@@ -62,8 +85,52 @@ var (
 	closeMapBytes         = []byte("]")
 	lenEqualsBytes        = []byte("len=")
 	capEqualsBytes        = []byte("cap=")
+	sizeEqualsBytes       = []byte("size=")
+	rawUnavailableBytes   = []byte("<raw bytes unavailable>")
+	notPointerBytes       = []byte("<not a pointer>")
+	embeddedBytes         = []byte("embedded")
+	tooManyCyclesBytes    = []byte("... (too many cycles)")
+	sharesBackingBytes    = []byte("[shares backing with #")
+	ellipsisBytes         = []byte("...")
+	moreBytes             = []byte(" more)")
+	base64Bytes           = []byte("(base64)")
+	hexBytes              = []byte("(hex)")
+	legendBytes           = []byte("Legend:\n")
+	runeSliceBytes        = []byte("rune as string")
+	argLabelBytes         = []byte("arg[")
+	closeArgLabelBytes    = []byte("]:\n")
+	notRestoredBytes      = []byte(" not restored>")
+	groupCommentBytes     = []byte("// ")
+	schemaLenBytes        = []byte(" (len ")
+	negativeZeroBytes     = []byte("-0.0")
+	nanOpenBytes          = []byte("NaN(")
+	subnormalBytes        = []byte(" (subnormal)")
+	dumpAbortedBytes      = []byte("<dump aborted: ")
+	timesBytes            = []byte("×")
+	asciiTimesBytes       = []byte("x")
+	equalsBytes           = []byte("=")
+	dynamicTypeBytes      = []byte("-- dynamic type ")
+	dynamicTypeCloseBytes = []byte(" --\n")
+	openCommentBytes      = []byte("/* via ")
+	closeCommentBytes     = []byte(" */ ")
 )
 
+// runeType is a reflect.Type representing a rune.  Since rune is an alias for
+// int32, it is the same as the predeclared int32 type.
+var runeType = reflect.TypeOf(rune(0))
+
+// reflectValueType is a reflect.Type representing a reflect.Value.  It is used
+// to detect values of type reflect.Value so the value they hold is displayed.
+var reflectValueType = reflect.TypeOf(reflect.Value{})
+
+// durationType is a reflect.Type representing a time.Duration.  It is used to
+// detect durations so they can be displayed in human readable form.
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// timeType is a reflect.Type representing a time.Time.  It is used to display
+// times according to the TimeLayout option.
+var timeType = reflect.TypeOf(time.Time{})
+
 // hexDigits is used to map a decimal value to a hex digit.
 var hexDigits = "0123456789abcdef"
 
@@ -77,18 +144,119 @@ func catchPanic(w io.Writer, v reflect.Value) {
 	}
 }
 
+// dumpAbort is used to unwind an in-progress dump or format operation via
+// panic when it can't sensibly continue.  The marker is written in place of
+// the remaining output.
+type dumpAbort struct {
+	marker []byte
+}
+
+// recoverAbort handles a dumpAbort panic by outputting its marker to Writer w.
+// Any other panics are propagated.
+func recoverAbort(w io.Writer) {
+	if err := recover(); err != nil {
+		abort, ok := err.(dumpAbort)
+		if !ok {
+			panic(err)
+		}
+		w.Write(abort.marker)
+	}
+}
+
+// trackPointer records the passed address as dereferenced at the passed depth
+// in the passed map used to detect circular references unless the map already
+// holds as many addresses as the PointerTrackLimit option allows.  Addresses
+// which are already tracked are updated regardless of the limit.
+func trackPointer(cs *ConfigState, pointers map[uintptr]int, addr uintptr, depth int) {
+	if _, ok := pointers[addr]; !ok && cs.PointerTrackLimit > 0 &&
+		len(pointers) >= cs.PointerTrackLimit {
+
+		return
+	}
+	pointers[addr] = depth
+}
+
+// printCycleMarker outputs the marker of a circular reference to Writer w
+// according to the CycleMarker and CycleMarkerFunc options, or the passed
+// default marker when neither provides one.  The label is the position of the
+// circular reference among those encountered so far, starting at 1.
+func printCycleMarker(cs *ConfigState, w io.Writer, label int, defaultMarker []byte) {
+	if cs.CycleMarkerFunc != nil {
+		if marker := cs.CycleMarkerFunc(label); marker != "" {
+			io.WriteString(w, marker)
+			return
+		}
+	}
+	if cs.CycleMarker != "" {
+		io.WriteString(w, cs.CycleMarker)
+		return
+	}
+	w.Write(defaultMarker)
+}
+
+// checkCycles tracks the number of circular references encountered so far and
+// aborts the current operation once it exceeds the MaxCycleRevisits option.
+func checkCycles(cs *ConfigState, cycles *int) {
+	*cycles++
+	if cs.MaxCycleRevisits != 0 && *cycles > cs.MaxCycleRevisits {
+		panic(dumpAbort{tooManyCyclesBytes})
+	}
+}
+
+// methodCacheKey identifies the value an error or String method was called on
+// by its pointer type and address.
+type methodCacheKey struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+// methodCache holds the results of the error and String methods called during
+// a single dump according to the CacheStringerResults option.
+type methodCache map[methodCacheKey]string
+
+// newMethodCache returns the cache for the results of the error and String
+// methods called during a single dump, which is nil unless the
+// CacheStringerResults option is enabled.
+func newMethodCache(cs *ConfigState) methodCache {
+	if !cs.CacheStringerResults {
+		return nil
+	}
+	return make(methodCache)
+}
+
+// callMethod returns the result of the passed error or String method of the
+// passed value.  When a cache is provided and the value is a non-nil pointer,
+// the result is only computed the first time the method is called for the
+// pointer and taken from the cache afterwards.
+func callMethod(cache methodCache, v reflect.Value, method func() string) string {
+	if cache == nil || v.Kind() != reflect.Ptr || v.IsNil() {
+		return method()
+	}
+	key := methodCacheKey{v.Type(), v.Pointer()}
+	if s, ok := cache[key]; ok {
+		return s
+	}
+	s := method()
+	cache[key] = s
+	return s
+}
+
 // handleMethods attempts to call the Error and String methods on the underlying
 // type the passed reflect.Value represents and outputes the result to Writer w.
+// The results are memoized in the passed cache, if any.
 //
 // It handles panics in any called methods by catching and displaying the error
 // as the formatted value.
-func handleMethods(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool) {
+func handleMethods(cs *ConfigState, w io.Writer, v reflect.Value, cache methodCache) (handled bool) {
 	// We need an interface to check if the type implements the error or
 	// Stringer interface.  However, the reflect package won't give us an
 	// interface on certain things like unexported struct fields in order
 	// to enforce visibility rules.  We use unsafe, when it's available,
 	// to bypass these restrictions since this package does not mutate the
-	// values.
+	// values.  Bypassing them makes the value addressable, so remember
+	// whether it was in order for values recovered from unexported fields
+	// to be treated exactly the same as exported ones.
+	addressable := v.CanAddr()
 	if !v.CanInterface() {
 		if UnsafeDisabled {
 			return false
@@ -106,7 +274,7 @@ func handleMethods(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool)
 	if !cs.DisablePointerMethods && !UnsafeDisabled && !v.CanAddr() {
 		v = unsafeReflectValue(v)
 	}
-	if v.CanAddr() {
+	if v.CanAddr() && (addressable || !cs.DisablePointerMethods) {
 		v = v.Addr()
 	}
 
@@ -114,32 +282,823 @@ func handleMethods(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool)
 	switch iface := v.Interface().(type) {
 	case error:
 		defer catchPanic(w, v)
+		printVia(cs, w, "error")
+		s := callMethod(cache, v, iface.Error)
+		if cs.ShowBothMethodAndInternals {
+			printMethodAndContinue(cs, w, s)
+			return false
+		}
 		if cs.ContinueOnMethod {
 			w.Write(openParenBytes)
-			w.Write([]byte(iface.Error()))
+			printText(cs, w, s, false)
 			w.Write(closeParenBytes)
 			w.Write(spaceBytes)
 			return false
 		}
 
-		w.Write([]byte(iface.Error()))
+		printText(cs, w, s, false)
 		return true
 
 	case fmt.Stringer:
 		defer catchPanic(w, v)
+		printVia(cs, w, "Stringer")
+		s := callMethod(cache, v, iface.String)
+		if cs.ShowBothMethodAndInternals {
+			printMethodAndContinue(cs, w, s)
+			return false
+		}
 		if cs.ContinueOnMethod {
 			w.Write(openParenBytes)
-			w.Write([]byte(iface.String()))
+			printText(cs, w, s, false)
 			w.Write(closeParenBytes)
 			w.Write(spaceBytes)
 			return false
 		}
-		w.Write([]byte(iface.String()))
+		printText(cs, w, s, false)
+		return true
+	}
+	return false
+}
+
+// isCorruptSlice returns whether the passed value is a slice whose length and
+// capacity are inconsistent, which can only happen when its header has been
+// corrupted, so its elements can't be safely accessed.
+func isCorruptSlice(v reflect.Value) bool {
+	return v.Kind() == reflect.Slice && (v.Len() < 0 || v.Cap() < v.Len())
+}
+
+// sliceElem returns the element with the passed index of the passed array or
+// slice along with whether it could be accessed.  Only a panic raised by the
+// indexing itself is recovered, which can only happen when the length checked
+// before iterating no longer holds because the value is in an invalid state.
+func sliceElem(v reflect.Value, i int) (elem reflect.Value, ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			checkIndexPanic(r)
+			elem, ok = reflect.Value{}, false
+		}
+	}()
+	return v.Index(i), true
+}
+
+// checkIndexPanic propagates the passed recovered panic unless it was raised
+// by indexing an array or slice out of range, which can only happen when the
+// value is in an invalid state.
+func checkIndexPanic(r interface{}) {
+	msg, ok := r.(string)
+	if err, isErr := r.(runtime.Error); isErr {
+		msg, ok = err.Error(), true
+	}
+	if !ok || !strings.Contains(msg, "index out of range") {
+		panic(r)
+	}
+}
+
+// printMethodAndContinue outputs the passed error or Stringer output quoted and
+// followed by a space to Writer w so the internals of the value can follow it
+// according to the ShowBothMethodAndInternals option.
+func printMethodAndContinue(cs *ConfigState, w io.Writer, s string) {
+	printText(cs, w, s, true)
+	w.Write(spaceBytes)
+}
+
+// methodConfig returns the configuration used to invoke the error and Stringer
+// methods of the passed value at the passed depth.  At the depth boundary of
+// the MaxDepth option, the StringerAtMaxDepth option displays containers via
+// their methods alone since their contents would be cut off anyway.
+func methodConfig(cs *ConfigState, v reflect.Value, depth int) *ConfigState {
+	if !cs.StringerAtMaxDepth || cs.MaxDepth == 0 || depth+1 <= cs.MaxDepth ||
+		(!cs.ContinueOnMethod && !cs.ShowBothMethodAndInternals) {
+
+		return cs
+	}
+	switch v.Kind() {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+		mcs := *cs
+		mcs.ContinueOnMethod = false
+		mcs.ShowBothMethodAndInternals = false
+		return &mcs
+	}
+	return cs
+}
+
+// handleKind displays the passed value via the handler for its kind set by the
+// KindHandlers option, if any, and returns whether it did.  Any panic raised
+// by the handler is caught and displayed in its place.
+func handleKind(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool) {
+	handler := cs.KindHandlers[v.Kind()]
+	if handler == nil {
+		return false
+	}
+	defer catchPanic(w, v)
+	printVia(cs, w, "KindHandlers")
+	printText(cs, w, handler(v), false)
+	return true
+}
+
+// methodSignature returns the name and signature of the passed method without
+// its receiver or parameter names, such as Write([]uint8) (int, error).
+func methodSignature(cs *ConfigState, m reflect.Method) string {
+	ft := m.Type
+	var buf bytes.Buffer
+	buf.WriteString(m.Name)
+	buf.WriteByte('(')
+	for i := 1; i < ft.NumIn(); i++ {
+		if i > 1 {
+			buf.WriteString(", ")
+		}
+		if ft.IsVariadic() && i == ft.NumIn()-1 {
+			buf.WriteString("...")
+			buf.WriteString(typeName(cs, ft.In(i).Elem()))
+			continue
+		}
+		buf.WriteString(typeName(cs, ft.In(i)))
+	}
+	buf.WriteByte(')')
+	switch ft.NumOut() {
+	case 0:
+	case 1:
+		buf.WriteByte(' ')
+		buf.WriteString(typeName(cs, ft.Out(0)))
+	default:
+		buf.WriteString(" (")
+		for i := 0; i < ft.NumOut(); i++ {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			buf.WriteString(typeName(cs, ft.Out(i)))
+		}
+		buf.WriteByte(')')
+	}
+	return buf.String()
+}
+
+// runeSliceText returns the text the passed array or slice of runes represents
+// when the RuneSlicesAsString option is enabled.  Since rune is an alias for
+// int32, this applies to all arrays and slices of the predeclared int32 type,
+// but not to those of named types such as type Code int32.
+func runeSliceText(cs *ConfigState, v reflect.Value) (string, bool) {
+	if !cs.RuneSlicesAsString || v.Type().Elem() != runeType {
+		return "", false
+	}
+	runes := make([]rune, v.Len())
+	for i := range runes {
+		runes[i] = rune(v.Index(i).Int())
+	}
+	return string(runes), true
+}
+
+// printText outputs the passed text, quoted when requested, to Writer w.  Text
+// longer than allowed by the MaxStringLen option is cut off at a character
+// boundary and followed by the marker for the number of bytes which were not
+// displayed.
+func printText(cs *ConfigState, w io.Writer, s string, quote bool) {
+	remaining := 0
+	if cs.MaxStringLen > 0 && len(s) > cs.MaxStringLen {
+		n := cs.MaxStringLen
+		for n > 0 && !utf8.RuneStart(s[n]) {
+			n--
+		}
+		s, remaining = s[:n], len(s)-n
+	}
+	if quote {
+		s = strconv.Quote(s)
+	}
+	w.Write([]byte(s))
+	if remaining > 0 {
+		w.Write(spaceBytes)
+		printMore(cs, w, remaining)
+	}
+}
+
+// asciiWriter escapes all non-ASCII characters written to it as \u or \U
+// escapes, and invalid UTF-8 as \x escapes, before passing the result along
+// to the underlying writer.  It keeps track of the number of bytes written to
+// the underlying writer.
+type asciiWriter struct {
+	w io.Writer
+	n int
+}
+
+// Write writes the passed bytes to the underlying writer with all non-ASCII
+// characters escaped.  It is part of the io.Writer interface implementation.
+func (aw *asciiWriter) Write(p []byte) (int, error) {
+	i := 0
+	for i < len(p) && p[i] < utf8.RuneSelf {
+		i++
+	}
+	if i == len(p) {
+		n, err := aw.w.Write(p)
+		aw.n += n
+		return n, err
+	}
+
+	buf := make([]byte, i, len(p)+16)
+	copy(buf, p[:i])
+	for i < len(p) {
+		if p[i] < utf8.RuneSelf {
+			buf = append(buf, p[i])
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(p[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			buf = append(buf, fmt.Sprintf("\\x%02x", p[i])...)
+		case r <= 0xffff:
+			buf = append(buf, fmt.Sprintf("\\u%04x", r)...)
+		default:
+			buf = append(buf, fmt.Sprintf("\\U%08x", r)...)
+		}
+		i += size
+	}
+	n, err := writeFull(aw.w, buf)
+	aw.n += n
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// asciiState wraps a fmt.State in order to escape all non-ASCII characters
+// written to it according to the ASCIIOnly option.
+type asciiState struct {
+	fmt.State
+}
+
+// Write writes the passed bytes to the wrapped fmt.State with all non-ASCII
+// characters escaped.  It is part of the io.Writer interface implementation.
+func (s asciiState) Write(p []byte) (int, error) {
+	aw := asciiWriter{w: s.State}
+	return aw.Write(p)
+}
+
+// keyConfig returns the configuration used to display map keys according to
+// the KeyConfig option.  The indentation of the main configuration is always
+// used so keys line up with their values.
+func keyConfig(cs *ConfigState) *ConfigState {
+	if cs.KeyConfig == nil {
+		return cs
+	}
+	kcs := *cs.KeyConfig
+	kcs.Indent = cs.Indent
+	return &kcs
+}
+
+// unpackReflectValue returns the value held by the passed value when it is of
+// type reflect.Value, the same as the fmt package, or otherwise the passed
+// value itself.  The returned value is invalid when a reflect.Value which
+// doesn't hold a value, such as the zero reflect.Value{}, is passed.
+func unpackReflectValue(v reflect.Value) reflect.Value {
+	for v.IsValid() && v.Type() == reflectValueType {
+		if !v.CanInterface() {
+			if UnsafeDisabled {
+				return v
+			}
+			v = unsafeReflectValue(v)
+		}
+		v = v.Interface().(reflect.Value)
+	}
+	return v
+}
+
+// SpewIdentifier is an optional interface types can implement to provide an
+// identity token which is used to detect logical cycles in addition to the
+// circular references detected by pointer identity.  This prevents the
+// infinite expansion of recursive values which don't involve pointers, such as
+// value-based graph representations.  A value is displayed as already shown
+// when a value with the same token is currently being displayed by one of its
+// ancestors.  The token must be comparable and nil disables the detection for
+// the value.
+//
+// The tokens of all ancestors of the value currently being displayed are
+// tracked in a set, so types implementing this interface incur the cost of
+// calling the method along with a map insertion and deletion for each value.
+type SpewIdentifier interface {
+	SpewIdentity() interface{}
+}
+
+// identityToken returns the identity token provided by the passed value when
+// it implements the SpewIdentifier interface, either directly or via a pointer
+// receiver, and the token is comparable.
+func identityToken(v reflect.Value) (interface{}, bool) {
+	// We need an interface to check if the type implements the
+	// SpewIdentifier interface.  Use unsafe, when it's available, to bypass
+	// the visibility restrictions on things like unexported struct fields.
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return nil, false
+		}
+		v = unsafeReflectValue(v)
+	}
+	var token interface{}
+	if id, ok := v.Interface().(SpewIdentifier); ok {
+		token = id.SpewIdentity()
+	} else if v.CanAddr() {
+		if id, ok := v.Addr().Interface().(SpewIdentifier); ok {
+			token = id.SpewIdentity()
+		}
+	}
+	if token == nil || !reflect.TypeOf(token).Comparable() {
+		return nil, false
+	}
+	return token, true
+}
+
+// mapIdentity identifies a map by the address of its runtime representation.
+type mapIdentity uintptr
+
+// sliceIdentity identifies a slice by its type and the portion of its backing
+// array it refers to.
+type sliceIdentity struct {
+	typ  reflect.Type
+	data uintptr
+	len  int
+}
+
+// containerToken returns the token identifying the passed value when it is a
+// non-nil map or a non-empty slice.  Maps and slices which contain themselves
+// are reached through interface values rather than pointers, so the pointer
+// chain cycle detection doesn't catch them and they are tracked by their
+// identity instead.  A slice only has the same identity as one of its
+// elements when the element is the slice itself, so deeply nested, but
+// finite, slices of values are never mistaken for cycles.
+func containerToken(v reflect.Value) (interface{}, bool) {
+	switch v.Kind() {
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, false
+		}
+		return mapIdentity(v.Pointer()), true
+
+	case reflect.Slice:
+		if v.Len() == 0 {
+			return nil, false
+		}
+		return sliceIdentity{v.Type(), v.Pointer(), v.Len()}, true
+	}
+	return nil, false
+}
+
+// drainChannel receives the elements currently buffered in the passed channel
+// and sends them back in the same order, returning the received elements along
+// with the number of them which could not be sent back.  That only happens when
+// other goroutines use the channel concurrently or it has been closed, in which
+// case the elements are lost.  Only bidirectional channels can be drained since
+// both receiving and sending are required.  Channels in unexported fields
+// require the unsafe package when they are drained.
+func drainChannel(v reflect.Value) (elems []reflect.Value, lost int) {
+	if v.IsNil() || v.Len() == 0 || v.Type().ChanDir() != reflect.BothDir {
+		return nil, 0
+	}
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return nil, 0
+		}
+		v = unsafeReflectValue(v)
+	}
+
+	for n := v.Len(); len(elems) < n; {
+		elem, ok := v.TryRecv()
+		if !ok {
+			break
+		}
+		elems = append(elems, elem)
+	}
+	for i, elem := range elems {
+		if !trySend(v, elem) {
+			return elems, len(elems) - i
+		}
+	}
+	return elems, 0
+}
+
+// trySend attempts to send the passed value on the passed channel without
+// blocking and returns whether it was sent.  Sending on a closed channel is
+// reported as a failure rather than a panic.
+func trySend(ch, x reflect.Value) (sent bool) {
+	defer func() {
+		if recover() != nil {
+			sent = false
+		}
+	}()
+	return ch.TrySend(x)
+}
+
+// weakPointee returns the strong pointer to the value the passed value refers
+// to when the ShowRuntimeState option is enabled and the value is a weak
+// pointer from the weak package of the standard library.  The returned pointer
+// is nil when the value has been garbage collected.  Zero weak pointers are not
+// handled so they are displayed normally.
+func weakPointee(cs *ConfigState, v reflect.Value) (reflect.Value, bool) {
+	if !cs.ShowRuntimeState || v.Kind() != reflect.Struct {
+		return v, false
+	}
+	vt := v.Type()
+	if vt.PkgPath() != "weak" || !strings.HasPrefix(vt.Name(), "Pointer[") ||
+		v.IsZero() {
+
+		return v, false
+	}
+
+	// The Value method is looked up by name since weak pointers are generic
+	// and require Go 1.24.  Use unsafe, when it's available, to bypass the
+	// visibility restrictions on things like unexported struct fields.
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return v, false
+		}
+		v = unsafeReflectValue(v)
+	}
+	method := v.MethodByName("Value")
+	if !method.IsValid() || method.Type().NumIn() != 0 ||
+		method.Type().NumOut() != 1 {
+
+		return v, false
+	}
+	ptr := method.Call(nil)[0]
+	if ptr.Kind() != reflect.Ptr {
+		return v, false
+	}
+	return ptr, true
+}
+
+// valuerValue returns the result of the Value method of the passed value when
+// the UseValuer option is enabled and the value implements the driver.Valuer
+// interface, either directly or via a pointer receiver.  Values whose method
+// returns an error or panics are not handled so they are displayed normally.
+func valuerValue(cs *ConfigState, v reflect.Value) (val driver.Value, ok bool) {
+	if !cs.UseValuer {
+		return nil, false
+	}
+
+	// We need an interface to check if the type implements the
+	// driver.Valuer interface.  Use unsafe, when it's available, to bypass
+	// the visibility restrictions on things like unexported struct fields.
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return nil, false
+		}
+		v = unsafeReflectValue(v)
+	}
+	valuer, ok := v.Interface().(driver.Valuer)
+	if !ok && v.CanAddr() {
+		valuer, ok = v.Addr().Interface().(driver.Valuer)
+	}
+	if !ok {
+		return nil, false
+	}
+
+	defer func() {
+		if err := recover(); err != nil {
+			val, ok = nil, false
+		}
+	}()
+	val, err := valuer.Value()
+	if err != nil {
+		return nil, false
+	}
+	return val, true
+}
+
+// handleDuration outputs the human readable form of the passed reflect.Value
+// to Writer w when it represents a time.Duration and duration formatting is
+// enabled.
+func handleDuration(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool) {
+	if !cs.FormatDurations || v.Type() != durationType {
+		return false
+	}
+	printVia(cs, w, "FormatDurations")
+	w.Write([]byte(time.Duration(v.Int()).String()))
+	return true
+}
+
+// handleTime outputs the passed reflect.Value formatted according to the
+// TimeLayout option to Writer w when it represents a time.Time and the layout
+// is not empty.
+func handleTime(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool) {
+	if cs.TimeLayout == "" || v.Type() != timeType {
+		return false
+	}
+
+	// Formatting the time requires an interface to the underlying value.
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return false
+		}
+		v = unsafeReflectValue(v)
+	}
+	printVia(cs, w, "TimeLayout")
+	w.Write([]byte(v.Interface().(time.Time).Format(cs.TimeLayout)))
+	return true
+}
+
+// handleBitflags outputs the names of the flags which are set in the passed
+// reflect.Value followed by its binary representation to Writer w when it is
+// of a bitmask type registered via RegisterBitflags.
+func handleBitflags(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool) {
+	names, ok := cs.bitflags[v.Type()]
+	if !ok {
+		return false
+	}
+
+	var bits uint64
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bits = uint64(v.Int())
+		if size := v.Type().Bits(); size < 64 {
+			bits &= 1<<uint(size) - 1
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		bits = v.Uint()
+	default:
+		return false
+	}
+
+	flags := make([]uint64, 0, len(names))
+	for flag := range names {
+		if flag != 0 {
+			flags = append(flags, flag)
+		}
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i] < flags[j] })
+
+	printVia(cs, w, "Bitflags")
+	set := make([]string, 0, len(flags))
+	remaining := bits
+	for _, flag := range flags {
+		if bits&flag == flag {
+			set = append(set, names[flag])
+			remaining &^= flag
+		}
+	}
+	if remaining != 0 {
+		set = append(set, "0x"+strconv.FormatUint(remaining, 16))
+	}
+	switch {
+	case len(set) > 0:
+		w.Write([]byte(strings.Join(set, "|")))
+	case names[0] != "":
+		w.Write([]byte(names[0]))
+	default:
+		w.Write(zeroBytes)
+	}
+	w.Write(spaceBytes)
+	w.Write(openParenBytes)
+	w.Write(binaryPrefixBytes)
+	w.Write([]byte(strconv.FormatUint(bits, 2)))
+	w.Write(closeParenBytes)
+	return true
+}
+
+// imageTypeName returns the package qualified name of the passed type when it
+// is one of the types of the image and image/color packages displayed
+// compactly by the ExpandImageTypes option.  The types are identified by name
+// so those packages don't need to be imported.
+func imageTypeName(t reflect.Type) string {
+	switch t.PkgPath() {
+	case "image", "image/color":
+		return t.PkgPath() + "." + t.Name()
+	}
+	return ""
+}
+
+// handleImage outputs the compact form of the passed reflect.Value to Writer w
+// when it is an image.Point, image.Rectangle, or one of the RGBA colors of the
+// image/color package and the ExpandImageTypes option is enabled.
+func handleImage(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool) {
+	if !cs.ExpandImageTypes || v.Kind() != reflect.Struct {
+		return false
+	}
+
+	var s string
+	switch imageTypeName(v.Type()) {
+	case "image.Point":
+		s = imagePoint(v)
+	case "image.Rectangle":
+		s = imagePoint(v.Field(0)) + "-" + imagePoint(v.Field(1))
+	case "image/color.RGBA", "image/color.NRGBA":
+		s = fmt.Sprintf("#%02X%02X%02X%02X", v.Field(0).Uint(),
+			v.Field(1).Uint(), v.Field(2).Uint(), v.Field(3).Uint())
+	case "image/color.RGBA64", "image/color.NRGBA64":
+		s = fmt.Sprintf("#%04X%04X%04X%04X", v.Field(0).Uint(),
+			v.Field(1).Uint(), v.Field(2).Uint(), v.Field(3).Uint())
+	default:
+		return false
+	}
+	printVia(cs, w, "ExpandImageTypes")
+	w.Write([]byte(s))
+	return true
+}
+
+// isNetAddrType returns whether the passed type is one of the address types of
+// the net and net/url packages displayed via their String methods by the
+// ExpandNetAddrs option.  The types are identified by name so those packages
+// don't need to be imported.
+func isNetAddrType(t reflect.Type) bool {
+	switch t.PkgPath() + "." + t.Name() {
+	case "net.IP", "net.IPNet", "net.HardwareAddr", "net/url.URL":
 		return true
 	}
 	return false
 }
 
+// handleNetAddr outputs the result of the String method of the passed
+// reflect.Value to Writer w when it is one of the address types of the net and
+// net/url packages and the ExpandNetAddrs option is enabled.  Since the method
+// of url.URL has a pointer receiver, it is invoked on a copy of the value.
+func handleNetAddr(cs *ConfigState, w io.Writer, v reflect.Value) (handled bool) {
+	if !cs.ExpandNetAddrs || cs.DisableMethods || !isNetAddrType(v.Type()) {
+		return false
+	}
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return false
+		}
+		v = unsafeReflectValue(v)
+	}
+	pv := reflect.New(v.Type())
+	pv.Elem().Set(v)
+	s, ok := pv.Interface().(fmt.Stringer)
+	if !ok {
+		return false
+	}
+
+	defer catchPanic(w, v)
+	printVia(cs, w, "ExpandNetAddrs")
+	printText(cs, w, s.String(), false)
+	return true
+}
+
+// imagePoint returns the passed image.Point formatted as (X,Y).
+func imagePoint(v reflect.Value) string {
+	return "(" + strconv.FormatInt(v.Field(0).Int(), 10) + "," +
+		strconv.FormatInt(v.Field(1).Int(), 10) + ")"
+}
+
+// transform returns the value to display in place of the passed value
+// according to the Transform option along with whether or not the value should
+// be omitted altogether and whether or not it was replaced.
+func transform(cs *ConfigState, v reflect.Value) (tv reflect.Value, omitted, replaced bool) {
+	if cs.Transform == nil {
+		return v, false, false
+	}
+	tv, ok := cs.Transform(v)
+	if !ok {
+		return v, false, false
+	}
+	if !tv.IsValid() {
+		return v, true, false
+	}
+	return tv, false, true
+}
+
+// printVia outputs a comment naming the code path which produced the value
+// being displayed to Writer w when the DebugSpew option is enabled.
+func printVia(cs *ConfigState, w io.Writer, path string) {
+	if !cs.DebugSpew {
+		return
+	}
+	w.Write(openCommentBytes)
+	w.Write([]byte(path))
+	w.Write(closeCommentBytes)
+}
+
+// typeChain returns the suffix of the type annotation of the passed type
+// according to the ShowTypeChain option, such as " -> int" for a type defined as
+// type Celsius int.  The reflect package only provides the underlying type of a
+// defined type, so types defined in terms of other defined types, such as
+// type A B, skip the intermediate types.  Predeclared and unnamed types have no
+// chain.
+func typeChain(cs *ConfigState, t reflect.Type) string {
+	if !cs.ShowTypeChain || t.Name() == "" || t.PkgPath() == "" {
+		return ""
+	}
+
+	var underlying string
+	switch t.Kind() {
+	case reflect.Array:
+		underlying = typeName(cs, reflect.ArrayOf(t.Len(), t.Elem()))
+	case reflect.Chan:
+		underlying = typeName(cs, reflect.ChanOf(t.ChanDir(), t.Elem()))
+	case reflect.Map:
+		underlying = typeName(cs, reflect.MapOf(t.Key(), t.Elem()))
+	case reflect.Ptr:
+		underlying = typeName(cs, reflect.PtrTo(t.Elem()))
+	case reflect.Slice:
+		underlying = typeName(cs, reflect.SliceOf(t.Elem()))
+	default:
+		// Structs, interfaces, and functions are described by their kind
+		// since spelling out their fields or methods would take over the
+		// annotation.
+		underlying = t.Kind().String()
+	}
+	return " -> " + underlying
+}
+
+// typeName returns the name of the passed type used in type annotations
+// according to the TypeNameFunc option.  When the function doesn't provide a
+// name for an unnamed composite type, such as a pointer, slice, or map, the
+// name is built from the names of its key and element types.
+func typeName(cs *ConfigState, t reflect.Type) string {
+	if cs.TypeNameFunc == nil {
+		return t.String()
+	}
+	if name := cs.TypeNameFunc(t); name != "" {
+		return name
+	}
+	if t.Name() != "" {
+		return t.String()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return "*" + typeName(cs, t.Elem())
+
+	case reflect.Slice:
+		return "[]" + typeName(cs, t.Elem())
+
+	case reflect.Array:
+		return "[" + strconv.Itoa(t.Len()) + "]" + typeName(cs, t.Elem())
+
+	case reflect.Map:
+		return "map[" + typeName(cs, t.Key()) + "]" + typeName(cs, t.Elem())
+
+	case reflect.Chan:
+		elem := typeName(cs, t.Elem())
+		switch t.ChanDir() {
+		case reflect.RecvDir:
+			return "<-chan " + elem
+		case reflect.SendDir:
+			return "chan<- " + elem
+		}
+		if t.Elem().Kind() == reflect.Chan && t.Elem().ChanDir() == reflect.RecvDir {
+			return "chan (" + elem + ")"
+		}
+		return "chan " + elem
+	}
+	return t.String()
+}
+
+// structName returns the short name of the passed struct type used by the
+// KeyValueStructStyle option, such as Foo rather than main.Foo.  The
+// TypeNameFunc option takes precedence.
+func structName(cs *ConfigState, t reflect.Type) string {
+	if cs.TypeNameFunc != nil {
+		if name := cs.TypeNameFunc(t); name != "" {
+			return name
+		}
+	}
+	if t.Name() != "" {
+		return t.Name()
+	}
+	return typeName(cs, t)
+}
+
+// mapSeparator returns the separator between map keys and values according to
+// the MapKVSeparator option, falling back to the passed default separator for
+// the style of output when it is not set.
+func mapSeparator(cs *ConfigState, def []byte) []byte {
+	if cs.MapKVSeparator == "" {
+		return def
+	}
+	return []byte(cs.MapKVSeparator)
+}
+
+// numShown returns the number of elements out of the passed total that should
+// be displayed according to the MaxElements option.
+func numShown(cs *ConfigState, total int) int {
+	if cs.MaxElements != 0 && total > cs.MaxElements {
+		return cs.MaxElements
+	}
+	return total
+}
+
+// ellipsis returns the marker used for truncated output according to the
+// Ellipsis option.
+func ellipsis(cs *ConfigState) []byte {
+	if cs.Ellipsis == "" {
+		return ellipsisBytes
+	}
+	return []byte(cs.Ellipsis)
+}
+
+// printMore outputs the marker used in place of the passed number of elements
+// that were not displayed, such as due to the MaxElements option, to Writer w.
+// Only the ellipsis is output when the number of elements is not known, which
+// is indicated by a negative number.
+func printMore(cs *ConfigState, w io.Writer, remaining int) {
+	w.Write(ellipsis(cs))
+	if remaining < 0 {
+		return
+	}
+	w.Write(spaceBytes)
+	w.Write(openParenBytes)
+	printInt(w, int64(remaining), 10)
+	w.Write(moreBytes)
+}
+
 // printBool outputs a boolean value as true or false to Writer w.
 func printBool(w io.Writer, val bool) {
 	if val {
@@ -159,27 +1118,178 @@ func printUint(w io.Writer, val uint64, base int) {
 	w.Write([]byte(strconv.FormatUint(val, base)))
 }
 
-// printFloat outputs a floating point value using the specified precision,
-// which is expected to be 32 or 64bit, to Writer w.
-func printFloat(w io.Writer, val float64, precision int) {
-	w.Write([]byte(strconv.FormatFloat(val, 'g', -1, precision)))
+// printFloat outputs a floating point value using the specified format, as
+// accepted by strconv.FormatFloat, and precision, which is expected to be 32 or
+// 64bit, to Writer w.
+func printFloat(w io.Writer, val float64, format byte, precision int) {
+	w.Write([]byte(strconv.FormatFloat(val, format, -1, precision)))
+}
+
+// floatFormat returns the format floating point values are displayed in
+// according to the FloatFormat option.  Formats strconv.FormatFloat doesn't
+// accept fall back to the default 'g' format.
+func floatFormat(cs *ConfigState) byte {
+	switch cs.FloatFormat {
+	case 'b', 'e', 'E', 'f', 'g', 'G', 'x', 'X':
+		return cs.FloatFormat
+	}
+	return 'g'
 }
 
-// printComplex outputs a complex value using the specified float precision
-// for the real and imaginary parts to Writer w.
-func printComplex(w io.Writer, c complex128, floatPrecision int) {
+// printIntValue outputs a signed integer value to Writer w according to the
+// GroupDigits option.
+func printIntValue(cs *ConfigState, w io.Writer, val int64) {
+	if !cs.GroupDigits {
+		printInt(w, val, 10)
+		return
+	}
+	w.Write([]byte(groupDigits(cs, strconv.FormatInt(val, 10))))
+}
+
+// printUintValue outputs an unsigned integer value to Writer w according to
+// the GroupDigits option.
+func printUintValue(cs *ConfigState, w io.Writer, val uint64) {
+	if !cs.GroupDigits {
+		printUint(w, val, 10)
+		return
+	}
+	w.Write([]byte(groupDigits(cs, strconv.FormatUint(val, 10))))
+}
+
+// printFloatValue outputs a floating point value using the specified precision
+// to Writer w according to the FloatFormat, VerboseFloats, and GroupDigits
+// options.
+func printFloatValue(cs *ConfigState, w io.Writer, val float64, precision int) {
+	if cs.GroupDigits {
+		var buf bytes.Buffer
+		defer func(w io.Writer) {
+			w.Write([]byte(groupDigits(cs, buf.String())))
+		}(w)
+		w = &buf
+	}
+	if cs.VerboseFloats {
+		printVerboseFloat(w, val, floatFormat(cs), precision)
+		return
+	}
+	printFloat(w, val, floatFormat(cs), precision)
+}
+
+// groupDigits inserts the DigitSeparator option, or a comma when it is not
+// set, between every group of three digits in the integer part of the passed
+// decimal number.
+func groupDigits(cs *ConfigState, num string) string {
+	sep := cs.DigitSeparator
+	if sep == "" {
+		sep = ","
+	}
+
+	start := 0
+	if start < len(num) && (num[0] == '-' || num[0] == '+') {
+		start++
+	}
+	end := start
+	for end < len(num) && num[end] >= '0' && num[end] <= '9' {
+		end++
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(num[:start])
+	for i := start; i < end; i++ {
+		if i > start && (end-i)%3 == 0 {
+			buf.WriteString(sep)
+		}
+		buf.WriteByte(num[i])
+	}
+	buf.WriteString(num[end:])
+	return buf.String()
+}
+
+// printRune outputs a rune value as a quoted character followed by its numeric
+// value, such as 'A' (65), to Writer w.  Non-printable runes are displayed as
+// \u or \U escapes and invalid runes are displayed as numbers.
+func printRune(w io.Writer, val int64) {
+	r := rune(val)
+	if !utf8.ValidRune(r) {
+		printInt(w, val, 10)
+		return
+	}
+	switch {
+	case unicode.IsPrint(r):
+		w.Write([]byte(strconv.QuoteRune(r)))
+	case r <= 0xffff:
+		fmt.Fprintf(w, "'\\u%04x'", r)
+	default:
+		fmt.Fprintf(w, "'\\U%08x'", r)
+	}
+	w.Write(spaceBytes)
+	w.Write(openParenBytes)
+	printInt(w, val, 10)
+	w.Write(closeParenBytes)
+}
+
+// printVerboseFloat outputs a floating point value using the specified
+// precision to Writer w while distinguishing the values the standard
+// formatting hides.  Negative zero is displayed as -0.0, NaNs are displayed with
+// their bit pattern such as NaN(0x7ff8000000000001), and subnormal values are
+// flagged.
+func printVerboseFloat(w io.Writer, val float64, format byte, precision int) {
+	switch {
+	case val == 0 && math.Signbit(val):
+		w.Write(negativeZeroBytes)
+
+	case math.IsNaN(val):
+		w.Write(nanOpenBytes)
+		if precision == 32 {
+			bits := math.Float32bits(float32(val))
+			w.Write([]byte(fmt.Sprintf("%#08x", bits)))
+		} else {
+			w.Write([]byte(fmt.Sprintf("%#016x", math.Float64bits(val))))
+		}
+		w.Write(closeParenBytes)
+
+	default:
+		printFloat(w, val, format, precision)
+		smallestNormal := math.Float64frombits(0x0010000000000000)
+		if precision == 32 {
+			smallestNormal = float64(math.Float32frombits(0x00800000))
+		}
+		if val != 0 && math.Abs(val) < smallestNormal {
+			w.Write(subnormalBytes)
+		}
+	}
+}
+
+// printComplex outputs a complex value using the specified float format and
+// precision for the real and imaginary parts to Writer w.
+func printComplex(w io.Writer, c complex128, format byte, floatPrecision int) {
 	r := real(c)
 	w.Write(openParenBytes)
-	w.Write([]byte(strconv.FormatFloat(r, 'g', -1, floatPrecision)))
+	w.Write([]byte(strconv.FormatFloat(r, format, -1, floatPrecision)))
 	i := imag(c)
 	if i >= 0 {
 		w.Write(plusBytes)
 	}
-	w.Write([]byte(strconv.FormatFloat(i, 'g', -1, floatPrecision)))
+	w.Write([]byte(strconv.FormatFloat(i, format, -1, floatPrecision)))
 	w.Write(iBytes)
 	w.Write(closeParenBytes)
 }
 
+// printAddr outputs the passed address formatted as hexidecimal to Writer w.
+// When the StableAddresses option is enabled, each distinct non-nil address is
+// replaced by a fake sequential address, starting at 0x1, which is assigned in
+// the order the addresses are encountered and recorded in the passed map.
+func printAddr(cs *ConfigState, w io.Writer, fakeAddrs map[uintptr]uintptr, p uintptr) {
+	if cs.StableAddresses && p != 0 {
+		fake, ok := fakeAddrs[p]
+		if !ok {
+			fake = uintptr(len(fakeAddrs) + 1)
+			fakeAddrs[p] = fake
+		}
+		p = fake
+	}
+	printHexPtr(w, p)
+}
+
 // printHexPtr outputs a uintptr formatted as hexidecimal with a leading '0x'
 // prefix to Writer w.
 func printHexPtr(w io.Writer, p uintptr) {
@@ -214,6 +1324,21 @@ func printHexPtr(w io.Writer, p uintptr) {
 	w.Write(buf)
 }
 
+// printFunc outputs the fully qualified name of the function the passed
+// reflect.Value represents to Writer w when function name resolution is
+// enabled and the name can be resolved.  Otherwise, it outputs the address of
+// the function.  Closures are named after their enclosing function along with
+// a sequence number such as main.doThing.func1.
+func printFunc(cs *ConfigState, w io.Writer, v reflect.Value) {
+	if cs.ResolveFuncNames && !v.IsNil() {
+		if fn := runtime.FuncForPC(v.Pointer()); fn != nil {
+			w.Write([]byte(fn.Name()))
+			return
+		}
+	}
+	printHexPtr(w, v.Pointer())
+}
+
 // valuesSorter implements sort.Interface to allow a slice of reflect.Value
 // elements to be sorted.
 type valuesSorter struct {
@@ -234,22 +1359,65 @@ func newValuesSorter(values []reflect.Value, cs *ConfigState) sort.Interface {
 		vs.strings = make([]string, len(values))
 		for i := range vs.values {
 			b := bytes.Buffer{}
-			if !handleMethods(cs, &b, vs.values[i]) {
+			if !handleMethods(cs, &b, vs.values[i], nil) {
 				vs.strings = nil
 				break
 			}
 			vs.strings[i] = b.String()
 		}
 	}
-	if vs.strings == nil && cs.SpewKeys {
+	if vs.strings == nil && cs.DerefMapKeys &&
+		vs.values[0].Kind() == reflect.Ptr {
+
 		vs.strings = make([]string, len(values))
-		for i := range vs.values {
-			vs.strings[i] = Sprintf("%#v", vs.values[i].Interface())
+		for i, v := range vs.values {
+			vs.strings[i] = pointeeString(cs, v)
+		}
+	}
+	if vs.strings == nil {
+		vs.strings = make([]string, len(values))
+		scs := sortConfig(cs)
+		for i, v := range vs.values {
+			vs.strings[i] = sortString(scs, v)
 		}
 	}
 	return vs
 }
 
+// sortConfig returns the configuration used to render values which can't be
+// compared natively for sorting.  It is the passed configuration with stable
+// addresses so values holding pointers are ordered by their content instead of
+// by where they happen to be allocated.
+func sortConfig(cs *ConfigState) *ConfigState {
+	scs := *cs
+	scs.StableAddresses = true
+	return &scs
+}
+
+// sortString returns the rendered form of the passed value which is used to
+// sort it according to the passed configuration from sortConfig.
+func sortString(scs *ConfigState, v reflect.Value) string {
+	if v.CanInterface() {
+		return scs.Sprintf("%#v", v.Interface())
+	}
+	return fmt.Sprintf("%#v", v)
+}
+
+// pointeeString returns the rendered form of the value the passed pointer
+// points to which is used to sort pointer map keys according to the
+// DerefMapKeys option.  Nil pointers are rendered as an empty string so they
+// sort first.
+func pointeeString(cs *ConfigState, v reflect.Value) string {
+	if v.IsNil() {
+		return ""
+	}
+	v = v.Elem()
+	if v.CanInterface() {
+		return cs.Sprintf("%#v", v.Interface())
+	}
+	return fmt.Sprintf("%#v", v)
+}
+
 // canSortSimply tests whether a reflect.Kind is a primitive that can be sorted
 // directly, or whether it should be considered for sorting by surrogate keys
 // (if the ConfigState allows it).
@@ -327,7 +1495,285 @@ func (s *valuesSorter) Less(i, j int) bool {
 	if s.strings == nil {
 		return valueSortLess(s.values[i], s.values[j])
 	}
-	return s.strings[i] < s.strings[j]
+	if s.strings[i] != s.strings[j] {
+		return s.strings[i] < s.strings[j]
+	}
+
+	// Values which are rendered identically, such as channels, are ordered
+	// by address so their order doesn't depend on the map iteration order.
+	return sortAddr(s.values[i]) < sortAddr(s.values[j])
+}
+
+// sortAddr returns the address the passed value refers to, or zero when it
+// isn't a reference, which is used to order values rendered identically.
+func sortAddr(v reflect.Value) uintptr {
+	switch v.Kind() {
+	case reflect.Chan, reflect.Func, reflect.Map, reflect.Ptr, reflect.Slice,
+		reflect.UnsafePointer:
+		return v.Pointer()
+	}
+	return 0
+}
+
+// isScalarKind returns whether the passed reflect.Kind is a boolean, numeric,
+// or string kind.
+func isScalarKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Bool, reflect.String:
+		return true
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
+		return true
+	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
+		return true
+	case reflect.Float32, reflect.Float64, reflect.Complex64, reflect.Complex128:
+		return true
+	}
+	return false
+}
+
+// visibleFields returns the indices of the fields of the passed struct value
+// which should be displayed according to the FieldNameFilter,
+// ExpandProtoMessages, and ExportedOnly options.  All fields not otherwise
+// hidden are visible when there is no filter or it has been lifted because an
+// enclosing field already matched.  The passed matcher remembers which values
+// contain matching fields so nested structs don't search them again.
+func visibleFields(cs *ConfigState, v reflect.Value, unfiltered bool, matches *fieldMatcher) []int {
+	numFields := v.NumField()
+	fields := make([]int, 0, numFields)
+	skipProtoFields := isExpandedProto(cs, v.Type())
+	for i := 0; i < numFields; i++ {
+		if skipProtoFields && protoInternalFields[v.Type().Field(i).Name] {
+			continue
+		}
+		if isHiddenField(cs, v.Type(), i) {
+			continue
+		}
+		if cs.FieldNameFilter == nil || unfiltered ||
+			fieldNameMatches(cs, v.Type().Field(i)) ||
+			matches.hasMatchingField(cs, v.Field(i)) {
+
+			fields = append(fields, i)
+		}
+	}
+	sortFields(cs, v.Type(), fields)
+	return fields
+}
+
+// sortFields sorts the passed field indices of the passed struct type in the
+// order specified by the FieldSort option.
+func sortFields(cs *ConfigState, t reflect.Type, fields []int) {
+	switch cs.FieldSort {
+	case NameFieldOrder:
+		sort.SliceStable(fields, func(i, j int) bool {
+			return t.Field(fields[i]).Name < t.Field(fields[j]).Name
+		})
+	case TypeThenNameFieldOrder:
+		sort.SliceStable(fields, func(i, j int) bool {
+			fi, fj := t.Field(fields[i]), t.Field(fields[j])
+			ti, tj := fi.Type.String(), fj.Type.String()
+			if ti != tj {
+				return ti < tj
+			}
+			return fi.Name < fj.Name
+		})
+	}
+}
+
+// isHiddenField returns whether the field with the passed index of the passed
+// struct type is hidden according to the ExportedOnly and UnexportedPolicy
+// options.
+func isHiddenField(cs *ConfigState, t reflect.Type, i int) bool {
+	pkgPath := t.Field(i).PkgPath
+	if pkgPath == "" || cs.allowUnexported[t] {
+		return false
+	}
+	switch {
+	case cs.ExportedOnly, cs.UnexportedPolicy == UnexportedNone:
+		return true
+	case cs.UnexportedPolicy == UnexportedOwnPackagesOnly:
+		for _, prefix := range cs.OwnPackages {
+			if strings.HasPrefix(pkgPath, prefix) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// protoInternalFields houses the names of the internal bookkeeping fields of
+// generated protobuf messages which are skipped when the ExpandProtoMessages
+// option is enabled.
+var protoInternalFields = map[string]bool{
+	"state":         true,
+	"sizeCache":     true,
+	"unknownFields": true,
+}
+
+// isExpandedProto returns whether the passed type is a generated protobuf
+// message which should be displayed by its logical fields according to the
+// ExpandProtoMessages option.  Messages are detected by their pointer having
+// the Reset, String, and ProtoReflect methods of the proto.Message interface
+// so the protobuf package doesn't need to be imported.
+func isExpandedProto(cs *ConfigState, t reflect.Type) bool {
+	if !cs.ExpandProtoMessages || t.Kind() != reflect.Struct {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	reset, ok := pt.MethodByName("Reset")
+	if !ok || reset.Type.NumIn() != 1 || reset.Type.NumOut() != 0 {
+		return false
+	}
+	str, ok := pt.MethodByName("String")
+	if !ok || str.Type.NumIn() != 1 || str.Type.NumOut() != 1 ||
+		str.Type.Out(0).Kind() != reflect.String {
+
+		return false
+	}
+	pr, ok := pt.MethodByName("ProtoReflect")
+	return ok && pr.Type.NumIn() == 1 && pr.Type.NumOut() == 1
+}
+
+// fieldName returns the name the passed struct field is displayed with
+// according to the FieldNameFunc option.
+func fieldName(cs *ConfigState, field reflect.StructField) string {
+	if cs.FieldNameFunc != nil {
+		if name := cs.FieldNameFunc(field); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// fieldNameMatches returns whether the name of the passed struct field
+// matches the FieldNameFilter option.
+func fieldNameMatches(cs *ConfigState, field reflect.StructField) bool {
+	return cs.FieldNameFilter != nil &&
+		cs.FieldNameFilter.MatchString(field.Name)
+}
+
+// fieldMatchKey identifies a value searched for fields matching the
+// FieldNameFilter option by its address and type, since structs share their
+// address with their first field.
+type fieldMatchKey struct {
+	addr uintptr
+	vt   reflect.Type
+}
+
+// fieldMatcher remembers which values contain a struct field matching the
+// FieldNameFilter option across the calls made while displaying a value, so
+// each value is searched at most once rather than again for every enclosing
+// struct.  The zero value is ready to use.
+type fieldMatcher struct {
+	known map[interface{}]bool
+}
+
+// matchKey returns the key the passed value is remembered by, which is the
+// identity of maps and slices or the address of pointers and addressable
+// values, and whether it has one.
+func matchKey(v reflect.Value) (interface{}, bool) {
+	if token, ok := containerToken(v); ok {
+		return token, true
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if !v.IsNil() {
+			return fieldMatchKey{v.Pointer(), v.Type()}, true
+		}
+	case reflect.Array, reflect.Struct:
+		if v.CanAddr() {
+			return fieldMatchKey{v.UnsafeAddr(), v.Type()}, true
+		}
+	}
+	return nil, false
+}
+
+// hasMatchingField returns whether the passed value contains a struct field,
+// at any level of nesting, whose name matches the FieldNameFilter option.
+// Hidden fields are not searched.  A nil matcher remembers nothing.
+func (m *fieldMatcher) hasMatchingField(cs *ConfigState, v reflect.Value) bool {
+	if m == nil {
+		m = &fieldMatcher{}
+	}
+	if m.known == nil {
+		m.known = make(map[interface{}]bool)
+	}
+	seen := make(map[interface{}]bool)
+	if m.search(cs, v, seen) {
+		return true
+	}
+	// Nothing reachable from the value matched, so none of the values seen
+	// along the way can contain a match either.
+	for key := range seen {
+		m.known[key] = false
+	}
+	return false
+}
+
+// search returns whether the passed value contains a matching struct field.
+// The seen map holds the keys of the values visited by the current search to
+// avoid following circular references forever.  Values which are found to
+// contain a match are remembered right away since that holds regardless of how
+// they were reached, while values which don't are only remembered once the
+// whole search failed because a circular reference may have cut it short.
+func (m *fieldMatcher) search(cs *ConfigState, v reflect.Value, seen map[interface{}]bool) bool {
+	key, ok := matchKey(v)
+	if ok {
+		if found, known := m.known[key]; known {
+			return found
+		}
+		if seen[key] {
+			return false
+		}
+		seen[key] = true
+	}
+	found := m.searchChildren(cs, v, seen)
+	if found && ok {
+		m.known[key] = true
+	}
+	return found
+}
+
+// searchChildren returns whether the fields, elements, keys, or value the
+// passed value holds contain a matching struct field.
+func (m *fieldMatcher) searchChildren(cs *ConfigState, v reflect.Value, seen map[interface{}]bool) bool {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return false
+		}
+		return m.search(cs, v.Elem(), seen)
+
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			if m.search(cs, v.Index(i), seen) {
+				return true
+			}
+		}
+
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			if m.search(cs, key, seen) ||
+				m.search(cs, v.MapIndex(key), seen) {
+
+				return true
+			}
+		}
+
+	case reflect.Struct:
+		vt := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if isHiddenField(cs, vt, i) {
+				continue
+			}
+			if fieldNameMatches(cs, vt.Field(i)) ||
+				m.search(cs, v.Field(i), seen) {
+
+				return true
+			}
+		}
+	}
+	return false
 }
 
 // sortValues is a sort function that handles both native types and any type that
@@ -339,3 +1785,120 @@ func sortValues(values []reflect.Value, cs *ConfigState) {
 	}
 	sort.Sort(newValuesSorter(values, cs))
 }
+
+// sortMapKeys orders the passed map keys according to the SortKeys and
+// MapKeyOrder options.
+func sortMapKeys(keys []reflect.Value, cs *ConfigState) {
+	switch {
+	case cs.SortKeys || cs.MapKeyOrder == SortedKeyOrder:
+		sortValues(keys, cs)
+
+	case cs.MapKeyOrder == StableKeyOrder:
+		rendered := make([]string, len(keys))
+		hashes := make([]uint64, len(keys))
+		scs := sortConfig(cs)
+		for i, key := range keys {
+			rendered[i] = sortString(scs, key)
+			h := fnv.New64a()
+			h.Write([]byte(rendered[i]))
+			hashes[i] = h.Sum64()
+		}
+		sort.Sort(&stableKeySorter{keys, rendered, hashes})
+	}
+}
+
+// stableKeySorter implements sort.Interface to order map keys by the hash of
+// their rendered form, falling back to the rendered form itself for the
+// unlikely case of hash collisions.
+type stableKeySorter struct {
+	keys     []reflect.Value
+	rendered []string
+	hashes   []uint64
+}
+
+// Len returns the number of keys in the list.  It is part of the
+// sort.Interface implementation.
+func (s *stableKeySorter) Len() int {
+	return len(s.keys)
+}
+
+// Swap swaps the keys at the passed indices.  It is part of the
+// sort.Interface implementation.
+func (s *stableKeySorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.rendered[i], s.rendered[j] = s.rendered[j], s.rendered[i]
+	s.hashes[i], s.hashes[j] = s.hashes[j], s.hashes[i]
+}
+
+// Less returns whether the key with index i should sort before the key with
+// index j.  It is part of the sort.Interface implementation.
+func (s *stableKeySorter) Less(i, j int) bool {
+	if s.hashes[i] != s.hashes[j] {
+		return s.hashes[i] < s.hashes[j]
+	}
+	return s.rendered[i] < s.rendered[j]
+}
+
+// isNilMapValue returns whether the passed map value is a nil pointer or nil
+// interface which should be marked according to the MarkNilMapValues option.
+func isNilMapValue(cs *ConfigState, v reflect.Value) bool {
+	if !cs.MarkNilMapValues {
+		return false
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		return v.IsNil()
+	}
+	return false
+}
+
+// fieldGroup returns the group of the passed struct field from its spewgroup
+// struct tag with any runs of whitespace collapsed to single spaces.
+func fieldGroup(f reflect.StructField) string {
+	return strings.Join(strings.Fields(f.Tag.Get("spewgroup")), " ")
+}
+
+// fieldDoc returns the documentation of the passed struct field from the
+// struct tag named by the DocTag option with any runs of whitespace, including
+// line breaks, collapsed to single spaces so the comment stays on one line.
+func fieldDoc(cs *ConfigState, f reflect.StructField) string {
+	if cs.DocTag == "" {
+		return ""
+	}
+	return strings.Join(strings.Fields(f.Tag.Get(cs.DocTag)), " ")
+}
+
+// spewFuncPrefix is the prefix of the names of all functions in this package.
+// It is used to skip over them when locating the call site for the
+// IncludeCaller option.
+var spewFuncPrefix = reflect.TypeOf(ConfigState{}).PkgPath() + "."
+
+// printArgLabel outputs the label of the top-level argument with the passed
+// index, such as arg[0]:, followed by a newline to Writer w according to the
+// LabelArgs option.
+func printArgLabel(w io.Writer, index int) {
+	w.Write(argLabelBytes)
+	printInt(w, int64(index), 10)
+	w.Write(closeArgLabelBytes)
+}
+
+// printCaller outputs the file name and line number of the first caller
+// outside of this package followed by a newline to Writer w.
+func printCaller(w io.Writer) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, spewFuncPrefix) {
+			w.Write([]byte(filepath.Base(frame.File)))
+			w.Write(colonBytes)
+			printInt(w, int64(frame.Line), 10)
+			w.Write(colonBytes)
+			w.Write(newlineBytes)
+			return
+		}
+		if !more {
+			return
+		}
+	}
+}
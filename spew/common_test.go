@@ -208,20 +208,20 @@ func TestSortValues(t *testing.T) {
 		},
 		// SortableStructs.
 		{
-			// Note: not sorted - DisableMethods is set.
-			[]reflect.Value{v(sortableStruct{2}), v(sortableStruct{1}), v(sortableStruct{3})},
+			// Note: sorted by the spewed strings - DisableMethods is set.
 			[]reflect.Value{v(sortableStruct{2}), v(sortableStruct{1}), v(sortableStruct{3})},
+			[]reflect.Value{v(sortableStruct{1}), v(sortableStruct{2}), v(sortableStruct{3})},
 		},
 		// UnsortableStructs.
 		{
-			// Note: not sorted - SpewKeys is false.
-			[]reflect.Value{v(unsortableStruct{2}), v(unsortableStruct{1}), v(unsortableStruct{3})},
+			// Note: sorted by the spewed strings even though SpewKeys is false.
 			[]reflect.Value{v(unsortableStruct{2}), v(unsortableStruct{1}), v(unsortableStruct{3})},
+			[]reflect.Value{v(unsortableStruct{1}), v(unsortableStruct{2}), v(unsortableStruct{3})},
 		},
 		// Invalid.
 		{
 			[]reflect.Value{embedB, embedA, embedC},
-			[]reflect.Value{embedB, embedA, embedC},
+			[]reflect.Value{embedA, embedB, embedC},
 		},
 	}
 	cs := spew.ConfigState{DisableMethods: true, SpewKeys: false}
@@ -254,9 +254,9 @@ func TestSortValuesWithMethods(t *testing.T) {
 		},
 		// UnsortableStructs.
 		{
-			// Note: not sorted - SpewKeys is false.
-			[]reflect.Value{v(unsortableStruct{2}), v(unsortableStruct{1}), v(unsortableStruct{3})},
+			// Note: sorted by the spewed strings even though SpewKeys is false.
 			[]reflect.Value{v(unsortableStruct{2}), v(unsortableStruct{1}), v(unsortableStruct{3})},
+			[]reflect.Value{v(unsortableStruct{1}), v(unsortableStruct{2}), v(unsortableStruct{3})},
 		},
 	}
 	cs := spew.ConfigState{DisableMethods: false, SpewKeys: false}
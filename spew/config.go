@@ -17,10 +17,106 @@
 package spew
 
 import (
-	"bytes"
 	"fmt"
 	"io"
 	"os"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// KeyOrder specifies the order map keys are displayed in.  See the MapKeyOrder
+// option of ConfigState.
+type KeyOrder int
+
+const (
+	// HashKeyOrder displays map keys in the unstable order the runtime
+	// iterates them.  This is the default.
+	HashKeyOrder KeyOrder = iota
+
+	// SortedKeyOrder sorts map keys exactly the same as the SortKeys
+	// option.
+	SortedKeyOrder
+
+	// StableKeyOrder sorts map keys by a hash of their rendered form, which
+	// is deterministic even for key types that can't be compared in a
+	// meaningful way.
+	StableKeyOrder
+)
+
+// StructStyle specifies how structs are displayed.  See the StructStyle option
+// of ConfigState.
+type StructStyle int
+
+const (
+	// BlockStructStyle displays structs as a block with one field per line
+	// in Dump style output.  This is the default.
+	BlockStructStyle StructStyle = iota
+
+	// KeyValueStructStyle displays structs on a single line as key=value
+	// pairs, such as Foo{flag=flagTwo data=<nil>}.
+	KeyValueStructStyle
+)
+
+// FieldOrder specifies the order struct fields are displayed in.  See the
+// FieldSort option of ConfigState.
+type FieldOrder int
+
+const (
+	// DeclarationFieldOrder displays struct fields in the order they are
+	// declared.  This is the default.
+	DeclarationFieldOrder FieldOrder = iota
+
+	// NameFieldOrder sorts struct fields alphabetically by name.
+	NameFieldOrder
+
+	// TypeThenNameFieldOrder groups struct fields by the name of their
+	// declared type, in alphabetical order, and sorts the fields of each
+	// group alphabetically by name.
+	TypeThenNameFieldOrder
+)
+
+// BytesEncoding specifies how byte arrays and slices are displayed.  See the
+// BytesEncoding option of ConfigState.
+type BytesEncoding int
+
+const (
+	// HexDumpBytesEncoding displays bytes like the hexdump -C command.
+	// This is the default.
+	HexDumpBytesEncoding BytesEncoding = iota
+
+	// Base64BytesEncoding displays bytes as a quoted string in the standard
+	// base64 encoding with a (base64) annotation, such as (base64) "AQID".
+	Base64BytesEncoding
+
+	// HexStringBytesEncoding displays bytes as a quoted string of hex digits
+	// with a (hex) annotation, such as (hex) "010203".
+	HexStringBytesEncoding
+
+	// StringBytesEncoding displays bytes as a quoted Go string, such as
+	// "abc", with any bytes which aren't printable escaped.
+	StringBytesEncoding
+)
+
+// UnexportedPolicy specifies which unexported struct fields are displayed.  See
+// the UnexportedPolicy option of ConfigState.
+type UnexportedPolicy int
+
+const (
+	// UnexportedAll displays the unexported fields of all types.  This is
+	// the default.
+	UnexportedAll UnexportedPolicy = iota
+
+	// UnexportedNone hides the unexported fields of all types exactly the
+	// same as the ExportedOnly option.
+	UnexportedNone
+
+	// UnexportedOwnPackagesOnly displays the unexported fields of types
+	// defined in the packages whose import paths start with one of the
+	// prefixes of the OwnPackages option and hides those of all other
+	// types, such as the types of the standard library.
+	UnexportedOwnPackagesOnly
 )
 
 // ConfigState houses the configuration options used by spew to format and
@@ -81,19 +177,636 @@ type ConfigState struct {
 	// this to have a more deterministic, diffable output.  Note that only
 	// native types (bool, int, uint, floats, uintptr and string) and types
 	// that support the error or Stringer interfaces (if methods are
-	// enabled) are supported, with other types sorted according to the
-	// reflect.Value.String() output which guarantees display stability.
+	// enabled) are supported, with other types spewed to strings and sorted
+	// by those strings which guarantees display stability.
 	SortKeys bool
 
 	// SpewKeys specifies that, as a last resort attempt, map keys should
 	// be spewed to strings and sorted by those strings.  This is only
-	// considered if SortKeys is true.
+	// considered if SortKeys is true.  Since SortKeys now always falls back
+	// to sorting by the spewed strings, it no longer has any effect and is
+	// only retained for compatibility.
 	SpewKeys bool
+
+	// FormatDurations specifies that time.Duration values should be displayed
+	// in their human readable form, such as 1h30m0s, rather than as the raw
+	// number of nanoseconds.  This applies even when method invocation is
+	// disabled via the DisableMethods option.  The global config instance
+	// and NewDefaultConfig enable this by default.
+	FormatDurations bool
+
+	// MaxCycleRevisits controls the maximum number of circular references
+	// that will be displayed before the dump or format operation is aborted
+	// altogether with a "... (too many cycles)" marker.  This protects
+	// against pathological data structures with a large number of mutual
+	// cycles.  The default, 0, means there is no limit.
+	MaxCycleRevisits int
+
+	// FieldNameFilter specifies a regular expression that struct field names
+	// must match in order to be displayed.  Fields which don't match are
+	// still displayed when they contain a matching field somewhere beneath
+	// them so the nesting needed to reach the matching fields is preserved,
+	// but they are omitted entirely otherwise.  Once a field matches, it is
+	// displayed in full.  The default, nil, means all fields are displayed.
+	FieldNameFilter *regexp.Regexp
+
+	// DetectSliceAliasing specifies whether or not to keep track of the
+	// backing arrays of the slices that are dumped in order to annotate
+	// slices which overlap the backing array of a previously dumped slice
+	// with "[shares backing with #N]", where N is the position of that
+	// slice, starting at 1, in the order slices were encountered during the
+	// dump.  This is disabled by default since it requires keeping track of
+	// every slice.  It only applies to Dump style output.
+	DetectSliceAliasing bool
+
+	// CompactSmallMaps specifies the maximum number of entries a map with
+	// scalar keys and values (bools, numbers, and strings) may have in order
+	// to be displayed inline, such as {a:1 b:2}, rather than in the normal
+	// block form.  The keys and values are displayed the same as they are
+	// with the %v verb of the custom formatter and honor the SortKeys option.
+	// The default, 0, means maps are always displayed in block form.  It only
+	// applies to Dump style output.
+	CompactSmallMaps int
+
+	// MaxElements controls the maximum number of elements of arrays, slices,
+	// maps, and expanded iterators to display.  Any remaining elements are
+	// replaced with a "... (N more)" marker, or simply "..." for iterators
+	// since their length is unknown.  Byte arrays and slices which are
+	// displayed as a hexdump are not affected.  The default, 0, means there
+	// is no limit.
+	MaxElements int
+
+	// ExpandIterators specifies whether or not to display the elements of
+	// the sequence provided by types which implement the SpewIterator
+	// interface rather than their internals.  Since iterators might never
+	// end, MaxElements should be set when dumping them unless they are known
+	// to be finite.  Iterators require Go 1.23 or newer and this option has
+	// no effect when built with older versions.
+	ExpandIterators bool
+
+	// NoTrailingNewline specifies whether or not to suppress the newline
+	// which Dump style output normally emits after the final value.  The
+	// newlines which separate multiple values are not affected.
+	NoTrailingNewline bool
+
+	// ResolveFuncNames specifies whether or not to display function values
+	// by their fully qualified name, such as main.doThing, instead of their
+	// address.  Closures are named after their enclosing function along with
+	// a sequence number, such as main.doThing.func1.  This is disabled by
+	// default since resolving the names is relatively expensive.
+	ResolveFuncNames bool
+
+	// ExpandProtoMessages specifies whether or not to display generated
+	// protobuf messages by their logical fields alone.  The internal
+	// bookkeeping fields named state, sizeCache, and unknownFields are
+	// skipped and the String method of the message is not invoked.
+	// Messages are detected by their pointer having the Reset, String, and
+	// ProtoReflect methods of the proto.Message interface, so the protobuf
+	// package is not required.
+	ExpandProtoMessages bool
+
+	// Transform specifies a function which is given a chance to replace
+	// every value just before it is displayed, such as to round floats or
+	// normalize timestamps for deterministic output.  When it returns true,
+	// the returned value is displayed instead of the original one, or
+	// "<omitted>" is displayed when the returned value is the zero Value.
+	// It is invoked before any type information is displayed and before the
+	// error and Stringer interfaces are checked, so those apply to the
+	// returned value.  For pointers, it is invoked for the pointer as well
+	// as the value it points to once the pointer type has been displayed.
+	Transform func(v reflect.Value) (reflect.Value, bool)
+
+	// MarkEmbedded specifies whether or not to flag embedded (anonymous)
+	// struct fields with an "(embedded)" marker before their name, or
+	// "(embedded *T)" for embedded pointers, in order to make it clear
+	// where promoted fields and methods come from.  It only applies to Dump
+	// style output.
+	MarkEmbedded bool
+
+	// MapKeyOrder specifies the order map keys are displayed in.  The
+	// default, HashKeyOrder, is the unstable iteration order of the runtime
+	// unless SortKeys is set.  SortedKeyOrder is the same as setting
+	// SortKeys.  StableKeyOrder sorts keys by a hash of their rendered form
+	// to guarantee deterministic output even for key types SortKeys can't
+	// order meaningfully.
+	MapKeyOrder KeyOrder
+
+	// OmitEmptyContainers specifies whether or not empty, but non-nil,
+	// arrays, slices, and maps should be displayed tersely as [] and {} on
+	// the same line instead of as an empty multi-line block.  Nil slices and
+	// maps are still displayed as <nil>.  It only applies to Dump style
+	// output since the custom formatter already displays them inline.
+	OmitEmptyContainers bool
+
+	// DebugSpew specifies whether or not to annotate each displayed value
+	// with an inline comment, such as /* via Stringer */, naming the code
+	// path which produced it.  The paths are error, Stringer, Transform,
+	// FormatDurations, SpewIterator, and reflection.  This is useful for
+	// diagnosing why a type is displayed unexpectedly.
+	DebugSpew bool
+
+	// MaxHexDumpBytes specifies the maximum number of bytes displayed in the
+	// hexdump of byte arrays and slices.  The remaining bytes are replaced
+	// by a "... (N more)" marker.  The offsets and ASCII gutter of the
+	// displayed bytes are unaffected.  The default, 0, means there is no
+	// limit.
+	MaxHexDumpBytes int
+
+	// TypeNameFunc specifies a function which overrides the name displayed
+	// in the type annotation of every value, such as to present values in
+	// domain vocabulary rather than that of Go.  It is also consulted for
+	// the key and element types of unnamed types such as pointers, slices,
+	// and maps.  Returning an empty string falls back to the default name.
+	TypeNameFunc func(t reflect.Type) string
+
+	// VerboseFloats specifies whether or not float32 and float64 values
+	// should be displayed in a way that distinguishes the values standard
+	// formatting hides, which is useful for debugging numeric code.
+	// Negative zero is displayed as -0.0, NaNs are displayed with their bit
+	// pattern such as NaN(0x7ff8000000000001), and subnormal values are
+	// suffixed with (subnormal).
+	VerboseFloats bool
+
+	// RunLengthEncode specifies whether or not runs of consecutive array
+	// and slice elements with identical output should be collapsed into a
+	// single element followed by the length of the run, such as
+	// (int) 0 (×1024).  This greatly shrinks dumps of mostly zero buffers.
+	// Only runs of at least three elements are collapsed.  It only applies
+	// to Dump style output.
+	RunLengthEncode bool
+
+	// StructStyle specifies how structs are displayed.  The default,
+	// BlockStructStyle, is the normal multi-line block in Dump style output.
+	// KeyValueStructStyle displays each struct, including nested ones, on a
+	// single line as key=value pairs, such as Foo{flag=flagTwo data=<nil>},
+	// which is more log friendly.  Field values are displayed the same as
+	// the %v verb of the custom formatter.
+	StructStyle StructStyle
+
+	// MapKVSeparator specifies the separator between map keys and values,
+	// such as " => " or " = ", for matching an external format.  It applies
+	// to all map and iterator output, including inline maps.  The default,
+	// an empty string, means ": " is used in Dump style output and ":" is
+	// used inline.
+	MapKVSeparator string
+
+	// CollapsePointerChains specifies whether or not chains of multiple
+	// pointers to a single value should be displayed without their
+	// addresses, such as (***int) ->5, in Dump style output.  The collapse
+	// is broken, and the addresses displayed as usual, when any pointer in
+	// the chain is shared with another part of the dumped value.
+	CollapsePointerChains bool
+
+	// GroupDigits specifies whether or not to separate every group of three
+	// digits of integer values, such as 1,234,567, for readability.  Only
+	// the integer part of floating point values is grouped.  It does not
+	// apply to values displayed in hexadecimal such as pointers.
+	GroupDigits bool
+
+	// DigitSeparator specifies the separator inserted between groups of
+	// digits by the GroupDigits option.  The default, an empty string,
+	// means a comma is used.
+	DigitSeparator string
+
+	// AutoFlush specifies whether or not writers which implement a
+	// Flush() error method, such as bufio.Writer, should be flushed once
+	// the output of Fdump and the like has been written to avoid losing
+	// buffered output.  Any flush error is returned by FdumpN.
+	AutoFlush bool
+
+	// HighlightHeterogeneous specifies whether or not the dynamic type of
+	// each element of arrays, slices, and maps whose element type is an
+	// interface, such as []interface{}, should be displayed prominently on
+	// its own line before the element.  This helps spot unexpected types in
+	// a supposedly uniform collection.  It only applies to Dump style
+	// output.
+	HighlightHeterogeneous bool
+
+	// MaxMapDepth specifies the maximum number of levels of nested maps to
+	// descend into independently of MaxDepth, which is useful for clipping
+	// arbitrarily deep decoded JSON while still fully expanding structs.
+	// Maps nested deeper are displayed as {... (N more)}, or
+	// map[... (N more)] inline.  The default, 0, means only MaxDepth
+	// applies.
+	MaxMapDepth int
+
+	// ExportedOnly specifies whether or not to hide the unexported fields
+	// of structs, which guards against accidentally leaking secrets held in
+	// private state.  Specific struct types may be opted back into having
+	// their unexported fields displayed via AllowUnexported.  The global
+	// config instance and NewDefaultConfig enable this by default.
+	ExportedOnly bool
+
+	// ShowRunes specifies whether or not rune values should be displayed as
+	// a quoted character followed by their numeric value, such as 'A' (65),
+	// which is helpful when debugging text processing.  Non-printable runes
+	// are displayed as \u escapes.  Since rune is an alias for int32, this
+	// applies to all values of the predeclared int32 type, but not to named
+	// types such as type Code int32.
+	ShowRunes bool
+
+	// EventSink specifies a function which is called for each rendering
+	// decision, such as entering a value, using a Stringer, truncating due
+	// to a limit, or detecting a circular reference.  It is primarily
+	// intended for testing and inspecting tools built on top of spew.  The
+	// events are emitted in the same order as the output, so they are
+	// deterministic whenever the output is, such as when SortKeys is set.
+	EventSink func(ev Event)
+
+	// MaxLineWidth specifies the maximum width of the lines of dump output
+	// before they are wrapped onto continuation lines which are indented
+	// one level deeper.  This is mainly useful to keep long inline values,
+	// such as those produced by CompactSmallMaps or KeyValueStructStyle,
+	// within the width of a terminal without truncating any data.  Lines
+	// are only broken at spaces outside of quoted strings and hex dumps are
+	// never wrapped.  A zero value disables wrapping.
+	MaxLineWidth int
+
+	// QualifiedNilPointers specifies whether or not nil pointers should be
+	// displayed along with their type in the same form as the %#v verb of
+	// the fmt package, such as (*Foo)(nil), instead of <nil>.  This makes
+	// nil pointers easy to tell apart from non-nil ones, such as
+	// (*Foo)(0xf84002f060), when scanning the output.
+	QualifiedNilPointers bool
+
+	// KeyConfig specifies the configuration used to display map keys while
+	// this configuration is used to display map values.  This allows dense
+	// dumps where keys are displayed compactly, for example with methods
+	// enabled and pointer methods disabled, while values are fully expanded,
+	// or vice versa.  The Indent option of this configuration is always used
+	// so keys line up with their values.  A nil value displays keys with the
+	// same configuration as values.
+	KeyConfig *ConfigState
+
+	// ASCIIOnly specifies whether or not the output should be guaranteed to
+	// only contain ASCII characters, which prevents garbled output in
+	// environments which mangle Unicode, such as some CI log viewers.  All
+	// non-ASCII characters, including those in strings, Stringer output,
+	// type names, and the Indent option, are escaped as \u or \U escapes
+	// and markers which normally use non-ASCII characters, such as the run
+	// lengths of the RunLengthEncode option, use ASCII alternatives.
+	ASCIIOnly bool
+
+	// StableAddresses specifies whether or not pointer addresses should be
+	// replaced by fake sequential addresses, such as 0x1 and 0x2, which are
+	// assigned to each distinct address in the order they are encountered.
+	// This makes the output reproducible for golden tests while preserving
+	// whether two pointers are the same or different.  The fake addresses
+	// are shared by all of the arguments of a single call.
+	StableAddresses bool
+
+	// UseValuer specifies whether or not types which implement the
+	// driver.Valuer interface, such as sql.NullString and sql.NullInt64,
+	// should be displayed as the result of their Value method along with a
+	// (valuer) annotation instead of their fields.  Nil results are
+	// displayed as <null>.  Types whose Value method returns an error are
+	// displayed normally.
+	UseValuer bool
+
+	// Ellipsis specifies the marker used for all truncated output.  Every
+	// truncation marker, including those of the MaxElements, MaxStringLen,
+	// MaxHexDumpBytes, MaxDepth, and MaxMapDepth options, consistently uses
+	// the form "<ellipsis> (N more)" with the number of elements which were
+	// not displayed, or only the ellipsis when the number isn't known, such
+	// as for iterators.  This makes truncation reliable to detect by tools
+	// consuming the output.  The global Config uses "...", which is also
+	// used when it is empty.
+	Ellipsis string
+
+	// ShowRuntimeState specifies whether or not runtime state which is
+	// useful for debugging garbage collection issues should be displayed
+	// on a best-effort basis.  Currently, weak pointers from the weak
+	// package of the standard library, such as weak.Pointer[T], are
+	// displayed as the value they point to, or <collected> once it has been
+	// garbage collected.  The runtime doesn't provide a way to query whether
+	// values have finalizers or cleanups attached, so those are not shown.
+	ShowRuntimeState bool
+
+	// MarkNilMapValues specifies whether or not map values which are nil
+	// pointers or nil interfaces should be displayed as <nil value> so
+	// entries which are present but hold nil stand out from absent keys.
+	MarkNilMapValues bool
+
+	// SdumpSizeHint specifies the number of bytes to pre-allocate for the
+	// buffer used by Sdump, Bdump, and SafeSdump.  Setting it to roughly the
+	// size of the expected output avoids repeatedly growing the buffer when
+	// large values are dumped.  The default of 0 grows the buffer as needed.
+	SdumpSizeHint int
+
+	// HashNodes specifies whether or not Dump should annotate each struct,
+	// array, slice, and map with a hash of its rendered content, such as
+	// [hash=3f2a9c0d1e7b4a65], so identical substructures can be found
+	// within and across dumps.  The hash is a fast non-cryptographic FNV-1a
+	// hash which is only intended for comparison and not for security.
+	// Pointer addresses are part of the content, so enable StableAddresses
+	// to compare dumps taken at different times.  The content of every
+	// node is buffered until its hash is known, which delays the output of
+	// large values until they have been rendered entirely.
+	HashNodes bool
+
+	// DocTag specifies the name of a struct tag, such as doc, whose value
+	// describes the field.  When set, Dump displays the value of the tag
+	// as a trailing comment after each field which has it, such as
+	// Timeout: (int) 30, // connection timeout in seconds.  Fields without
+	// the tag are displayed as usual.  The default of an empty string
+	// disables the comments.
+	DocTag string
+
+	// FlattenPointers specifies whether or not Dump and the custom formatter
+	// should display the values pointers point to directly, along with the
+	// type of the value, instead of the pointer types and addresses.  Values
+	// which are referenced by more than one pointer are labeled, such as #1,
+	// the first time they are displayed and displayed as <seen #1>
+	// afterwards so aliasing and circular references remain visible.  Both
+	// output paths assign the same labels to the same values.  Nil pointers
+	// are displayed as usual.
+	FlattenPointers bool
+
+	// TimeLayout specifies the layout, in the form accepted by
+	// time.Time.Format, used to display time.Time values, including those
+	// behind pointers such as *time.Time.  This applies even when method
+	// invocation is disabled via the DisableMethods option.  An empty layout
+	// disables the special handling of times.  The global config instance
+	// and NewDefaultConfig use time.RFC3339Nano by default.
+	TimeLayout string
+
+	// IncludeCaller specifies whether or not Dump and its variants should
+	// precede their output with the file name and line number of the call
+	// site, such as main.go:42:, on a line of its own.  This helps to
+	// locate which call produced which output in busy logs.
+	IncludeCaller bool
+
+	// ShowSliceIndices specifies whether or not Dump should precede each
+	// array and slice element with its index, such as [ 7], right aligned
+	// to the width of the largest index shown.  Runs of identical elements
+	// collapsed by the RunLengthEncode option are labeled with the index of
+	// their first element.
+	ShowSliceIndices bool
+
+	// ShowBothMethodAndInternals specifies whether or not the quoted output
+	// of error and Stringer methods should be displayed followed by the
+	// internals of the value, such as (Foo) "stringer output" {...}, for
+	// full visibility when a method hides a bug in the internals.  It
+	// supersedes ContinueOnMethod, which also displays the internals but
+	// with the method output in parentheses instead, so enabling both
+	// displays the quoted form.  Like ContinueOnMethod, it has no effect if
+	// method invocation is disabled via the DisableMethods option.
+	ShowBothMethodAndInternals bool
+
+	// UnexportedPolicy specifies which unexported struct fields are
+	// displayed.  The default, UnexportedAll, displays all of them, while
+	// UnexportedOwnPackagesOnly only displays those of types defined in the
+	// packages named by OwnPackages so the implementation details of the
+	// standard library and other dependencies don't clutter the output.
+	// Fields hidden by either this or the ExportedOnly option are hidden,
+	// and types registered via AllowUnexported are exempt from both, so
+	// ExportedOnly must be disabled on the global config instance for this
+	// option to display any unexported fields there.
+	UnexportedPolicy UnexportedPolicy
+
+	// OwnPackages specifies the import path prefixes, such as
+	// github.com/me/project/, of the packages whose unexported fields are
+	// displayed when UnexportedPolicy is UnexportedOwnPackagesOnly.
+	OwnPackages []string
+
+	// CollapseRepeatedTypes specifies whether or not Dump should omit the
+	// type annotations of array and slice elements when they all share the
+	// same type, which the type of the container already conveys.  The
+	// common type of interface elements, such as (elem=int), is displayed
+	// once on the container instead.  Containers of interface elements
+	// holding differing types or nil keep their annotations, as do pointer
+	// elements since their addresses are part of the annotation.
+	CollapseRepeatedTypes bool
+
+	// MaxStringLen specifies the maximum number of bytes of text displayed
+	// for each string value as well as for the output of error and Stringer
+	// methods, so a misbehaving method can't flood the output any more than
+	// a long string can.  Longer text is cut off at a character boundary and
+	// followed by the same marker as MaxElements with the number of bytes
+	// which were not displayed, such as "... (90 more)".  The default of 0
+	// means there is no limit.
+	MaxStringLen int
+
+	// ExpandImageTypes specifies whether or not geometry and color types of
+	// the image and image/color packages should be displayed compactly.
+	// An image.Point is displayed as (0,0), an image.Rectangle as
+	// (0,0)-(640,480), and RGBA and NRGBA colors in hex notation, such as
+	// #FF8000FF, or #FFFF80000000FFFF for their 64-bit variants.  This
+	// applies even when method invocation is disabled via the
+	// DisableMethods option.
+	ExpandImageTypes bool
+
+	// WriterWrapper, when set, is called with the destination writer of
+	// each Dump, Fdump, and Sdump call and the dump is written through the
+	// writer it returns instead, such as one which prefixes each line or
+	// mirrors the output to a log.  It is applied after all other output
+	// options.  When AutoFlush is enabled, the returned writer is flushed
+	// ahead of the destination if it is buffered, and write errors it
+	// returns are reported just like those of the destination.  The default
+	// of nil writes to the destination directly.
+	WriterWrapper func(io.Writer) io.Writer
+
+	// SideBySideWidth specifies the width of each column displayed by
+	// FdumpSideBySide and SdumpSideBySide in characters.  Longer lines are
+	// cut off with an ellipsis.  The default of 0 uses a width of 40.
+	SideBySideWidth int
+
+	// ShowContainerCounts specifies whether or not Dump should display the
+	// element counts of arrays, maps, and slices along with their type in
+	// the opening line, such as ([]int len=1000 cap=1024), rather than in a
+	// separate annotation.  The counts are displayed even when they are zero
+	// so the size of a container is known before reading its elements.
+	ShowContainerCounts bool
+
+	// FieldSort specifies the order struct fields are displayed in.  The
+	// default, DeclarationFieldOrder, is the order they are declared in.
+	// NameFieldOrder sorts them by name and TypeThenNameFieldOrder groups
+	// them by their declared type and sorts each group by name, which keeps
+	// related fields together in dumps of large configuration structs.
+	FieldSort FieldOrder
+
+	// BytesEncoding specifies how Dump displays byte arrays and slices.  The
+	// default, HexDumpBytesEncoding, displays them as a hexdump.  The other
+	// encodings display them on a single line, which suits values destined
+	// for JSON and web contexts.  The MaxHexDumpBytes option limits the
+	// number of bytes displayed in all encodings.
+	BytesEncoding BytesEncoding
+
+	// PrintLegend specifies whether or not Dump should append a legend which
+	// explains the special markers that appear in the output, such as <nil>
+	// and <already shown>, for readers unfamiliar with them.  Only the
+	// markers which were actually used are listed and nothing is appended
+	// when there are none.
+	PrintLegend bool
+
+	// PointerTrackLimit specifies the maximum number of distinct pointers
+	// tracked at once in order to detect circular references.  Once the
+	// limit is reached, further pointers are not tracked, which bounds the
+	// memory used for cycle detection on enormous acyclic graphs, such as
+	// very long linked lists, at the cost of missing cycles through the
+	// untracked pointers.  Setting MaxDepth along with it bounds how far a
+	// missed cycle is followed.  The default of 0 means there is no limit.
+	PointerTrackLimit int
+
+	// RuneSlicesAsString specifies whether or not arrays and slices of runes
+	// should be displayed as the text they represent rather than as a list
+	// of numbers.  Dump displays them as a quoted string along with a note,
+	// such as ([]rune as string) "héllo".  Since rune is an alias for int32,
+	// this applies to all arrays and slices of the predeclared int32 type,
+	// but not to those of named types such as type Code int32.
+	RuneSlicesAsString bool
+
+	// ShowMethodSet specifies whether or not Dump should list the exported
+	// methods of each struct along with its fields, one per line as comments
+	// with their signatures.  Methods of the type itself are listed with a
+	// value receiver, such as // func (T) String() string, followed by those
+	// only available via a pointer with a pointer receiver, which helps to
+	// diagnose why a value does or doesn't satisfy an interface.  It is
+	// verbose, so it is best reserved for debugging.
+	ShowMethodSet bool
+
+	// KindHandlers specifies functions which return the text displayed for
+	// all values of a kind, such as every reflect.Float64 rounded to two
+	// decimals, instead of the default rendering.  Since it is coarser,
+	// the handling of individual types takes precedence over it, which
+	// includes types registered via RegisterBitflags, those displayed by
+	// options for specific types such as FormatDurations, and those with
+	// error or Stringer methods.  The default of nil uses the default
+	// rendering for all kinds.
+	KindHandlers map[reflect.Kind]func(reflect.Value) string
+
+	// DerefMapKeys specifies whether or not map keys which are pointers
+	// should be displayed by the values they point to alone, without their
+	// addresses, which are meaningless to readers of dumps of maps such as
+	// caches keyed by pointers.  Nil keys are displayed as usual.  When
+	// SortKeys is set as well, the keys are sorted by the values they point
+	// to rather than by their addresses, regardless of SpewKeys.
+	DerefMapKeys bool
+
+	// StringerAtMaxDepth specifies whether or not structs, arrays, slices,
+	// and maps whose contents are cut off by the MaxDepth option should be
+	// displayed via their error or Stringer method alone, when they have
+	// one, even when ContinueOnMethod or ShowBothMethodAndInternals is set.
+	// The method output summarizes the value far better than the max depth
+	// marker.  It only applies at the depth boundary since values within it
+	// are expanded as usual.  The global config instance and
+	// NewDefaultConfig enable this by default.
+	StringerAtMaxDepth bool
+
+	// ExpandNetAddrs specifies whether or not the address types of the net
+	// and net/url packages, net.IP, net.IPNet, net.HardwareAddr, and
+	// url.URL, should always be displayed via their String methods, such as
+	// 10.0.0.1 or 01:02:03:04:05:06, without a length annotation and even
+	// when ContinueOnMethod is set, rather than as the bytes or fields they
+	// are made of.  It has no effect when method invocation is disabled via
+	// the DisableMethods option.
+	ExpandNetAddrs bool
+
+	// FloatFormat specifies the format float32 and float64 values, as well
+	// as the real and imaginary parts of complex values, are displayed in.
+	// It accepts the same formats as strconv.FormatFloat, such as 'e' to
+	// force scientific notation or 'f' to force fixed notation, and always
+	// uses the smallest number of digits which represents the value
+	// exactly.  The default of 0, as well as any unsupported format, uses
+	// 'g', which switches to scientific notation for large exponents.
+	FloatFormat byte
+
+	// LabelArgs specifies whether or not each top-level argument of Dump and
+	// the Printf family of functions, such as Printf and Sprint, should be
+	// preceded by a label of its index, such as arg[0]:, on a line of its
+	// own, so the arguments of a single call can be told apart in logs.
+	// Formatters created via NewFormatter are not labeled.
+	LabelArgs bool
+
+	// DumpChannelContents specifies whether or not Dump should display the
+	// elements buffered in bidirectional channels in a block after their
+	// addresses, the same as the elements of slices, rather than just their
+	// addresses.  Go provides no way to peek at the elements, so they are
+	// received from the channel and sent back in the same order, which is
+	// only safe when no other goroutine uses the channel at the same time,
+	// such as when inspecting a stopped pipeline.  Elements which can't be
+	// sent back, including all of those of closed channels, are lost and
+	// counted by a <N not restored> marker.  Since this modifies the
+	// channels being dumped, it only has an effect when UnsafeChannelDrain
+	// is set as well.
+	DumpChannelContents bool
+
+	// UnsafeChannelDrain acknowledges that the DumpChannelContents option
+	// temporarily drains the channels it displays and loses their elements
+	// when other goroutines use them concurrently.  It has no effect on its
+	// own.
+	UnsafeChannelDrain bool
+
+	// ShowTypeChain specifies whether or not Dump should follow the type
+	// annotations of defined types with their underlying type, such as
+	// (main.Celsius -> float64) or (main.IDs -> []int), while structs,
+	// interfaces, and functions are followed by their kind, such as
+	// (main.Config -> struct).  The reflect package only exposes the final
+	// underlying type, so for a chain of definitions such as type A B and
+	// type B int, the intermediate type B can't be shown and the annotation
+	// is (main.A -> int).
+	ShowTypeChain bool
+
+	// FieldNameFunc specifies a function which returns the name a struct
+	// field is displayed with, such as one which strips a prefix used by
+	// generated code.  It applies to embedded fields as well as to the
+	// field names used by the other output formats of this package.
+	// Returning an empty string displays the field with its declared name.
+	// The FieldNameFilter option and field paths always use the declared
+	// names.  The default of nil displays all fields with their declared
+	// names.
+	FieldNameFunc func(field reflect.StructField) string
+
+	// CacheStringerResults specifies whether or not the results of the
+	// error and String methods should be remembered by the pointer they
+	// were called on for the rest of a single dump, so values which are
+	// referenced many times only have their potentially expensive methods
+	// called once.  The results are discarded once the dump is complete.
+	// Since only pointers identify values, methods called on values which
+	// aren't addressable can't be cached.  This is disabled by default since
+	// methods whose results depend on side effects would otherwise display
+	// stale results.
+	CacheStringerResults bool
+
+	// GroupFields specifies whether or not Dump should visually group the
+	// fields of structs by the value of their spewgroup struct tag, such as
+	// spewgroup:"network".  A blank line separates consecutive fields with
+	// different groups and a comment naming the group, such as // network,
+	// precedes the first field of each named group.  Fields without the tag
+	// belong to no group and are displayed as usual.  It only applies to
+	// the block form of structs.
+	GroupFields bool
+
+	// CycleMarker specifies the marker displayed in place of circular
+	// references, such as <-- cycle, instead of <already shown> in Dump
+	// style output and <shown> in the output of the custom formatter.  The
+	// traversal stops at circular references regardless of the marker.  The
+	// default of an empty string uses the standard markers.
+	CycleMarker string
+
+	// CycleMarkerFunc specifies a function which returns the marker
+	// displayed in place of the circular reference with the passed label,
+	// which is the position of the circular reference among those
+	// encountered so far during the dump, starting at 1.  It takes
+	// precedence over the CycleMarker option unless it returns an empty
+	// string.  The default of nil uses the CycleMarker option.
+	CycleMarkerFunc func(label int) string
+
+	// allowUnexported houses the struct types whose unexported fields are
+	// displayed even when ExportedOnly is set or UnexportedPolicy hides
+	// them.  See AllowUnexported.
+	allowUnexported map[reflect.Type]bool
+
+	// bitflags houses the names of the flags of the bitmask types which are
+	// decomposed into their set flags.  See RegisterBitflags.
+	bitflags map[reflect.Type]map[uint64]string
 }
 
 // Config is the active configuration of the top-level functions.
 // The configuration can be changed by modifying the contents of spew.Config.
-var Config = ConfigState{Indent: " "}
+var Config = ConfigState{Indent: " ", FormatDurations: true,
+	TimeLayout: time.RFC3339Nano, StringerAtMaxDepth: true, Ellipsis: "...",
+	ExportedOnly: true}
 
 // Errorf is a wrapper for fmt.Errorf that treats each argument as if it were
 // passed with a Formatter interface returned by c.NewFormatter.  It returns
@@ -224,6 +937,13 @@ standard fmt package for formatting.  In addition, the custom formatter ignores
 the width and precision arguments (however they will still work on the format
 specifiers not handled by the custom formatter).
 
+The - flag may be combined with any of these, such as %-v or %-#v, to force map
+keys to be sorted for that call as if the SortKeys option were set.
+
+The space flag, as in % v, instead produces the full multi-line indented output
+of Dump, without the trailing newline, so it can be used inline in a Printf
+call.  It may be combined with the - flag, as in %- v, to sort map keys.
+
 Typically this function shouldn't be called directly.  It is much easier to make
 use of the custom formatter by calling one of the convenience functions such as
 c.Printf, c.Println, or c.Printf.
@@ -232,12 +952,59 @@ func (c *ConfigState) NewFormatter(v interface{}) fmt.Formatter {
 	return newFormatter(c, v)
 }
 
+// AllowUnexported registers the struct types of the passed values, such as
+// MyStruct{}, as types whose unexported fields are displayed even when the
+// ExportedOnly option is set or the UnexportedPolicy option hides them.
+// Pointers to structs register the struct type they point to.  It is not safe
+// to call concurrently with dumping or formatting, so it should typically be
+// called during initialization.
+func (c *ConfigState) AllowUnexported(types ...interface{}) {
+	if c.allowUnexported == nil {
+		c.allowUnexported = make(map[reflect.Type]bool)
+	}
+	for _, t := range types {
+		rt := reflect.TypeOf(t)
+		if rt == nil {
+			continue
+		}
+		if rt.Kind() == reflect.Ptr {
+			rt = rt.Elem()
+		}
+		c.allowUnexported[rt] = true
+	}
+}
+
+// RegisterBitflags registers the passed integer type as a bitmask whose values
+// are displayed as the names of the flags which are set, such as
+// flagA|flagC (0b101), rather than as an opaque number.  The names map the
+// value of each flag to its name.  Any set bits which don't belong to a named
+// flag are displayed in hex.  Registered bitmasks take precedence over error
+// and Stringer methods since those typically only handle single flags.  It is
+// not safe to call concurrently with dumping or formatting, so it should
+// typically be called during initialization.
+func (c *ConfigState) RegisterBitflags(t reflect.Type, names map[uint64]string) {
+	if c.bitflags == nil {
+		c.bitflags = make(map[reflect.Type]map[uint64]string)
+	}
+	c.bitflags[t] = names
+}
+
 // Fdump formats and displays the passed arguments to io.Writer w.  It formats
-// exactly the same as Dump.
+// exactly the same as Dump.  Buffered writers, such as bufio.Writer, are not
+// flushed unless the AutoFlush option is enabled.
 func (c *ConfigState) Fdump(w io.Writer, a ...interface{}) {
 	fdump(c, w, a...)
 }
 
+// FdumpN formats and displays the passed arguments to io.Writer w.  It formats
+// exactly the same as Dump.  It returns the number of bytes written and the
+// first write error encountered, at which point the dump is stopped.  Writers
+// which accept only part of a write without an error are passed the remaining
+// bytes again until they have accepted all of them.
+func (c *ConfigState) FdumpN(w io.Writer, a ...interface{}) (n int, err error) {
+	return fdump(c, w, a...)
+}
+
 /*
 Dump displays the passed parameters to standard out with newlines, customizable
 indentation, and additional debug information such as complete types and all
@@ -268,18 +1035,64 @@ func (c *ConfigState) Dump(a ...interface{}) {
 // Sdump returns a string with the passed arguments formatted exactly the same
 // as Dump.
 func (c *ConfigState) Sdump(a ...interface{}) string {
-	var buf bytes.Buffer
-	fdump(c, &buf, a...)
-	return buf.String()
+	return sdump(c, a...)
+}
+
+// Bdump returns a byte slice with the passed arguments formatted exactly the
+// same as Dump.  See the package level Bdump for details.
+func (c *ConfigState) Bdump(a ...interface{}) []byte {
+	return bdump(c, a...)
+}
+
+// SafeSdump returns a string with the passed argument formatted exactly the
+// same as Dump, but it never panics.  Any panic raised while dumping is
+// recovered and returned separately while the output produced up to that point
+// is terminated with a <dump aborted: ...> marker.  See the package level
+// SafeSdump for details.
+func (c *ConfigState) SafeSdump(v interface{}) (out string, recovered interface{}) {
+	return safeSdump(c, v)
+}
+
+// FdumpTo formats the passed arguments exactly the same as Dump and appends the
+// result to the passed strings.Builder.  This avoids the intermediate copy
+// made by Sdump when accumulating the output of several dumps.
+func (c *ConfigState) FdumpTo(sb *strings.Builder, a ...interface{}) {
+	fdump(c, sb, a...)
+}
+
+// FdumpBytes displays the raw memory representation of the value the passed
+// pointer points to like the hexdump -C command to io.Writer w.  See DumpBytes
+// for details.
+func (c *ConfigState) FdumpBytes(w io.Writer, v interface{}) {
+	fdumpBytes(c, w, v)
+}
+
+// SdumpBytes returns a string with the raw memory representation of the value
+// the passed pointer points to formatted exactly the same as DumpBytes.
+func (c *ConfigState) SdumpBytes(v interface{}) string {
+	return sdumpBytes(c, v)
+}
+
+// DumpBytes displays the raw memory representation of the value the passed
+// pointer points to like the hexdump -C command to standard out.  See the
+// package level DumpBytes for details.
+func (c *ConfigState) DumpBytes(v interface{}) {
+	fdumpBytes(c, os.Stdout, v)
 }
 
 // convertArgs accepts a slice of arguments and returns a slice of the same
 // length with each argument converted to a spew Formatter interface using
 // the ConfigState associated with s.
 func (c *ConfigState) convertArgs(args []interface{}) (formatters []interface{}) {
+	// All of the arguments share the same fake addresses so the StableAddresses
+	// option distinguishes pointers across arguments.
+	fakeAddrs := make(map[uintptr]uintptr)
 	formatters = make([]interface{}, len(args))
 	for index, arg := range args {
-		formatters[index] = newFormatter(c, arg)
+		fs := newFormatter(c, arg).(*formatState)
+		fs.fakeAddrs = fakeAddrs
+		fs.argIndex = index
+		formatters[index] = fs
 	}
 	return formatters
 }
@@ -292,6 +1105,138 @@ func (c *ConfigState) convertArgs(args []interface{}) (formatters []interface{})
 // 	DisablePointerMethods: false
 // 	ContinueOnMethod: false
 // 	SortKeys: false
+// 	FormatDurations: true
+// 	MaxCycleRevisits: 0
+// 	FieldNameFilter: nil
+// 	DetectSliceAliasing: false
+// 	CompactSmallMaps: 0
+// 	MaxElements: 0
+// 	ExpandIterators: false
+// 	NoTrailingNewline: false
+// 	ResolveFuncNames: false
+// 	ExpandProtoMessages: false
+// 	Transform: nil
+// 	MarkEmbedded: false
+// 	MapKeyOrder: HashKeyOrder
+// 	OmitEmptyContainers: false
+// 	DebugSpew: false
+// 	MaxHexDumpBytes: 0
+// 	TypeNameFunc: nil
+// 	VerboseFloats: false
+// 	RunLengthEncode: false
+// 	StructStyle: BlockStructStyle
+// 	MapKVSeparator: ""
+// 	CollapsePointerChains: false
+// 	GroupDigits: false
+// 	DigitSeparator: ""
+// 	AutoFlush: false
+// 	HighlightHeterogeneous: false
+// 	MaxMapDepth: 0
+// 	ExportedOnly: true
+// 	ShowRunes: false
+// 	EventSink: nil
+// 	MaxLineWidth: 0
+// 	QualifiedNilPointers: false
+// 	KeyConfig: nil
+// 	ASCIIOnly: false
+// 	StableAddresses: false
+// 	UseValuer: false
+// 	Ellipsis: "..."
+// 	ShowRuntimeState: false
+// 	MarkNilMapValues: false
+// 	SdumpSizeHint: 0
+// 	HashNodes: false
+// 	DocTag: ""
+// 	FlattenPointers: false
+// 	TimeLayout: time.RFC3339Nano
+// 	IncludeCaller: false
+// 	ShowSliceIndices: false
+// 	ShowBothMethodAndInternals: false
+// 	UnexportedPolicy: UnexportedAll
+// 	OwnPackages: nil
+// 	CollapseRepeatedTypes: false
+// 	MaxStringLen: 0
+// 	ExpandImageTypes: false
+// 	WriterWrapper: nil
+// 	SideBySideWidth: 0
+// 	ShowContainerCounts: false
+// 	FieldSort: DeclarationFieldOrder
+// 	BytesEncoding: HexDumpBytesEncoding
+// 	PrintLegend: false
+// 	PointerTrackLimit: 0
+// 	RuneSlicesAsString: false
+// 	ShowMethodSet: false
+// 	KindHandlers: nil
+// 	DerefMapKeys: false
+// 	StringerAtMaxDepth: true
+// 	ExpandNetAddrs: false
+// 	FloatFormat: 0
+// 	LabelArgs: false
+// 	DumpChannelContents: false
+// 	UnsafeChannelDrain: false
+// 	ShowTypeChain: false
+// 	FieldNameFunc: nil
+// 	CacheStringerResults: false
+// 	GroupFields: false
+// 	CycleMarker: ""
+// 	CycleMarkerFunc: nil
 func NewDefaultConfig() *ConfigState {
-	return &ConfigState{Indent: " "}
+	return &ConfigState{Indent: " ", FormatDurations: true,
+		TimeLayout: time.RFC3339Nano, StringerAtMaxDepth: true,
+		ExportedOnly: true}
+}
+
+// ConfigForGoldenTests returns a ConfigState tuned for output which is compared
+// against golden files and therefore must be identical across runs.  It starts
+// from the settings of NewDefaultConfig and additionally sets:
+//
+// 	SortKeys: true
+// 	StableAddresses: true
+//
+// The returned config can be tweaked further before it is used.
+func ConfigForGoldenTests() *ConfigState {
+	cs := NewDefaultConfig()
+	cs.SortKeys = true
+	cs.StableAddresses = true
+	return cs
+}
+
+// ConfigForLogging returns a ConfigState tuned for writing values to logs,
+// where the output must stay bounded in size no matter how large the values
+// are.  It starts from the settings of NewDefaultConfig and additionally sets:
+//
+// 	SortKeys: true
+// 	MaxDepth: 8
+// 	MaxElements: 64
+// 	MaxStringLen: 256
+// 	NoTrailingNewline: true
+//
+// The returned config can be tweaked further before it is used.
+func ConfigForLogging() *ConfigState {
+	cs := NewDefaultConfig()
+	cs.SortKeys = true
+	cs.MaxDepth = 8
+	cs.MaxElements = 64
+	cs.MaxStringLen = 256
+	cs.NoTrailingNewline = true
+	return cs
+}
+
+// ConfigCompact returns a ConfigState tuned for dumps which use as few lines
+// as possible while still showing everything.  It starts from the settings of
+// NewDefaultConfig and additionally sets:
+//
+// 	StructStyle: KeyValueStructStyle
+// 	CompactSmallMaps: 8
+// 	OmitEmptyContainers: true
+// 	CollapseRepeatedTypes: true
+//
+// The returned config can be tweaked further before it is used.
+func ConfigCompact() *ConfigState {
+	cs := NewDefaultConfig()
+	cs.StructStyle = KeyValueStructStyle
+	cs.CompactSmallMaps = 8
+	cs.OmitEmptyContainers = true
+	cs.CollapseRepeatedTypes = true
+	return cs
 }
@@ -73,6 +73,15 @@ It is also possible to create a ConfigState instance that provides methods
 equivalent to the top-level functions.  This allows concurrent configuration
 options.  See the ConfigState documentation for more details.
 
+Presets for common scenarios are returned by ConfigForGoldenTests,
+ConfigForLogging, and ConfigCompact.  Each of them documents exactly which
+options it sets on top of NewDefaultConfig, and the returned ConfigState can be
+tweaked further:
+
+	cs := spew.ConfigForGoldenTests()
+	cs.MaxDepth = 3
+	golden := cs.Sdump(result)
+
 The following configuration options are available:
 	* Indent
 		String to use for each indentation level for Dump functions.
@@ -100,14 +109,445 @@ The following configuration options are available:
 		this to have a more deterministic, diffable output.  Note that
 		only native types (bool, int, uint, floats, uintptr and string)
 		and types which implement error or Stringer interfaces are
-		supported with other types sorted according to the
-		reflect.Value.String() output which guarantees display
-		stability.  Natural map order is used by default.
+		supported with other types spewed to strings and sorted by
+		those strings which guarantees display stability.  Natural map
+		order is used by default.
 
 	* SpewKeys
 		Specifies that, as a last resort attempt, map keys should be
 		spewed to strings and sorted by those strings.  This is only
-		considered if SortKeys is true.
+		considered if SortKeys is true.  SortKeys now always falls back
+		to the spewed strings, so this is only retained for
+		compatibility.
+
+	* FormatDurations
+		Specifies time.Duration values should be displayed in their human
+		readable form, such as 1h30m0s, even when method invocation is
+		disabled.  Duration formatting is enabled by default.
+
+	* MaxCycleRevisits
+		Maximum number of circular references to display before aborting
+		the dump with a "... (too many cycles)" marker.  There is no limit
+		by default.
+
+	* FieldNameFilter
+		Regular expression struct field names must match in order to be
+		displayed.  Fields which contain a matching field beneath them are
+		still displayed to preserve the nesting needed to reach it, while
+		all other fields are omitted.  All fields are displayed by default.
+
+	* DetectSliceAliasing
+		Annotates slices which overlap the backing array of a previously
+		dumped slice with "[shares backing with #N]", where N is the
+		position of that slice in the order slices were encountered.
+		Only applies to Dump style output.  Aliasing detection is disabled
+		by default.
+
+	* CompactSmallMaps
+		Maximum number of entries a map with scalar keys and values may
+		have in order to be displayed inline, such as {a:1 b:2}, for Dump
+		style output.  Maps are always displayed in block form by default.
+
+	* MaxElements
+		Maximum number of elements of arrays, slices, maps, and expanded
+		iterators to display.  The remaining elements are replaced with a
+		"... (N more)" marker.  There is no limit by default.
+
+	* ExpandIterators
+		Enables displaying the elements of the sequence provided by types
+		which implement the SpewIterator interface instead of their
+		internals.  Requires Go 1.23 or newer.  Iterator expansion is
+		disabled by default.
+
+	* NoTrailingNewline
+		Suppresses the newline Dump style output normally emits after the
+		final value.  The trailing newline is emitted by default.
+
+	* ResolveFuncNames
+		Enables displaying function values by their fully qualified name,
+		such as main.doThing, instead of their address.  Closures are named
+		after their enclosing function along with a sequence number.
+		Function name resolution is disabled by default.
+
+	* ExpandProtoMessages
+		Enables displaying generated protobuf messages by their logical
+		fields alone, skipping the internal state, sizeCache, and
+		unknownFields bookkeeping fields instead of invoking their String
+		method.  Protobuf message expansion is disabled by default.
+
+	* Transform
+		Function which is given a chance to replace every value just
+		before it is displayed.  The returned value is displayed instead
+		when it returns true, or "<omitted>" when the returned value is the
+		zero Value.  It is invoked before type information is displayed
+		and before the error and Stringer interfaces are checked.  No
+		values are transformed by default.
+
+	* MarkEmbedded
+		Flags embedded struct fields with an "(embedded)" marker, or
+		"(embedded *T)" for embedded pointers, in Dump style output.
+		Embedded fields are not flagged by default.
+
+	* MapKeyOrder
+		Specifies the order map keys are displayed in.  HashKeyOrder, the
+		default, is the unstable iteration order of the runtime unless SortKeys
+		is set.  SortedKeyOrder is the same as SortKeys.  StableKeyOrder sorts
+		keys by a hash of their rendered form to guarantee deterministic output
+		even for key types SortKeys can't order meaningfully.
+
+	* OmitEmptyContainers
+		Specifies whether or not empty, but non-nil, arrays, slices, and maps
+		should be displayed tersely as [] and {} on the same line instead of as
+		an empty multi-line block.  Nil slices and maps are still displayed as
+		<nil>.  It only applies to Dump style output.
+
+	* DebugSpew
+		Specifies whether or not to annotate each displayed value with an
+		inline "via" comment, such as one for Stringer, naming the code path which
+		produced it.  This is useful for diagnosing why a type is displayed
+		unexpectedly.
+
+	* MaxHexDumpBytes
+		Maximum number of bytes displayed in the hexdump of byte arrays and
+		slices.  The remaining bytes are replaced by a "... (N more)" marker.
+		The default, 0, means there is no limit.
+
+	* TypeNameFunc
+		Function which overrides the name displayed in the type annotation of
+		every value, such as to present values in domain vocabulary.  It is
+		also consulted for the key and element types of unnamed types such as
+		pointers, slices, and maps.  Returning an empty string falls back to the
+		default name.
+
+	* VerboseFloats
+		Specifies whether or not float32 and float64 values should be displayed
+		in a way that distinguishes the values standard formatting hides.
+		Negative zero is displayed as -0.0, NaNs are displayed with their bit
+		pattern such as NaN(0x7ff8000000000001), and subnormal values are
+		suffixed with (subnormal).
+
+	* RunLengthEncode
+		Specifies whether or not runs of at least three consecutive array and
+		slice elements with identical output should be collapsed into a single
+		element followed by the length of the run, such as (int) 0 (×1024).  It
+		only applies to Dump style output.
+
+	* StructStyle
+		Specifies how structs are displayed.  The default, BlockStructStyle, is
+		the normal multi-line block.  KeyValueStructStyle displays each struct,
+		including nested ones, on a single line as key=value pairs, such as
+		Foo{flag=flagTwo data=<nil>}.
+
+	* MapKVSeparator
+		Separator between map keys and values, such as " => " or " = ".  It
+		applies to all map and iterator output, including inline maps.  The
+		default, an empty string, means ": " is used in Dump style output and
+		":" is used inline.
+
+	* CollapsePointerChains
+		Specifies whether or not chains of multiple pointers to a single value
+		should be displayed without their addresses, such as (***int) ->5.  The
+		collapse is broken when any pointer in the chain is shared with another
+		part of the dumped value.
+
+	* GroupDigits
+		Specifies whether or not to separate every group of three digits of
+		integer values, such as 1,234,567.  Only the integer part of floating
+		point values is grouped.  It does not apply to hexadecimal values.
+
+	* DigitSeparator
+		Separator inserted between groups of digits by the GroupDigits option.
+		The default, an empty string, means a comma is used.
+
+	* AutoFlush
+		Specifies whether or not writers which implement a Flush() error
+		method, such as bufio.Writer, should be flushed once the output of
+		Fdump and the like has been written.  Any flush error is returned by
+		FdumpN.
+
+	* HighlightHeterogeneous
+		Specifies whether or not the dynamic type of each element of arrays,
+		slices, and maps whose element type is an interface, such as
+		[]interface{}, should be displayed prominently on its own line before
+		the element.  It only applies to Dump style output.
+
+	* MaxMapDepth
+		Maximum number of levels of nested maps to descend into independently of
+		MaxDepth.  Maps nested deeper are displayed as {... (N more)}, or
+		map[... (N more)] inline.  The default, 0, means only MaxDepth applies.
+
+	* ExportedOnly
+		Specifies whether or not to hide the unexported fields of structs to
+		guard against accidentally leaking secrets held in private state.
+		Specific struct types may be opted back in via AllowUnexported.  It is
+		enabled by default.
+
+	* ShowRunes
+		Displays rune values as a quoted character followed by their numeric
+		value, such as 'A' (65).  Since rune is an alias for int32, this applies
+		to all values of the predeclared int32 type, but not to named types.
+
+	* EventSink
+		Specifies a function which is called with an Event for each rendering
+		decision, such as entering a value, using a Stringer, truncating due to
+		a limit, or detecting a circular reference.  This is useful for testing
+		tools built on top of spew.
+
+	* MaxLineWidth
+		Maximum width of the lines of dump output before they are wrapped onto
+		continuation lines.  Lines are only broken at spaces outside of quoted
+		strings and hex dumps are never wrapped.  The default is 0 (no wrapping).
+
+	* QualifiedNilPointers
+		Displays nil pointers along with their type, such as (*Foo)(nil), in
+		both Dump and the custom formatter instead of <nil>.
+
+	* KeyConfig
+		Specifies a separate configuration used to display map keys, such as
+		one with methods disabled, while the main configuration is used to
+		display map values.  When nil, keys use the same configuration as values.
+
+	* ASCIIOnly
+		Guarantees the output only contains ASCII characters by escaping all
+		non-ASCII characters, including those in strings, Stringer output, and
+		the Indent option, as \u or \U escapes.
+
+	* StableAddresses
+		Replaces pointer addresses with fake sequential addresses, such as 0x1
+		and 0x2, assigned in the order they are encountered.  This makes the
+		output reproducible while preserving whether pointers are the same.
+
+	* UseValuer
+		Displays types which implement the driver.Valuer interface, such as
+		sql.NullString, as the result of their Value method with a (valuer)
+		annotation, or <null> for nil results.  Types whose Value method returns
+		an error are displayed normally.
+
+	* Ellipsis
+		Marker used for all truncated output.  Every truncation marker, including
+		those of MaxElements, MaxStringLen, MaxHexDumpBytes, MaxDepth, and
+		MaxMapDepth, consistently uses the form "<ellipsis> (N more)", or only the
+		ellipsis when the number isn't known, which makes truncation reliable to
+		detect by tools consuming the output.  It defaults to "...", which is also
+		used when it is empty.
+
+	* ShowRuntimeState
+		Displays runtime state which is useful for debugging garbage collection
+		issues on a best-effort basis.  Weak pointers, such as weak.Pointer[T],
+		are displayed as the value they point to, or <collected> once it has been
+		garbage collected.
+
+	* MarkNilMapValues
+		Displays map values which are nil pointers or nil interfaces as
+		<nil value> so entries which are present but hold nil can be told apart
+		from absent keys.
+
+	* SdumpSizeHint
+		Specifies the number of bytes to pre-allocate for the buffer used by
+		Sdump, Bdump, and SafeSdump, which avoids repeatedly growing the buffer
+		when large values are dumped.  The default 0 grows the buffer as
+		needed.
+
+	* HashNodes
+		Annotates each struct, array, slice, and map displayed by Dump with a
+		hash of its rendered content, such as [hash=3f2a9c0d1e7b4a65], so
+		identical substructures can be found within and across dumps.  The hash
+		is a fast non-cryptographic FNV-1a hash meant for comparison only, not
+		for security.
+
+	* DocTag
+		Specifies the name of a struct tag, such as doc, whose value is
+		displayed by Dump as a trailing comment after each field which has it,
+		such as Timeout: (int) 30, // connection timeout in seconds.  The
+		comments are disabled by default.
+
+	* FlattenPointers
+		Displays the values pointers point to directly, along with the type of
+		the value, instead of the pointer types and addresses.  Values which are
+		referenced by more than one pointer are labeled, such as #1, the first
+		time they are displayed and displayed as <seen #1> afterwards so
+		aliasing and circular references remain visible.  Dump and the custom
+		formatter label the same values the same way.
+
+	* TimeLayout
+		Specifies the layout, in the form accepted by time.Time.Format, used to
+		display time.Time values, including those behind pointers.  An empty
+		layout disables the special handling of times.  The global config
+		instance and NewDefaultConfig use time.RFC3339Nano by default.
+
+	* IncludeCaller
+		Precedes the output of Dump and its variants with the file name and
+		line number of the call site, such as main.go:42:, on a line of its
+		own to help locate which call produced which output in busy logs.
+
+	* ShowSliceIndices
+		Precedes each array and slice element displayed by Dump with its index,
+		such as [ 7], right aligned to the width of the largest index shown.
+		Runs collapsed by RunLengthEncode are labeled with the index of their
+		first element.
+
+	* ShowBothMethodAndInternals
+		Displays the quoted output of error and Stringer methods followed by the
+		internals of the value, such as (Foo) "stringer output" {...}.  It
+		supersedes ContinueOnMethod, which displays the method output in
+		parentheses instead, when both are enabled.
+
+	* UnexportedPolicy
+		Specifies which unexported struct fields are displayed.  The default,
+		UnexportedAll, displays all of them, UnexportedNone hides them exactly
+		the same as ExportedOnly, and UnexportedOwnPackagesOnly only displays
+		those of types defined in the packages whose import paths start with
+		one of the prefixes of OwnPackages.
+
+	* OwnPackages
+		Specifies the import path prefixes of the packages whose unexported
+		fields are displayed when UnexportedPolicy is UnexportedOwnPackagesOnly.
+
+	* CollapseRepeatedTypes
+		Omits the type annotations of array and slice elements displayed by Dump
+		when they all share the same type, displaying the common type of
+		interface elements once on the container, such as (elem=int), instead.
+		Heterogeneous containers keep their per-element annotations.
+
+	* MaxStringLen
+		Specifies the maximum number of bytes of text displayed for each string
+		value as well as for the output of error and Stringer methods.  Longer
+		text is cut off and followed by a "... (N more)" marker.  There is no
+		limit by default.
+
+	* ExpandImageTypes
+		Displays geometry and color types of the image and image/color packages
+		compactly, such as (0,0)-(640,480) for an image.Rectangle and #FF8000FF
+		for a color.RGBA.
+
+	* WriterWrapper
+		Specifies a function which is called with the destination writer of
+		each Dump, Fdump, and Sdump call and returns the writer to write the
+		dump through instead, such as one which prefixes each line.  The
+		destination is used directly by default.
+
+	* SideBySideWidth
+		Specifies the width of each column displayed by FdumpSideBySide and
+		SdumpSideBySide.  Longer lines are cut off with an ellipsis.  A width of
+		40 is used by default.
+
+	* ShowContainerCounts
+		Displays the element counts of arrays, maps, and slices along with their
+		type in the opening line of Dump, such as (map[string]int len=3), even
+		when they are zero.
+
+	* FieldSort
+		Specifies the order struct fields are displayed in.  The default,
+		DeclarationFieldOrder, is the order they are declared in, NameFieldOrder
+		sorts them by name, and TypeThenNameFieldOrder groups them by their
+		declared type and sorts each group by name.
+
+	* BytesEncoding
+		Specifies how Dump displays byte arrays and slices.  The default,
+		HexDumpBytesEncoding, displays them as a hexdump, while
+		Base64BytesEncoding, HexStringBytesEncoding, and StringBytesEncoding
+		display them on a single line as a quoted string.
+
+	* PrintLegend
+		Appends a legend to the output of Dump which explains the special markers
+		it contains, such as <nil> and <already shown>.  Only the markers which
+		were actually used are listed.
+
+	* PointerTrackLimit
+		Maximum number of distinct pointers tracked at once to detect circular
+		references, which bounds the memory used on enormous acyclic graphs at
+		the cost of missing cycles through pointers beyond the limit.  Setting
+		MaxDepth as well bounds how far a missed cycle is followed.  There is no
+		limit by default.
+
+	* RuneSlicesAsString
+		Displays arrays and slices of runes as the text they represent, such as
+		([]rune as string) "héllo", rather than as a list of numbers.  Like
+		ShowRunes, this applies to all arrays and slices of the predeclared int32
+		type.
+
+	* ShowMethodSet
+		Lists the exported methods of each struct displayed by Dump after its
+		fields, such as // func (T) String() string, including those only
+		available via a pointer, such as // func (*T) Reset().
+
+	* KindHandlers
+		Specifies functions which return the text displayed for all values of a
+		kind, such as every reflect.Float64 rounded to two decimals, instead of
+		the default rendering.  Types registered via RegisterBitflags and other
+		individually handled types, including those with error or Stringer
+		methods, take precedence.
+
+	* DerefMapKeys
+		Displays map keys which are pointers by the values they point to without
+		their addresses and sorts them by those values when SortKeys is set.
+
+	* StringerAtMaxDepth
+		Displays structs, arrays, slices, and maps whose contents are cut off by
+		MaxDepth via their error or Stringer method alone even when
+		ContinueOnMethod or ShowBothMethodAndInternals is set.  It is enabled by
+		default.
+
+	* ExpandNetAddrs
+		Specifies whether or not net.IP, net.IPNet, net.HardwareAddr, and url.URL
+		values are always displayed via their String methods without a length
+		annotation, even when ContinueOnMethod is set.  It has no effect when
+		DisableMethods is set.  Addresses are displayed as regular values by default.
+
+	* FloatFormat
+		Specifies the format floats and the parts of complex numbers are displayed
+		in, using the same formats as strconv.FormatFloat such as 'e' for scientific
+		or 'f' for fixed notation.  The 'g' format is used by default.
+
+	* LabelArgs
+		Specifies whether or not each top-level argument of Dump and the Printf
+		family should be preceded by a label of its index, such as arg[0]:, on a line
+		of its own.  Arguments aren't labeled by default.
+
+	* DumpChannelContents
+		Specifies whether or not Dump should display the elements buffered in
+		bidirectional channels by receiving them and sending them back, which is only
+		safe when no other goroutine uses the channels, such as in a stopped pipeline.
+		It requires UnsafeChannelDrain to be set as well.  Only addresses are
+		displayed by default.
+
+	* UnsafeChannelDrain
+		Acknowledges that DumpChannelContents temporarily drains the channels it
+		displays.  It has no effect on its own and is unset by default.
+
+	* ShowTypeChain
+		Specifies whether or not Dump should follow the type annotations of defined
+		types with their underlying type, such as (main.Celsius -> float64).  Since
+		reflect only exposes the final underlying type, intermediate defined types in
+		chains such as type A B are skipped.  Annotations show only the type by
+		default.
+
+	* FieldNameFunc
+		Specifies a function which returns the name a struct field, including an
+		embedded one, is displayed with.  Returning an empty string keeps the
+		declared name.  Fields are displayed with their declared names by default.
+
+	* CacheStringerResults
+		Specifies whether or not the results of error and String methods should be
+		remembered by the pointer they were called on for the rest of a single dump so
+		values referenced many times only have their methods called once.  Results
+		are not cached by default.
+
+	* GroupFields
+		Specifies whether or not Dump should separate struct fields with different
+		spewgroup struct tags by a blank line and precede each named group with a
+		comment naming it.  Fields are not grouped by default.
+
+	* CycleMarker
+		Specifies the marker displayed in place of circular references.  The default
+		of an empty string uses <already shown> for Dump and <shown> for the custom
+		formatter.
+
+	* CycleMarkerFunc
+		Specifies a function which returns the marker displayed in place of the
+		circular reference with the passed label, its position among the circular
+		references encountered so far starting at 1.  It takes precedence over
+		CycleMarker unless it returns an empty string.  It is nil by default.
 
 Dump Usage
 
@@ -120,20 +560,113 @@ io.Writer.  For example, to dump to standard error:
 
 	spew.Fdump(os.Stderr, myVar1, myVar2, ...)
 
+Use spew.FdumpN instead if you need to know the number of bytes written or any
+write error encountered.  The dump is stopped at the first write error:
+
+	n, err := spew.FdumpN(conn, myVar1, myVar2, ...)
+
 A third option is to call spew.Sdump to get the formatted output as a string:
 
 	str := spew.Sdump(myVar1, myVar2, ...)
 
+When accumulating the output of many dumps, spew.FdumpTo appends the output
+directly to a strings.Builder to avoid the intermediate copy made by Sdump:
+
+	var sb strings.Builder
+	spew.FdumpTo(&sb, myVar1, myVar2, ...)
+
+Byte-oriented pipelines can call spew.Bdump instead, which returns the output as
+a byte slice owned by the caller without converting it to a string:
+
+	hash.Write(spew.Bdump(myVar1, myVar2, ...))
+
+To visualize a pointer-heavy data structure, spew.Fdot outputs its object graph
+in the Graphviz DOT language with one node per struct, array, slice, and map:
+
+	spew.Fdot(os.Stdout, myVar)
+
+To dump only part of a large value, spew.DumpPath selects it with a path of
+dotted field names and bracketed indices or map keys.  An error is returned
+when the path does not resolve:
+
+	err := spew.DumpPath(root, "Config.Servers[0].Limits[\"conn\"]")
+
+Inside recover handlers, spew.SafeSdump never panics.  Any panic raised while
+dumping a partially constructed value is returned separately and the output is
+terminated with a <dump aborted: ...> marker:
+
+	str, recovered := spew.SafeSdump(myVar)
+
+For golden tests, spew.EqualExcept compares two values deeply while ignoring
+inherently non-deterministic fields selected with the same path syntax:
+
+	ok := spew.EqualExcept(got, want, "Created", "Items[*].ID")
+
+To see what is actually set in a large configuration struct, spew.DumpNonZero
+omits all struct fields which hold the zero value of their type and reports
+how many were omitted:
+
+	spew.DumpNonZero(cfg)
+
+To observe state transitions, spew.Snapshot takes a deep copy of a value and
+DumpChanges later displays only the portions of it which changed:
+
+	snap := spew.Snapshot(svc)
+	svc.HandleRequest(req)
+	snap.DumpChanges(svc)
+
+For tools which consume s-expressions, spew.SdumpSexp returns a value
+formatted as a single parseable s-expression:
+
+	fmt.Println(spew.SdumpSexp(foo)) // (Foo (flag flagTwo) (data nil))
+
+For exploring unknown payloads, spew.SdumpSchema returns an outline of the
+types of a value with one sample element of each array, slice, and map rather
+than a full dump:
+
+	fmt.Print(spew.SdumpSchema(payload)) // []main.Item (len 1000) [...
+
+For documentation and issues, spew.SdumpMarkdown returns the same output as
+Sdump wrapped in a Markdown fenced code block.
+
+For reviewing test failures, spew.SdumpSideBySide displays the dumps of two
+values in two columns with their matching lines aligned and the lines which
+differ marked.
+
+For change detection, spew.Fingerprint returns a stable SHA-256 hash of the
+deterministic dump of a value, so two values which are structurally equal
+produce the same fingerprint:
+
+	if spew.Fingerprint(cfg) != lastFingerprint {
+		log.Println("configuration changed")
+	}
+
+For structured loggers, spew.Fields flattens a value into a map of dotted keys
+to scalar values which can be passed to their fields:
+
+	logger.WithFields(spew.Fields(foo)) // {"Foo.flag": "flagTwo", ...}
+
+Bitmask types whose values combine several flags can be registered via
+RegisterBitflags so they are displayed as the names of the flags which are set
+rather than as an opaque number:
+
+	spew.Config.RegisterBitflags(reflect.TypeOf(Perm(0)),
+		map[uint64]string{1: "read", 2: "write", 4: "exec"})
+	spew.Dump(Perm(5)) // (main.Perm) read|exec (0b101)
+
+Labels which refer back to previously displayed values, such as the slice
+numbers of the DetectSliceAliasing option and the node numbers of Fdot, are
+always assigned in the order values are encountered and never derived from
+addresses.  The output is therefore deterministic across runs and
+architectures for the same data as long as map keys are ordered via the
+SortKeys or MapKeyOrder options and pointer addresses are not displayed.
+
 Sample Dump Output
 
 See the Dump example for details on the setup of the types and variables being
 shown here.
 
 	(main.Foo) {
-	 unexportedField: (*main.Bar)(0xf84002e210)({
-	  flag: (main.Flag) flagTwo,
-	  data: (uintptr) <nil>
-	 }),
 	 ExportedField: (map[interface {}]interface {}) (len=1) {
 	  (string) (len=3) "one": (bool) true
 	 }
@@ -161,6 +694,17 @@ standard fmt package for formatting.  In addition, the custom formatter ignores
 the width and precision arguments (however they will still work on the format
 specifiers not handled by the custom formatter).
 
+The - flag may be combined with any of these, such as %-v or %-#v, to force map
+keys to be sorted for that call as if the SortKeys option were set.
+
+The space flag, as in % v, instead produces the full multi-line indented output
+of Dump, without the trailing newline, so it can be used inline in a Printf
+call such as:
+
+	spew.Printf("state:\n% v\n", myVar)
+
+It may be combined with the - flag, as in %- v, to sort map keys for that call.
+
 Custom Formatter Usage
 
 The simplest way to make use of the spew custom formatter is to call one of the
@@ -185,9 +729,9 @@ Double pointer to a uint8:
 
 Pointer to circular struct with a uint8 field and a pointer to itself:
 	  %v: <*>{1 <*><shown>}
-	 %+v: <*>(0xf84003e260){ui8:1 c:<*>(0xf84003e260)<shown>}
-	 %#v: (*main.circular){ui8:(uint8)1 c:(*main.circular)<shown>}
-	%#+v: (*main.circular)(0xf84003e260){ui8:(uint8)1 c:(*main.circular)(0xf84003e260)<shown>}
+	 %+v: <*>(0xf84003e260){Ui8:1 C:<*>(0xf84003e260)<shown>}
+	 %#v: (*main.circular){Ui8:(uint8)1 C:(*main.circular)<shown>}
+	%#+v: (*main.circular)(0xf84003e260){Ui8:(uint8)1 C:(*main.circular)(0xf84003e260)<shown>}
 
 See the Printf example for details on the setup of variables being shown
 here.
@@ -197,6 +741,7 @@ Errors
 Since it is possible for custom Stringer/error interfaces to panic, spew
 detects them and handles them internally by printing the panic information
 inline with the output.  Since spew is intended to provide deep pretty printing
-capabilities on structures, it intentionally does not return any errors.
+capabilities on structures, it intentionally does not return any errors
+other than those encountered while writing the output via FdumpN.
 */
 package spew
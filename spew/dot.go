@@ -0,0 +1,268 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// dotKey identifies a value which is reachable through a reference, such as
+// the target of a pointer, a map, or the elements of a slice, so every
+// reference to it shares the same node in the object graph.  The length
+// distinguishes slices of different lengths sharing the same backing array.
+type dotKey struct {
+	addr uintptr
+	vt   reflect.Type
+	len  int
+}
+
+// dotNode describes a node in the object graph.  The label houses the type of
+// the value followed by any of its scalar elements.
+type dotNode struct {
+	id    int
+	label []string
+}
+
+// dotEdge describes a pointer or containment relationship between two nodes
+// in the object graph.
+type dotEdge struct {
+	from  int
+	to    int
+	label string
+}
+
+// dotState contains information about the state of an object graph operation.
+type dotState struct {
+	cs       *ConfigState
+	nodes    []*dotNode
+	edges    []dotEdge
+	ids      map[dotKey]int
+	visiting map[uintptr]bool
+}
+
+// isDotComposite returns whether the passed value should be displayed as its
+// own node in the object graph as opposed to inline in the label of its
+// parent.  Byte arrays and slices are displayed inline.
+func isDotComposite(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return false
+		}
+		return v.Type().Elem().Kind() != reflect.Uint8
+	case reflect.Map:
+		return !v.IsNil()
+	case reflect.Struct:
+		return true
+	}
+	return false
+}
+
+// scalar returns the inline representation of the passed value which is the
+// same as the %v verb of the custom formatter.
+func (s *dotState) scalar(v reflect.Value) string {
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return fmt.Sprint(v)
+		}
+		v = unsafeReflectValue(v)
+	}
+	return s.cs.Sprint(v.Interface())
+}
+
+// value returns the id of the node the passed value is displayed as, creating
+// it as needed, or -1 along with the inline representation of the value when
+// it isn't displayed as its own node.
+func (s *dotState) value(v reflect.Value) (id int, scalar string) {
+	v = unpackReflectValue(v)
+	switch v.Kind() {
+	case reflect.Invalid:
+		return -1, string(invalidAngleBytes)
+
+	case reflect.Interface:
+		if v.IsNil() {
+			return -1, string(nilAngleBytes)
+		}
+		return s.value(v.Elem())
+
+	case reflect.Ptr:
+		if v.IsNil() {
+			return -1, string(nilAngleBytes)
+		}
+		return s.reference(v, v.Elem())
+
+	case reflect.Map:
+		if isDotComposite(v) {
+			return s.reference(v, v)
+		}
+
+	case reflect.Slice:
+		if isDotComposite(v) && v.Len() > 0 {
+			return s.reference(v, v)
+		}
+	}
+
+	if isDotComposite(v) {
+		return s.node(v, nil), ""
+	}
+	return -1, s.scalar(v)
+}
+
+// reference returns the id of the node for the passed target which is reached
+// through the reference r.  All references to the same target share the same
+// node.
+func (s *dotState) reference(r, target reflect.Value) (id int, scalar string) {
+	key := dotKey{addr: r.Pointer(), vt: r.Type()}
+	if r.Kind() == reflect.Slice {
+		key.len = r.Len()
+	}
+	if id, ok := s.ids[key]; ok {
+		return id, ""
+	}
+
+	// Pointers to non-composite values are followed until reaching a
+	// composite or scalar value while detecting circular references.
+	if !isDotComposite(target) {
+		if s.visiting[key.addr] {
+			return -1, string(circularShortBytes)
+		}
+		s.visiting[key.addr] = true
+		defer delete(s.visiting, key.addr)
+		return s.value(target)
+	}
+	return s.node(target, &key), ""
+}
+
+// node creates a new node for the passed composite value, which is registered
+// under the passed key when it is reached through a reference, and then
+// creates nodes and edges for all of its elements.
+func (s *dotState) node(v reflect.Value, key *dotKey) int {
+	n := &dotNode{id: len(s.nodes) + 1}
+	s.nodes = append(s.nodes, n)
+	if key != nil {
+		s.ids[*key] = n.id
+	}
+
+	header := typeName(s.cs, v.Type())
+	if v.Kind() != reflect.Struct {
+		header += " (len=" + strconv.Itoa(v.Len()) + ")"
+	}
+	n.label = append(n.label, header)
+
+	// element adds either an edge or a labeled inline value for the passed
+	// element of the node.
+	element := func(name string, ev reflect.Value) {
+		id, scalar := s.value(ev)
+		if id < 0 {
+			n.label = append(n.label, name+": "+scalar)
+			return
+		}
+		s.edges = append(s.edges, dotEdge{n.id, id, name})
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		vt := v.Type()
+		for i := 0; i < v.NumField(); i++ {
+			if isHiddenField(s.cs, vt, i) {
+				continue
+			}
+			element(fieldName(s.cs, vt.Field(i)), v.Field(i))
+		}
+
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			element("["+strconv.Itoa(i)+"]", v.Index(i))
+		}
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sortMapKeys(keys, s.cs)
+		for _, mk := range keys {
+			_, name := s.value(mk)
+			if isDotComposite(mk) {
+				name = s.scalar(mk)
+			}
+			element(name, v.MapIndex(mk))
+		}
+	}
+	return n.id
+}
+
+// dotEscaper escapes the characters which are special inside the quoted
+// strings of the DOT language.
+var dotEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// fdot is a helper function to consolidate the logic from the various public
+// methods which take varying config states.
+func fdot(cs *ConfigState, w io.Writer, v interface{}) {
+	s := dotState{cs: cs, ids: make(map[dotKey]int),
+		visiting: make(map[uintptr]bool)}
+	if id, scalar := s.value(reflect.ValueOf(v)); id < 0 {
+		// Values which aren't composites are displayed as a single node.
+		vt := "interface {}"
+		if v != nil {
+			vt = typeName(cs, reflect.TypeOf(v))
+		}
+		s.nodes = append(s.nodes, &dotNode{1, []string{vt, scalar}})
+	}
+
+	fmt.Fprintln(w, "digraph spew {")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	for _, n := range s.nodes {
+		label := ""
+		for _, line := range n.label {
+			label += dotEscaper.Replace(line) + `\l`
+		}
+		fmt.Fprintf(w, "\tn%d [label=\"%s\"];\n", n.id, label)
+	}
+	for _, e := range s.edges {
+		fmt.Fprintf(w, "\tn%d -> n%d [label=\"%s\"];\n", e.from, e.to,
+			dotEscaper.Replace(e.label))
+	}
+	fmt.Fprintln(w, "}")
+}
+
+/*
+Fdot outputs the object graph of the passed value to io.Writer w in the
+Graphviz DOT language.  The result can be rendered with the Graphviz tools, such
+as "dot -Tsvg", to visualize complex pointer-heavy data structures.
+
+Each struct, array, slice, and map is displayed as a node labeled with its type
+and any scalar elements it contains.  Edges are used for pointers and for
+elements which are themselves displayed as nodes.  Every pointer to the same
+value, and every reference to the same map or slice, shares the same node,
+which makes aliasing and circular references visually obvious.  Byte arrays
+and slices are displayed inline.
+
+The nodes are numbered in the order they are encountered, so the output is
+deterministic for the same data when the SortKeys option is enabled.
+*/
+func (c *ConfigState) Fdot(w io.Writer, v interface{}) {
+	fdot(c, w, v)
+}
+
+// Fdot outputs the object graph of the passed value to io.Writer w in the
+// Graphviz DOT language using the global configuration.  See ConfigState.Fdot
+// for details.
+func Fdot(w io.Writer, v interface{}) {
+	fdot(&Config, w, v)
+}
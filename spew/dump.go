@@ -18,14 +18,18 @@ package spew
 
 import (
 	"bytes"
+	"encoding/base64"
 	"encoding/hex"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"os"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 var (
@@ -45,13 +49,202 @@ var (
 	// cUint8tCharRE is a regular expression that matches a cgo uint8_t.
 	// It is used to detect uint8_t arrays to hexdump them.
 	cUint8tCharRE = regexp.MustCompile("^.*\\._Ctype_uint8_t$")
+
+	// hexDumpLineRE is a regular expression that matches a line of a hex
+	// dump.  It is used to avoid wrapping hex dumps.
+	hexDumpLineRE = regexp.MustCompile("^[ \\t]*[0-9a-f]{8}  ")
 )
 
+// dumpWriter wraps the io.Writer a dump is written to in order to keep track
+// of the number of bytes written and the first write error encountered.  Once
+// an error has been encountered, all further writes are discarded.
+type dumpWriter struct {
+	w       io.Writer
+	n       int
+	err     error
+	ascii   *asciiWriter
+	wrapper *lineWrapper
+	legend  *legendTracker
+}
+
+// Write writes the passed bytes to the underlying writer unless a previous
+// write has already failed.  It is part of the io.Writer interface
+// implementation.
+func (dw *dumpWriter) Write(p []byte) (int, error) {
+	if dw.err != nil {
+		return 0, dw.err
+	}
+	if dw.legend != nil {
+		dw.legend.note(p)
+	}
+	n, err := writeFull(dw.w, p)
+	dw.n += n
+	dw.err = err
+	return n, err
+}
+
+// writeFull writes all of the passed bytes to w.  Writers which accept fewer
+// bytes than passed without returning an error, such as slow network writers
+// applying backpressure, are called again with the remaining bytes rather than
+// silently losing them.  It returns io.ErrShortWrite when a call makes no
+// progress at all.
+func writeFull(w io.Writer, p []byte) (n int, err error) {
+	for n < len(p) {
+		var m int
+		m, err = w.Write(p[n:])
+		n += m
+		if err != nil {
+			return n, err
+		}
+		if m == 0 {
+			return n, io.ErrShortWrite
+		}
+	}
+	return n, nil
+}
+
+// newDumpWriter returns a dumpWriter which writes to the passed writer while
+// escaping non-ASCII characters according to the ASCIIOnly option and
+// wrapping long lines according to the MaxLineWidth option.
+func newDumpWriter(cs *ConfigState, w io.Writer) *dumpWriter {
+	dw := &dumpWriter{}
+	if cs.MaxLineWidth > 0 {
+		dw.wrapper = &lineWrapper{w: w, width: cs.MaxLineWidth,
+			indent: cs.Indent}
+		w = dw.wrapper
+	}
+	if cs.ASCIIOnly {
+		dw.ascii = &asciiWriter{w: w}
+		w = dw.ascii
+	}
+	dw.w = w
+	return dw
+}
+
+// finish writes any output which is still buffered for line wrapping and
+// updates the number of bytes written to account for any escaping and
+// wrapping.  It must be called once the dump is complete.
+func (dw *dumpWriter) finish() {
+	switch {
+	case dw.wrapper != nil:
+		if dw.err == nil {
+			dw.err = dw.wrapper.flush()
+		}
+		dw.n = dw.wrapper.n
+	case dw.ascii != nil:
+		dw.n = dw.ascii.n
+	}
+}
+
+// lineWrapper buffers the lines written to it in order to wrap those which are
+// longer than the configured width before passing them along to the
+// underlying writer.
+type lineWrapper struct {
+	w      io.Writer
+	width  int
+	indent string
+	line   []byte
+	n      int
+}
+
+// Write buffers the passed bytes and writes all complete lines to the
+// underlying writer.  It is part of the io.Writer interface implementation.
+func (lw *lineWrapper) Write(p []byte) (int, error) {
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			lw.line = append(lw.line, p...)
+			break
+		}
+		lw.line = append(lw.line, p[:i+1]...)
+		if err := lw.flush(); err != nil {
+			return 0, err
+		}
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// flush wraps the buffered line and writes it to the underlying writer.
+func (lw *lineWrapper) flush() error {
+	line := wrapLine(lw.line, lw.width, lw.indent)
+	lw.line = lw.line[:0]
+	n, err := writeFull(lw.w, line)
+	lw.n += n
+	return err
+}
+
+// wrapLine breaks the passed line at spaces so each resulting line is no
+// longer than width characters where possible.  Continuation lines are
+// indented one level deeper than the original line.  Lines are never broken
+// inside of quoted strings and hex dumps are not wrapped at all, so a token
+// which is longer than the width is left intact.
+func wrapLine(line []byte, width int, indent string) []byte {
+	content := bytes.TrimSuffix(line, newlineBytes)
+	if utf8.RuneCount(content) <= width || hexDumpLineRE.Match(content) {
+		return line
+	}
+
+	lead := len(line) - len(bytes.TrimLeft(line, " \t"))
+	prefix := string(line[:lead]) + indent
+	if indent == "" {
+		prefix += " "
+	}
+
+	var out []byte
+	start, brk, col := 0, -1, 0
+	inQuote, escaped := false, false
+	for i, r := range string(line) {
+		switch {
+		case escaped:
+			escaped = false
+		case inQuote && r == '\\':
+			escaped = true
+		case r == '"':
+			inQuote = !inQuote
+		case r == ' ' && !inQuote && i >= lead:
+			brk = i
+		}
+
+		col++
+		if col > width && r != '\n' && brk > start {
+			out = append(out, line[start:brk]...)
+			out = append(out, '\n')
+			out = append(out, prefix...)
+			start = brk + 1
+			col = utf8.RuneCountInString(prefix) +
+				utf8.RuneCount(line[start:i+utf8.RuneLen(r)])
+		}
+	}
+	return append(out, line[start:]...)
+}
+
+// sliceBacking describes the memory range of the backing array of a slice
+// that has been dumped.
+type sliceBacking struct {
+	start uintptr
+	end   uintptr
+}
+
 // dumpState contains information about the state of a dump operation.
 type dumpState struct {
 	w                io.Writer
 	depth            int
 	pointers         map[uintptr]int
+	cycles           int
+	unfiltered       bool
+	matches          fieldMatcher
+	derefKey         bool
+	mapDepth         int
+	methods          methodCache
+	slices           *[]sliceBacking
+	pointerRefs      map[uintptr]int
+	zeroFields       *int
+	fakeAddrs        map[uintptr]uintptr
+	identities       map[interface{}]bool
+	flatLabels       map[uintptr]int
+	hash             *hashFrame
 	ignoreNextType   bool
 	ignoreNextIndent bool
 	cs               *ConfigState
@@ -79,6 +272,11 @@ func (d *dumpState) unpackValue(v reflect.Value) reflect.Value {
 
 // dumpPtr handles formatting of pointers by indirecting them as necessary.
 func (d *dumpState) dumpPtr(v reflect.Value) {
+	// Display the targets of pointers directly when enabled.
+	if d.cs.FlattenPointers && d.dumpFlatPtr(v) {
+		return
+	}
+
 	// Remove pointers at or below the current depth from map used to detect
 	// circular refs.
 	for k, depth := range d.pointers {
@@ -107,10 +305,11 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 		pointerChain = append(pointerChain, addr)
 		if pd, ok := d.pointers[addr]; ok && pd < d.depth {
 			cycleFound = true
+			emitEvent(d.cs, CycleEvent, ve.Type(), d.depth, "")
 			indirects--
 			break
 		}
-		d.pointers[addr] = d.depth
+		trackPointer(d.cs, d.pointers, addr, d.depth)
 
 		ve = ve.Elem()
 		if ve.Kind() == reflect.Interface {
@@ -125,17 +324,28 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 	// Display type information.
 	d.w.Write(openParenBytes)
 	d.w.Write(bytes.Repeat(asteriskBytes, indirects))
-	d.w.Write([]byte(ve.Type().String()))
+	d.w.Write([]byte(typeName(d.cs, ve.Type())))
+	d.w.Write([]byte(typeChain(d.cs, ve.Type())))
 	d.w.Write(closeParenBytes)
 
-	// Display pointer information.
-	if len(pointerChain) > 0 {
+	// Collapse chains of unshared pointers to a single value when enabled.
+	if d.collapsiblePointerChain(pointerChain, nilFound || cycleFound) {
+		d.w.Write(spaceBytes)
+		d.w.Write(pointerChainBytes)
+		d.ignoreNextType = true
+		d.dump(ve)
+		return
+	}
+
+	// Display pointer information unless map keys are displayed by their
+	// pointees.
+	if len(pointerChain) > 0 && !d.derefKey {
 		d.w.Write(openParenBytes)
 		for i, addr := range pointerChain {
 			if i > 0 {
 				d.w.Write(pointerChainBytes)
 			}
-			printHexPtr(d.w, addr)
+			printAddr(d.cs, d.w, d.fakeAddrs, addr)
 		}
 		d.w.Write(closeParenBytes)
 	}
@@ -143,11 +353,15 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 	// Display dereferenced value.
 	d.w.Write(openParenBytes)
 	switch {
+	case nilFound && d.cs.QualifiedNilPointers:
+		d.w.Write(nilBytes)
+
 	case nilFound == true:
 		d.w.Write(nilAngleBytes)
 
 	case cycleFound == true:
-		d.w.Write(circularBytes)
+		checkCycles(d.cs, &d.cycles)
+		printCycleMarker(d.cs, d.w, d.cycles, circularBytes)
 
 	default:
 		d.ignoreNextType = true
@@ -156,15 +370,419 @@ func (d *dumpState) dumpPtr(v reflect.Value) {
 	d.w.Write(closeParenBytes)
 }
 
-// dumpSlice handles formatting of arrays and slices.  Byte (uint8 under
-// reflection) arrays and slices are dumped in hexdump -C fashion.
-func (d *dumpState) dumpSlice(v reflect.Value) {
-	// Determine whether this type should be hex dumped or not.  Also,
-	// for types which should be hexdumped, try to use the underlying data
-	// first, then fall back to trying to convert them to a uint8 slice.
+// dumpFlatPtr displays the value the passed pointer chain ultimately points to
+// directly according to the FlattenPointers option.  Targets which are
+// referenced by more than one pointer are labeled with a number the first time
+// they are displayed and with a <seen #N> reference afterwards, which also
+// covers circular references.  It returns false without displaying anything
+// for chains which lead to a nil pointer or nil interface so they are displayed
+// as usual.
+func (d *dumpState) dumpFlatPtr(v reflect.Value) bool {
+	var chain []uintptr
+	ve := v
+	for ve.Kind() == reflect.Ptr || ve.Kind() == reflect.Interface {
+		if ve.IsNil() {
+			return false
+		}
+		if ve.Kind() == reflect.Ptr {
+			chain = append(chain, ve.Pointer())
+		}
+		ve = ve.Elem()
+	}
+
+	if d.flatLabels == nil {
+		d.flatLabels = make(map[uintptr]int)
+	}
+	for _, addr := range chain {
+		if label, ok := d.flatLabels[addr]; ok {
+			emitEvent(d.cs, CycleEvent, v.Type(), d.depth, "")
+			if label == 0 {
+				checkCycles(d.cs, &d.cycles)
+				printCycleMarker(d.cs, d.w, d.cycles, circularBytes)
+				return true
+			}
+			d.w.Write(seenLabelBytes)
+			printInt(d.w, int64(label), 10)
+			d.w.Write(closeAngleBytes)
+			return true
+		}
+	}
+
+	// Label the target when any pointer in the chain is shared.
+	label := 0
+	for _, addr := range chain {
+		if d.pointerRefs[addr] > 1 {
+			label = len(d.flatLabels) + 1
+			break
+		}
+	}
+	for _, addr := range chain {
+		d.flatLabels[addr] = label
+	}
+	if label != 0 {
+		d.w.Write(hashBytes)
+		printInt(d.w, int64(label), 10)
+		d.w.Write(spaceBytes)
+	}
+	d.ignoreNextIndent = true
+	d.ignoreNextType = false
+	d.dump(ve)
+	return true
+}
+
+// collapsiblePointerChain returns whether the passed chain of pointer addresses
+// should be collapsed according to the CollapsePointerChains option.  Only
+// chains of multiple pointers which lead to a value are collapsed and the
+// collapse is broken when any pointer in the chain is referenced elsewhere.
+func (d *dumpState) collapsiblePointerChain(chain []uintptr, incomplete bool) bool {
+	if !d.cs.CollapsePointerChains || incomplete || len(chain) < 2 {
+		return false
+	}
+	for _, addr := range chain {
+		if d.pointerRefs[addr] > 1 {
+			return false
+		}
+	}
+	return true
+}
+
+// countPointerRefs counts the number of pointers which refer to each address
+// reachable from the passed value.  It is used to detect shared pointers for
+// the CollapsePointerChains and FlattenPointers options.
+func countPointerRefs(cs *ConfigState, v reflect.Value, refs map[uintptr]int) {
+	c := pointerRefCounter{cs: cs, refs: refs}
+	c.count(v, 0)
+}
+
+// pointerRefCounter contains information about the state of counting pointer
+// references.  Maps and slices which are being visited are tracked by the
+// same identity the dump uses to detect those which contain themselves.
+type pointerRefCounter struct {
+	cs       *ConfigState
+	refs     map[uintptr]int
+	visiting map[interface{}]bool
+}
+
+// count counts the pointer references reachable from the passed value at the
+// passed nesting depth.  Values nested beyond the MaxDepth option aren't
+// displayed, so they aren't visited either.
+func (c *pointerRefCounter) count(v reflect.Value, depth int) {
+	if c.cs.MaxDepth != 0 && depth > c.cs.MaxDepth {
+		return
+	}
+	if token, ok := containerToken(v); ok {
+		if c.visiting[token] {
+			return
+		}
+		if c.visiting == nil {
+			c.visiting = make(map[interface{}]bool)
+		}
+		c.visiting[token] = true
+		defer delete(c.visiting, token)
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return
+		}
+		c.refs[v.Pointer()]++
+		if c.refs[v.Pointer()] == 1 {
+			c.count(v.Elem(), depth)
+		}
+
+	case reflect.Interface:
+		if !v.IsNil() {
+			c.count(v.Elem(), depth)
+		}
+
+	case reflect.Array, reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			c.count(v.Index(i), depth+1)
+		}
+
+	case reflect.Map:
+		for _, key := range v.MapKeys() {
+			c.count(key, depth+1)
+			c.count(v.MapIndex(key), depth+1)
+		}
+
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			c.count(v.Field(i), depth+1)
+		}
+	}
+}
+
+// dumpSliceAliasing records the backing array of the passed slice and displays
+// which previously dumped slice, if any, shares the same backing array.
+func (d *dumpState) dumpSliceAliasing(v reflect.Value) {
+	elemSize := v.Type().Elem().Size()
+	if v.IsNil() || v.Cap() == 0 || elemSize == 0 {
+		return
+	}
+	start := v.Pointer()
+	end := start + uintptr(v.Cap())*elemSize
+	for i, backing := range *d.slices {
+		if start < backing.end && backing.start < end {
+			d.w.Write(sharesBackingBytes)
+			printInt(d.w, int64(i+1), 10)
+			d.w.Write(closeBracketBytes)
+			d.w.Write(spaceBytes)
+			break
+		}
+	}
+	*d.slices = append(*d.slices, sliceBacking{start, end})
+}
+
+// hashSpan describes where the content of a node rendered by the HashNodes
+// option is located within the shared content buffer along with its hash.
+type hashSpan struct {
+	start, end int
+	depth      int
+	sum        uint64
+}
+
+// hashFrame houses the state of a node whose content is rendered into a buffer
+// by the HashNodes option so its hash can be displayed ahead of the content.
+// Nested nodes share the buffer of the outermost node, which is displayed
+// with the hashes of all nodes inserted once it has been rendered.
+type hashFrame struct {
+	buf      *bytes.Buffer
+	rendered *[]hashSpan
+	start    int
+	children []hashSpan
+}
+
+// sum returns the hash of the content of the node rendered at the passed depth
+// which ends at the passed offset.  The indentation of the node is removed from
+// every line so identical content hashes the same regardless of where it
+// appears.  The content of child nodes is replaced by their hashes so every
+// node is only rendered and hashed once.
+func (f *hashFrame) sum(indent string, depth, end int) uint64 {
+	h := fnv.New64a()
+	prefix := []byte(strings.Repeat(indent, depth))
+	content := f.buf.Bytes()
+	lineStart := false
+	write := func(b []byte) {
+		for len(b) > 0 {
+			if lineStart {
+				b = bytes.TrimPrefix(b, prefix)
+			}
+			i := bytes.IndexByte(b, '\n')
+			if i < 0 {
+				h.Write(b)
+				lineStart = false
+				return
+			}
+			h.Write(b[:i+1])
+			b = b[i+1:]
+			lineStart = true
+		}
+	}
+	pos := f.start
+	for _, child := range f.children {
+		write(content[pos:child.start])
+		fmt.Fprintf(h, "%016x", child.sum)
+		pos = child.end
+	}
+	write(content[pos:end])
+	return h.Sum64()
+}
+
+// isHashNode returns whether the passed value is a struct, array, slice, or
+// map which is annotated with a hash by the HashNodes option.
+func isHashNode(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct, reflect.Array:
+		return true
+	case reflect.Slice, reflect.Map:
+		return !v.IsNil()
+	}
+	return false
+}
+
+// enterHash prepares for rendering the content of a node according to the
+// HashNodes option and returns a function which displays its hash ahead of
+// the content once it has been rendered.
+func (d *dumpState) enterHash() func() {
+	w, parent, depth := d.w, d.hash, d.depth
+	frame := &hashFrame{}
+	shared := parent != nil && w == io.Writer(parent.buf)
+	if shared {
+		frame.buf, frame.rendered = parent.buf, parent.rendered
+	} else {
+		frame.buf, frame.rendered = new(bytes.Buffer), new([]hashSpan)
+		d.w = frame.buf
+	}
+	frame.start = frame.buf.Len()
+	d.hash = frame
+
+	return func() {
+		d.w, d.hash = w, parent
+		end := frame.buf.Len()
+		span := hashSpan{frame.start, end, depth,
+			frame.sum(d.cs.Indent, depth, end)}
+		*frame.rendered = append(*frame.rendered, span)
+		if shared {
+			parent.children = append(parent.children, span)
+			return
+		}
+		d.dumpHashed(frame.buf.Bytes(), *frame.rendered)
+	}
+}
+
+// dumpHashed displays the passed content with the hash of every node rendered
+// into it inserted ahead of the content of the node.
+func (d *dumpState) dumpHashed(content []byte, rendered []hashSpan) {
+	// Nodes are rendered before their parents, so order them by where they
+	// start with parents ahead of their children starting at the same place.
+	sort.SliceStable(rendered, func(i, j int) bool {
+		if rendered[i].start != rendered[j].start {
+			return rendered[i].start < rendered[j].start
+		}
+		return rendered[i].depth < rendered[j].depth
+	})
+	pos := 0
+	for _, span := range rendered {
+		d.w.Write(content[pos:span.start])
+		d.w.Write(openHashBytes)
+		fmt.Fprintf(d.w, "%016x", span.sum)
+		d.w.Write(closeBracketBytes)
+		d.w.Write(spaceBytes)
+		pos = span.start
+	}
+	d.w.Write(content[pos:])
+}
+
+// dumpMapKey displays the passed map key using the passed configuration which
+// is chosen according to the KeyConfig option.
+func (d *dumpState) dumpMapKey(kcs *ConfigState, key reflect.Value) {
+	cs, derefKey := d.cs, d.derefKey
+	d.cs = kcs
+	d.derefKey = derefKey || cs.DerefMapKeys
+	d.dump(key)
+	d.cs, d.derefKey = cs, derefKey
+}
+
+// dumpCompactMap displays the passed map inline when it qualifies according to
+// the CompactSmallMaps option.  It returns whether or not the map was handled.
+func (d *dumpState) dumpCompactMap(v reflect.Value) (handled bool) {
+	vt := v.Type()
+	if d.cs.CompactSmallMaps == 0 || v.Len() > d.cs.CompactSmallMaps ||
+		numShown(d.cs, v.Len()) < v.Len() ||
+		!isScalarKind(vt.Key().Kind()) || !isScalarKind(vt.Elem().Kind()) {
+
+		return false
+	}
+
+	// The entries are displayed via the custom formatter which requires an
+	// interface to the underlying value.
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return false
+		}
+		v = unsafeReflectValue(v)
+	}
+
+	keys := v.MapKeys()
+	sortMapKeys(keys, d.cs)
+	kcs := keyConfig(d.cs)
+	d.w.Write(openBraceBytes)
+	for i, key := range keys {
+		if i > 0 {
+			d.w.Write(spaceBytes)
+		}
+		fmt.Fprintf(d.w, "%v", newFormatter(kcs, key.Interface()))
+		d.w.Write(mapSeparator(d.cs, colonBytes))
+		fmt.Fprintf(d.w, "%v", newFormatter(d.cs, v.MapIndex(key).Interface()))
+	}
+	d.w.Write(closeBraceBytes)
+	return true
+}
+
+// dumpKeyValueStruct displays the passed struct on a single line as key=value
+// pairs when enabled by the StructStyle option.  It returns whether or not the
+// struct was handled.
+func (d *dumpState) dumpKeyValueStruct(v reflect.Value) (handled bool) {
+	if d.cs.StructStyle != KeyValueStructStyle {
+		return false
+	}
+
+	// The struct is displayed via the custom formatter which requires an
+	// interface to the underlying value.
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return false
+		}
+		v = unsafeReflectValue(v)
+	}
+	fmt.Fprintf(d.w, "%v", newFormatter(d.cs, v.Interface()))
+	return true
+}
+
+// dumpHex displays the passed bytes like the hexdump -C command indented to the
+// current depth.
+func (d *dumpState) dumpHex(buf []byte) {
+	remaining := 0
+	if d.cs.MaxHexDumpBytes > 0 && len(buf) > d.cs.MaxHexDumpBytes {
+		remaining = len(buf) - d.cs.MaxHexDumpBytes
+		buf = buf[:d.cs.MaxHexDumpBytes]
+	}
+
+	indent := strings.Repeat(d.cs.Indent, d.depth)
+	str := indent + hex.Dump(buf)
+	str = strings.Replace(str, "\n", "\n"+indent, -1)
+	str = strings.TrimRight(str, d.cs.Indent)
+	d.w.Write([]byte(str))
+
+	// Display the number of bytes cut off by the MaxHexDumpBytes option.
+	if remaining > 0 {
+		d.w.Write([]byte(indent))
+		printMore(d.cs, d.w, remaining)
+		d.w.Write(newlineBytes)
+	}
+}
+
+// dumpEncodedBytes displays the passed bytes on a single line in the encoding
+// specified by the BytesEncoding option.  The MaxHexDumpBytes option limits
+// the number of bytes which are encoded exactly the same as for hexdumps.
+func (d *dumpState) dumpEncodedBytes(buf []byte) {
+	remaining := 0
+	if d.cs.MaxHexDumpBytes > 0 && len(buf) > d.cs.MaxHexDumpBytes {
+		remaining = len(buf) - d.cs.MaxHexDumpBytes
+		buf = buf[:d.cs.MaxHexDumpBytes]
+	}
+
+	switch d.cs.BytesEncoding {
+	case Base64BytesEncoding:
+		d.w.Write(base64Bytes)
+		d.w.Write(spaceBytes)
+		d.w.Write([]byte(strconv.Quote(base64.StdEncoding.EncodeToString(buf))))
+	case HexStringBytesEncoding:
+		d.w.Write(hexBytes)
+		d.w.Write(spaceBytes)
+		d.w.Write([]byte(strconv.Quote(hex.EncodeToString(buf))))
+	default:
+		d.w.Write([]byte(strconv.Quote(string(buf))))
+	}
+
+	if remaining > 0 {
+		d.w.Write(spaceBytes)
+		printMore(d.cs, d.w, remaining)
+	}
+}
+
+// sliceBytes returns the bytes of the passed array or slice when its elements
+// are bytes, including C char types, along with whether they are.  It uses the
+// underlying data when possible and otherwise converts and copies the
+// elements.
+func sliceBytes(v reflect.Value) ([]byte, bool) {
+	// Try to use the underlying data first, then fall back to trying to
+	// convert the elements to a uint8 slice.
 	var buf []uint8
 	doConvert := false
-	doHexDump := false
+	converted := false
 	numEntries := v.Len()
 	if numEntries > 0 {
 		vt := v.Index(0).Type()
@@ -199,9 +817,7 @@ func (d *dumpState) dumpSlice(v reflect.Value) {
 				// type asserted.
 				iface := vs.Interface()
 				if slice, ok := iface.([]uint8); ok {
-					buf = slice
-					doHexDump = true
-					break
+					return slice, true
 				}
 			}
 
@@ -219,29 +835,359 @@ func (d *dumpState) dumpSlice(v reflect.Value) {
 				vv := v.Index(i)
 				buf[i] = uint8(vv.Convert(uint8Type).Uint())
 			}
-			doHexDump = true
+			converted = true
+		}
+	}
+
+	return buf, converted
+}
+
+// dumpMethodSet displays the exported methods of the passed struct type, one
+// per line, as comments with their signatures.  The methods in the method set
+// of the type itself are listed first with a value receiver followed by those
+// which are only in the method set of a pointer to it with a pointer receiver.
+func (d *dumpState) dumpMethodSet(t reflect.Type) {
+	name := typeName(d.cs, t)
+	pt := reflect.PtrTo(t)
+	for i := 0; i < pt.NumMethod(); i++ {
+		m := pt.Method(i)
+		if _, ok := t.MethodByName(m.Name); !ok {
+			continue
+		}
+		d.indent()
+		fmt.Fprintf(d.w, "// func (%s) %s\n", name, methodSignature(d.cs, m))
+	}
+	for i := 0; i < pt.NumMethod(); i++ {
+		m := pt.Method(i)
+		if _, ok := t.MethodByName(m.Name); ok {
+			continue
+		}
+		d.indent()
+		fmt.Fprintf(d.w, "// func (*%s) %s\n", name, methodSignature(d.cs, m))
+	}
+}
+
+// dumpChanContents displays the elements buffered in the passed channel after
+// its address according to the DumpChannelContents and UnsafeChannelDrain
+// options.  The elements are received from the channel and sent back to it
+// before any of them are displayed so the channel is left in its original state
+// for as short a time as possible.
+func (d *dumpState) dumpChanContents(v reflect.Value) {
+	elems, lost := drainChannel(v)
+	if len(elems) == 0 {
+		return
+	}
+
+	d.w.Write(spaceBytes)
+	d.w.Write(openBraceNewlineBytes)
+	d.depth++
+	for i, elem := range elems {
+		d.dump(d.unpackValue(elem))
+		if i < len(elems)-1 {
+			d.w.Write(commaNewlineBytes)
+		} else {
+			d.w.Write(newlineBytes)
 		}
 	}
+	d.depth--
+	d.indent()
+	d.w.Write(closeBraceBytes)
+	if lost > 0 {
+		d.w.Write(spaceBytes)
+		d.w.Write(openAngleBytes)
+		printInt(d.w, int64(lost), 10)
+		d.w.Write(notRestoredBytes)
+	}
+}
 
+// dumpFieldGroup separates the passed struct field from the previous one with a
+// blank line when its group, as set by its spewgroup tag, differs from the
+// passed group of the previous field, and precedes it with a comment naming
+// its group, if any, according to the GroupFields option.  It returns the
+// group of the field.
+func (d *dumpState) dumpFieldGroup(field reflect.StructField, first bool, prev string) string {
+	group := fieldGroup(field)
+	if group == prev {
+		return group
+	}
+	if !first {
+		d.w.Write(newlineBytes)
+	}
+	if group != "" {
+		d.indent()
+		d.w.Write(groupCommentBytes)
+		d.w.Write([]byte(group))
+		d.w.Write(newlineBytes)
+	}
+	return group
+}
+
+// dumpSlice handles formatting of arrays and slices.  Byte (uint8 under
+// reflection) arrays and slices are dumped in hexdump -C fashion.
+//
+// The caller is expected to have rejected slices whose headers are corrupt via
+// isCorruptSlice.  The length is read once and, should an element still turn
+// out to be inaccessible, the remaining elements are replaced by a corrupt
+// slice marker.
+func (d *dumpState) dumpSlice(v reflect.Value) {
 	// Hexdump the entire slice as needed.
-	if doHexDump {
-		indent := strings.Repeat(d.cs.Indent, d.depth)
-		str := indent + hex.Dump(buf)
-		str = strings.Replace(str, "\n", "\n"+indent, -1)
-		str = strings.TrimRight(str, d.cs.Indent)
-		d.w.Write([]byte(str))
+	if buf, ok := sliceBytes(v); ok {
+		d.dumpHex(buf)
 		return
 	}
 
 	// Recursively call dump for each item.
-	for i := 0; i < numEntries; i++ {
-		d.dump(d.unpackValue(v.Index(i)))
-		if i < (numEntries - 1) {
+	numEntries := v.Len()
+	shown := numShown(d.cs, numEntries)
+	_, collapse := collapsedElemType(d.cs, v)
+	if d.cs.RunLengthEncode {
+		if !d.dumpRuns(v, shown, numEntries, collapse) {
+			d.dumpCorruptSlice()
+			return
+		}
+	} else {
+		width := indexWidth(shown)
+		for i := 0; i < shown; i++ {
+			elem, ok := sliceElem(v, i)
+			if !ok {
+				d.dumpCorruptSlice()
+				return
+			}
+			d.dumpDynamicType(elem)
+			d.dumpIndex(i, width)
+			if collapse {
+				d.indent()
+				d.ignoreNextType = true
+			}
+			d.dump(d.unpackValue(elem))
+			if i < (numEntries - 1) {
+				d.w.Write(commaNewlineBytes)
+			} else {
+				d.w.Write(newlineBytes)
+			}
+		}
+	}
+	if shown < numEntries {
+		d.indent()
+		printMore(d.cs, d.w, numEntries-shown)
+		d.w.Write(newlineBytes)
+	}
+}
+
+// dumpCorruptSlice displays the corrupt slice marker on its own line in place
+// of the elements of an array or slice which can't be accessed.
+func (d *dumpState) dumpCorruptSlice() {
+	d.ignoreNextType = false
+	d.ignoreNextIndent = false
+	d.indent()
+	d.w.Write(corruptSliceBytes)
+	d.w.Write(newlineBytes)
+}
+
+// dumpDynamicType displays the dynamic type of the passed array, slice, or map
+// element on its own line when enabled by the HighlightHeterogeneous option
+// and the static type of the element is an interface.
+func (d *dumpState) dumpDynamicType(v reflect.Value) {
+	if !d.cs.HighlightHeterogeneous || v.Kind() != reflect.Interface {
+		return
+	}
+	d.indent()
+	d.w.Write(dynamicTypeBytes)
+	if v.IsNil() {
+		d.w.Write(nilAngleBytes)
+	} else {
+		d.w.Write([]byte(typeName(d.cs, v.Elem().Type())))
+	}
+	d.w.Write(dynamicTypeCloseBytes)
+}
+
+// collapsedElemType returns the type shared by all elements of the passed array
+// or slice, which is the dynamic type of the elements for interface element
+// types, and whether the type annotations of the elements should be omitted in
+// favor of it according to the CollapseRepeatedTypes option.  Interface
+// elements which hold differing types or nil keep their annotations.
+func collapsedElemType(cs *ConfigState, v reflect.Value) (reflect.Type, bool) {
+	if !cs.CollapseRepeatedTypes || v.Len() == 0 {
+		return nil, false
+	}
+	et := v.Type().Elem()
+	switch et.Kind() {
+	case reflect.Ptr:
+		return nil, false
+	case reflect.Interface:
+	default:
+		return et, true
+	}
+	var t reflect.Type
+	for i := 0; i < v.Len(); i++ {
+		e := v.Index(i)
+		if e.IsNil() || (t != nil && e.Elem().Type() != t) ||
+			e.Elem().Kind() == reflect.Ptr {
+
+			return nil, false
+		}
+		t = e.Elem().Type()
+	}
+	return t, true
+}
+
+// indexWidth returns the number of digits in the largest index of the passed
+// number of shown elements which is used to align them for the
+// ShowSliceIndices option.
+func indexWidth(shown int) int {
+	if shown < 1 {
+		return 1
+	}
+	return len(strconv.Itoa(shown - 1))
+}
+
+// dumpIndex displays the passed index of an array or slice element right
+// aligned to the passed width, such as [ 7], when enabled by the
+// ShowSliceIndices option.
+func (d *dumpState) dumpIndex(i, width int) {
+	if !d.cs.ShowSliceIndices {
+		return
+	}
+	d.indent()
+	d.w.Write(openBracketBytes)
+	index := strconv.Itoa(i)
+	d.w.Write(bytes.Repeat(spaceBytes, width-len(index)))
+	d.w.Write([]byte(index))
+	d.w.Write(closeBracketBytes)
+	d.w.Write(spaceBytes)
+	d.ignoreNextIndent = true
+}
+
+// minRunLength is the minimum number of consecutive identical elements which
+// are collapsed into a single element by the RunLengthEncode option.
+const minRunLength = 3
+
+// dumpRuns handles formatting of the first shown elements out of the total
+// number of elements of the passed array or slice when the RunLengthEncode
+// option is enabled.  Each element is rendered first so that runs of elements
+// with identical output can be collapsed into a single element followed by the
+// length of the run.  Runs are labeled with the index of their first element
+// by the ShowSliceIndices option.  The type annotations of the elements are
+// omitted when the passed collapse flag is set.  It returns false when an
+// element can't be accessed, in which case only the preceding elements have
+// been displayed.
+func (d *dumpState) dumpRuns(v reflect.Value, shown, numEntries int, collapse bool) bool {
+	elems := make([]reflect.Value, 0, shown)
+	rendered := make([][]byte, 0, shown)
+	ok := true
+	for i := 0; i < shown; i++ {
+		var elem reflect.Value
+		if elem, ok = sliceElem(v, i); !ok {
+			break
+		}
+		var buf bytes.Buffer
+		ed := *d
+		ed.w = &buf
+		ed.ignoreNextIndent = true
+		ed.ignoreNextType = collapse
+		ed.dump(ed.unpackValue(elem))
+		d.cycles = ed.cycles
+		elems = append(elems, elem)
+		rendered = append(rendered, buf.Bytes())
+	}
+	shown = len(rendered)
+
+	width := indexWidth(shown)
+	for i := 0; i < shown; {
+		run := 1
+		for i+run < shown && bytes.Equal(rendered[i+run], rendered[i]) {
+			run++
+		}
+		if run < minRunLength {
+			run = 1
+		}
+		d.dumpDynamicType(elems[i])
+		d.dumpIndex(i, width)
+		d.indent()
+		d.w.Write(rendered[i])
+		if run > 1 {
+			d.w.Write(spaceBytes)
+			d.w.Write(openParenBytes)
+			if d.cs.ASCIIOnly {
+				d.w.Write(asciiTimesBytes)
+			} else {
+				d.w.Write(timesBytes)
+			}
+			printInt(d.w, int64(run), 10)
+			d.w.Write(closeParenBytes)
+		}
+		i += run
+		if i < numEntries {
 			d.w.Write(commaNewlineBytes)
 		} else {
 			d.w.Write(newlineBytes)
 		}
 	}
+	return ok
+}
+
+// dumpIter handles formatting of the sequence of key and value pairs provided
+// by an iterator.
+func (d *dumpState) dumpIter(seq func(yield func(k, v interface{}) bool)) {
+	d.w.Write(openBraceNewlineBytes)
+	d.depth++
+	if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
+		d.indent()
+		printMore(d.cs, d.w, -1)
+		d.w.Write(newlineBytes)
+		emitEvent(d.cs, TruncateEvent, nil, d.depth, "MaxDepth")
+	} else {
+		n, truncated := 0, false
+		seq(func(key, val interface{}) bool {
+			if d.cs.MaxElements != 0 && n >= d.cs.MaxElements {
+				truncated = true
+				return false
+			}
+			if n > 0 {
+				d.w.Write(commaNewlineBytes)
+			}
+			d.dump(d.unpackValue(reflect.ValueOf(&key).Elem()))
+			d.w.Write(mapSeparator(d.cs, colonSpaceBytes))
+			d.ignoreNextIndent = true
+			d.dump(d.unpackValue(reflect.ValueOf(&val).Elem()))
+			n++
+			return true
+		})
+		if truncated {
+			d.w.Write(commaNewlineBytes)
+			d.indent()
+			printMore(d.cs, d.w, -1)
+		}
+		if n > 0 {
+			d.w.Write(newlineBytes)
+		}
+	}
+	d.depth--
+	d.indent()
+	d.w.Write(closeBraceBytes)
+}
+
+// dumpCounts writes the element count of the passed value, along with its
+// capacity for arrays and slices, following its type when the value is an
+// array, map, or slice.  The counts are displayed even when they are zero.
+// It returns whether the counts were written.
+func (d *dumpState) dumpCounts(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice:
+		d.w.Write(spaceBytes)
+		d.w.Write(lenEqualsBytes)
+		printInt(d.w, int64(v.Len()), 10)
+		d.w.Write(spaceBytes)
+		d.w.Write(capEqualsBytes)
+		printInt(d.w, int64(v.Cap()), 10)
+		return true
+	case reflect.Map:
+		d.w.Write(spaceBytes)
+		d.w.Write(lenEqualsBytes)
+		printInt(d.w, int64(v.Len()), 10)
+		return true
+	}
+	return false
 }
 
 // dump is the main workhorse for dumping a value.  It uses the passed reflect
@@ -249,30 +1195,98 @@ func (d *dumpState) dumpSlice(v reflect.Value) {
 // appropriately.  It is a recursive function, however circular data structures
 // are detected and handled properly.
 func (d *dumpState) dump(v reflect.Value) {
-	// Handle invalid reflect values immediately.
+	// Stop dumping as soon as writing to the underlying writer fails.
+	if dw, ok := d.w.(*dumpWriter); ok && dw.err != nil {
+		panic(dumpAbort{})
+	}
+
+	// Handle invalid reflect values immediately.  This includes values of
+	// type reflect.Value which don't hold a value.
+	v = unpackReflectValue(v)
 	kind := v.Kind()
 	if kind == reflect.Invalid {
+		d.indent()
+		d.ignoreNextType = false
 		d.w.Write(invalidAngleBytes)
 		return
 	}
 
+	// Give the Transform option a chance to replace the value.
+	v, omitted, replaced := transform(d.cs, v)
+	kind = v.Kind()
+	emitEvent(d.cs, EnterEvent, v.Type(), d.depth, "")
+
 	// Handle pointers specially.
-	if kind == reflect.Ptr {
+	if kind == reflect.Ptr && !omitted {
+		d.ignoreNextType = false
 		d.indent()
 		d.dumpPtr(v)
 		return
 	}
 
-	// Print type information unless already handled elsewhere.
+	// Print type information unless already handled elsewhere.  The element
+	// counts of containers are displayed along with it when enabled.
+	counted := false
 	if !d.ignoreNextType {
 		d.indent()
 		d.w.Write(openParenBytes)
-		d.w.Write([]byte(v.Type().String()))
+		d.w.Write([]byte(typeName(d.cs, v.Type())))
+		d.w.Write([]byte(typeChain(d.cs, v.Type())))
+		if d.cs.ShowContainerCounts && !omitted {
+			counted = d.dumpCounts(v)
+		}
 		d.w.Write(closeParenBytes)
 		d.w.Write(spaceBytes)
 	}
 	d.ignoreNextType = false
 
+	if replaced {
+		printVia(d.cs, d.w, "Transform")
+	}
+
+	// Display the marker for values omitted by the Transform option.
+	if omitted {
+		d.w.Write(omittedAngleBytes)
+		return
+	}
+
+	// Detect logical cycles of values which provide their own identity.
+	if token, ok := identityToken(v); ok {
+		if d.identities[token] {
+			emitEvent(d.cs, CycleEvent, v.Type(), d.depth, "SpewIdentity")
+			checkCycles(d.cs, &d.cycles)
+			printCycleMarker(d.cs, d.w, d.cycles, circularBytes)
+			return
+		}
+		if d.identities == nil {
+			d.identities = make(map[interface{}]bool)
+		}
+		d.identities[token] = true
+		defer delete(d.identities, token)
+	}
+
+	// Detect maps and slices which contain themselves.
+	if token, ok := containerToken(v); ok {
+		if d.identities[token] {
+			emitEvent(d.cs, CycleEvent, v.Type(), d.depth, "")
+			checkCycles(d.cs, &d.cycles)
+			printCycleMarker(d.cs, d.w, d.cycles, circularBytes)
+			return
+		}
+		if d.identities == nil {
+			d.identities = make(map[interface{}]bool)
+		}
+		d.identities[token] = true
+		defer delete(d.identities, token)
+	}
+
+	// Display network addresses via their String methods when enabled.  The
+	// length and capacity of address slices are omitted since the text is
+	// far more meaningful.
+	if handled := handleNetAddr(d.cs, d.w, v); handled {
+		return
+	}
+
 	// Display length and capacity if the built-in len and cap functions
 	// work with the value's kind and the len/cap itself is non-zero.
 	valueLen, valueCap := 0, 0
@@ -282,7 +1296,7 @@ func (d *dumpState) dump(v reflect.Value) {
 	case reflect.Map, reflect.String:
 		valueLen = v.Len()
 	}
-	if valueLen != 0 || valueCap != 0 {
+	if !counted && (valueLen != 0 || valueCap != 0) {
 		d.w.Write(openParenBytes)
 		if valueLen != 0 {
 			d.w.Write(lenEqualsBytes)
@@ -299,16 +1313,88 @@ func (d *dumpState) dump(v reflect.Value) {
 		d.w.Write(spaceBytes)
 	}
 
+	// Display backing array sharing for slices when enabled.
+	if d.cs.DetectSliceAliasing && kind == reflect.Slice {
+		d.dumpSliceAliasing(v)
+	}
+
+	// Display durations in their human readable form when enabled.
+	if handled := handleDuration(d.cs, d.w, v); handled {
+		return
+	}
+
+	// Display times in the configured layout when enabled.
+	if handled := handleTime(d.cs, d.w, v); handled {
+		return
+	}
+
+	// Display registered bitmasks as their set flags.
+	if handled := handleBitflags(d.cs, d.w, v); handled {
+		return
+	}
+
+	// Display geometry and color types compactly when enabled.
+	if handled := handleImage(d.cs, d.w, v); handled {
+		return
+	}
+
+	// Display the pointees of weak pointers when enabled.
+	if ptr, ok := weakPointee(d.cs, v); ok {
+		printVia(d.cs, d.w, "ShowRuntimeState")
+		if ptr.IsNil() {
+			d.w.Write(collectedAngleBytes)
+			return
+		}
+		d.ignoreNextIndent = true
+		d.dump(ptr)
+		return
+	}
+
+	// Display the driver values of database types when enabled.
+	if val, ok := valuerValue(d.cs, v); ok {
+		printVia(d.cs, d.w, "Valuer")
+		d.w.Write(valuerBytes)
+		d.w.Write(spaceBytes)
+		if val == nil {
+			d.w.Write(nullAngleBytes)
+			return
+		}
+		d.ignoreNextIndent = true
+		d.dump(reflect.ValueOf(val))
+		return
+	}
+
+	// Display the elements of iterators when enabled.
+	if seq, ok := iterSeq(d.cs, v); ok {
+		printVia(d.cs, d.w, "SpewIterator")
+		d.dumpIter(seq)
+		return
+	}
+
 	// Call Stringer/error interfaces if they exist and the handle methods flag
 	// is enabled
-	if !d.cs.DisableMethods {
+	if !d.cs.DisableMethods && !isExpandedProto(d.cs, v.Type()) {
 		if (kind != reflect.Invalid) && (kind != reflect.Interface) {
-			if handled := handleMethods(d.cs, d.w, v); handled {
+			mcs := methodConfig(d.cs, v, d.depth)
+			if handled := handleMethods(mcs, d.w, v, d.methods); handled {
+				emitEvent(d.cs, MethodEvent, v.Type(), d.depth, "")
 				return
 			}
 		}
 	}
 
+	// Display the value via the handler for its kind when one is set.
+	if handled := handleKind(d.cs, d.w, v); handled {
+		return
+	}
+
+	// Render the content of structs, arrays, slices, and maps into a buffer
+	// when hashing is enabled so the hash can be displayed ahead of it.
+	if d.cs.HashNodes && isHashNode(v) {
+		defer d.enterHash()()
+	}
+
+	printVia(d.cs, d.w, "reflection")
 	switch kind {
 	case reflect.Invalid:
 		// Do nothing.  We should never get here since invalid has already
@@ -318,36 +1404,90 @@ func (d *dumpState) dump(v reflect.Value) {
 		printBool(d.w, v.Bool())
 
 	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
-		printInt(d.w, v.Int(), 10)
+		if d.cs.ShowRunes && v.Type() == runeType {
+			printRune(d.w, v.Int())
+			break
+		}
+		printIntValue(d.cs, d.w, v.Int())
 
 	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-		printUint(d.w, v.Uint(), 10)
+		printUintValue(d.cs, d.w, v.Uint())
 
 	case reflect.Float32:
-		printFloat(d.w, v.Float(), 32)
+		printFloatValue(d.cs, d.w, v.Float(), 32)
 
 	case reflect.Float64:
-		printFloat(d.w, v.Float(), 64)
+		printFloatValue(d.cs, d.w, v.Float(), 64)
 
 	case reflect.Complex64:
-		printComplex(d.w, v.Complex(), 32)
+		printComplex(d.w, v.Complex(), floatFormat(d.cs), 32)
 
 	case reflect.Complex128:
-		printComplex(d.w, v.Complex(), 64)
+		printComplex(d.w, v.Complex(), floatFormat(d.cs), 64)
 
 	case reflect.Slice:
 		if v.IsNil() {
 			d.w.Write(nilAngleBytes)
 			break
 		}
+		if isCorruptSlice(v) {
+			d.w.Write(corruptSliceBytes)
+			break
+		}
 		fallthrough
 
 	case reflect.Array:
+		// Display arrays and slices of runes as the text they represent
+		// when enabled.
+		if text, ok := runeSliceText(d.cs, v); ok {
+			d.w.Write(openParenBytes)
+			d.w.Write(openBracketBytes)
+			if kind == reflect.Array {
+				printInt(d.w, int64(v.Len()), 10)
+			}
+			d.w.Write(closeBracketBytes)
+			d.w.Write(runeSliceBytes)
+			d.w.Write(closeParenBytes)
+			d.w.Write(spaceBytes)
+			printText(d.cs, d.w, text, true)
+			break
+		}
+
+		// Display empty arrays and slices tersely when enabled.
+		if d.cs.OmitEmptyContainers && v.Len() == 0 {
+			d.w.Write(openBracketBytes)
+			d.w.Write(closeBracketBytes)
+			break
+		}
+
+		// Display the common dynamic type of interface elements once when
+		// their annotations are collapsed.
+		if et, ok := collapsedElemType(d.cs, v); ok &&
+			v.Type().Elem().Kind() == reflect.Interface {
+
+			d.w.Write(openParenBytes)
+			d.w.Write(elemEqualsBytes)
+			d.w.Write([]byte(typeName(d.cs, et)))
+			d.w.Write(closeParenBytes)
+			d.w.Write(spaceBytes)
+		}
+
+		// Display bytes on a single line in the configured encoding
+		// instead of as a hexdump.
+		if d.cs.BytesEncoding != HexDumpBytesEncoding {
+			if buf, ok := sliceBytes(v); ok {
+				d.dumpEncodedBytes(buf)
+				break
+			}
+		}
+
 		d.w.Write(openBraceNewlineBytes)
 		d.depth++
 		if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
 			d.indent()
-			d.w.Write(maxNewlineBytes)
+			printMore(d.cs, d.w, v.Len())
+			d.w.Write(newlineBytes)
+			emitEvent(d.cs, TruncateEvent, v.Type(), d.depth, "MaxDepth")
 		} else {
 			d.dumpSlice(v)
 		}
@@ -356,7 +1496,7 @@ func (d *dumpState) dump(v reflect.Value) {
 		d.w.Write(closeBraceBytes)
 
 	case reflect.String:
-		d.w.Write([]byte(strconv.Quote(v.String())))
+		printText(d.cs, d.w, v.String(), true)
 
 	case reflect.Interface:
 		// The only time we should get here is for nil interfaces due to
@@ -376,54 +1516,130 @@ func (d *dumpState) dump(v reflect.Value) {
 			break
 		}
 
+		// Clip maps nested deeper than allowed by the MaxMapDepth option.
+		if d.cs.MaxMapDepth != 0 && d.mapDepth >= d.cs.MaxMapDepth {
+			emitEvent(d.cs, TruncateEvent, v.Type(), d.depth, "MaxMapDepth")
+			d.w.Write(openBraceBytes)
+			printMore(d.cs, d.w, v.Len())
+			d.w.Write(closeBraceBytes)
+			break
+		}
+
+		// Display empty maps tersely when enabled.
+		if d.cs.OmitEmptyContainers && v.Len() == 0 {
+			d.w.Write(openBraceBytes)
+			d.w.Write(closeBraceBytes)
+			break
+		}
+
+		// Display small maps of scalars inline when enabled.
+		if d.dumpCompactMap(v) {
+			break
+		}
+
 		d.w.Write(openBraceNewlineBytes)
 		d.depth++
+		d.mapDepth++
 		if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
 			d.indent()
-			d.w.Write(maxNewlineBytes)
+			printMore(d.cs, d.w, v.Len())
+			d.w.Write(newlineBytes)
+			emitEvent(d.cs, TruncateEvent, v.Type(), d.depth, "MaxDepth")
 		} else {
 			numEntries := v.Len()
 			keys := v.MapKeys()
-			if d.cs.SortKeys {
-				sortValues(keys, d.cs)
-			}
+			sortMapKeys(keys, d.cs)
+			keys = keys[:numShown(d.cs, numEntries)]
+			kcs := keyConfig(d.cs)
 			for i, key := range keys {
-				d.dump(d.unpackValue(key))
-				d.w.Write(colonSpaceBytes)
+				d.dumpDynamicType(v.MapIndex(key))
+				d.dumpMapKey(kcs, d.unpackValue(key))
+				d.w.Write(mapSeparator(d.cs, colonSpaceBytes))
 				d.ignoreNextIndent = true
-				d.dump(d.unpackValue(v.MapIndex(key)))
+				if isNilMapValue(d.cs, v.MapIndex(key)) {
+					d.w.Write(nilValueAngleBytes)
+				} else {
+					d.dump(d.unpackValue(v.MapIndex(key)))
+				}
 				if i < (numEntries - 1) {
 					d.w.Write(commaNewlineBytes)
 				} else {
 					d.w.Write(newlineBytes)
 				}
 			}
+			if len(keys) < numEntries {
+				d.indent()
+				printMore(d.cs, d.w, numEntries-len(keys))
+				d.w.Write(newlineBytes)
+			}
 		}
+		d.mapDepth--
 		d.depth--
 		d.indent()
 		d.w.Write(closeBraceBytes)
 
 	case reflect.Struct:
+		// Display structs as key=value pairs when enabled.
+		if d.dumpKeyValueStruct(v) {
+			break
+		}
+
 		d.w.Write(openBraceNewlineBytes)
 		d.depth++
 		if (d.cs.MaxDepth != 0) && (d.depth > d.cs.MaxDepth) {
 			d.indent()
-			d.w.Write(maxNewlineBytes)
+			printMore(d.cs, d.w, v.NumField())
+			d.w.Write(newlineBytes)
+			emitEvent(d.cs, TruncateEvent, v.Type(), d.depth, "MaxDepth")
 		} else {
 			vt := v.Type()
-			numFields := v.NumField()
-			for i := 0; i < numFields; i++ {
+			fields := visibleFields(d.cs, v, d.unfiltered, &d.matches)
+			if d.zeroFields != nil {
+				fields = d.nonZeroFields(v, fields)
+			}
+			numFields := len(fields)
+			group := ""
+			for i, fieldIndex := range fields {
+				vtf := vt.Field(fieldIndex)
+				if d.cs.GroupFields {
+					group = d.dumpFieldGroup(vtf, i == 0, group)
+				}
 				d.indent()
-				vtf := vt.Field(i)
-				d.w.Write([]byte(vtf.Name))
+				if d.cs.MarkEmbedded && vtf.Anonymous {
+					d.w.Write(openParenBytes)
+					d.w.Write(embeddedBytes)
+					if vtf.Type.Kind() == reflect.Ptr {
+						d.w.Write(spaceBytes)
+						d.w.Write([]byte(typeName(d.cs, vtf.Type)))
+					}
+					d.w.Write(closeParenBytes)
+					d.w.Write(spaceBytes)
+				}
+				d.w.Write([]byte(fieldName(d.cs, vtf)))
 				d.w.Write(colonSpaceBytes)
 				d.ignoreNextIndent = true
-				d.dump(d.unpackValue(v.Field(i)))
+				unfiltered := d.unfiltered
+				d.unfiltered = unfiltered || fieldNameMatches(d.cs, vtf)
+				d.dump(d.unpackValue(v.Field(fieldIndex)))
+				d.unfiltered = unfiltered
+				doc := fieldDoc(d.cs, vtf)
+				if doc == "" {
+					if i < (numFields - 1) {
+						d.w.Write(commaNewlineBytes)
+					} else {
+						d.w.Write(newlineBytes)
+					}
+					continue
+				}
 				if i < (numFields - 1) {
-					d.w.Write(commaNewlineBytes)
-				} else {
-					d.w.Write(newlineBytes)
+					d.w.Write(commaBytes)
 				}
+				d.w.Write(docCommentBytes)
+				d.w.Write([]byte(doc))
+				d.w.Write(newlineBytes)
+			}
+			if d.cs.ShowMethodSet {
+				d.dumpMethodSet(vt)
 			}
 		}
 		d.depth--
@@ -433,8 +1649,17 @@ func (d *dumpState) dump(v reflect.Value) {
 	case reflect.Uintptr:
 		printHexPtr(d.w, uintptr(v.Uint()))
 
-	case reflect.UnsafePointer, reflect.Chan, reflect.Func:
-		printHexPtr(d.w, v.Pointer())
+	case reflect.UnsafePointer:
+		printAddr(d.cs, d.w, d.fakeAddrs, v.Pointer())
+
+	case reflect.Chan:
+		printAddr(d.cs, d.w, d.fakeAddrs, v.Pointer())
+		if d.cs.DumpChannelContents && d.cs.UnsafeChannelDrain {
+			d.dumpChanContents(v)
+		}
+
+	case reflect.Func:
+		printFunc(d.cs, d.w, v)
 
 	// There were not any other types at the time this code was written, but
 	// fall back to letting the default fmt package handle it in case any new
@@ -448,39 +1673,310 @@ func (d *dumpState) dump(v reflect.Value) {
 	}
 }
 
+// dumpTop dumps the passed top-level value while handling any aborts that
+// occur along the way.
+func (d *dumpState) dumpTop(v reflect.Value) {
+	defer recoverAbort(d.w)
+	if d.cs.CollapsePointerChains || d.cs.FlattenPointers {
+		d.pointerRefs = make(map[uintptr]int)
+		countPointerRefs(d.cs, v, d.pointerRefs)
+	}
+	d.dump(v)
+}
+
 // fdump is a helper function to consolidate the logic from the various public
-// methods which take varying writers and config states.
-func fdump(cs *ConfigState, w io.Writer, a ...interface{}) {
-	for _, arg := range a {
-		if arg == nil {
-			w.Write(interfaceBytes)
-			w.Write(spaceBytes)
-			w.Write(nilAngleBytes)
-			w.Write(newlineBytes)
-			continue
+// methods which take varying writers and config states.  It returns the number
+// of bytes written and the first write error encountered, if any.
+func fdump(cs *ConfigState, w io.Writer, a ...interface{}) (n int, err error) {
+	values := make([]reflect.Value, len(a))
+	for i, arg := range a {
+		values[i] = reflect.ValueOf(arg)
+	}
+	return fdumpValues(cs, w, values)
+}
+
+// fdumpValues dumps the passed values, which have already been obtained via
+// reflection, exactly the same as fdump does the arguments they hold.  Invalid
+// values are displayed as nil interfaces.
+func fdumpValues(cs *ConfigState, w io.Writer, values []reflect.Value) (n int, err error) {
+	out := wrapWriter(cs, w)
+	dw := newDumpWriter(cs, out)
+	defer func() {
+		// Write any output still buffered for line wrapping before
+		// propagating panics so callers which recover them, such as
+		// SafeSdump, receive everything produced up to that point.
+		if err := recover(); err != nil {
+			dw.finish()
+			panic(err)
+		}
+	}()
+	slices := make([]sliceBacking, 0)
+	fakeAddrs := make(map[uintptr]uintptr)
+	methods := newMethodCache(cs)
+	if cs.IncludeCaller {
+		printCaller(dw)
+	}
+	if cs.PrintLegend {
+		dw.legend = &legendTracker{used: make([]bool, len(legendEntries))}
+	}
+	for i, v := range values {
+		if dw.err != nil {
+			break
+		}
+		if cs.LabelArgs {
+			printArgLabel(dw, i)
 		}
 
-		d := dumpState{w: w, cs: cs}
-		d.pointers = make(map[uintptr]int)
-		d.dump(reflect.ValueOf(arg))
-		d.w.Write(newlineBytes)
+		if !v.IsValid() {
+			dw.Write(interfaceBytes)
+			dw.Write(spaceBytes)
+			dw.Write(nilAngleBytes)
+		} else {
+			d := dumpState{w: dw, cs: cs, slices: &slices,
+				fakeAddrs: fakeAddrs, methods: methods}
+			d.pointers = make(map[uintptr]int)
+			d.dumpTop(v)
+		}
+
+		// Terminate each value with a newline except for the final one
+		// when the trailing newline is suppressed.
+		if i < len(values)-1 || !cs.NoTrailingNewline {
+			dw.Write(newlineBytes)
+		}
+	}
+	if lt := dw.legend; lt != nil {
+		dw.legend = nil
+		printLegend(cs, dw, lt, !cs.NoTrailingNewline)
+	}
+	dw.finish()
+	return dw.n, autoFlush(cs, dw.err, out, w)
+}
+
+// flusher is the interface implemented by buffered writers such as
+// bufio.Writer.
+type flusher interface {
+	Flush() error
+}
+
+// wrapWriter returns the writer the output for w is written through.  It is w
+// itself unless the WriterWrapper option is set.
+func wrapWriter(cs *ConfigState, w io.Writer) io.Writer {
+	if cs.WriterWrapper == nil {
+		return w
+	}
+	if ww := cs.WriterWrapper(w); ww != nil {
+		return ww
+	}
+	return w
+}
+
+// autoFlush flushes the passed writers, in order, when they are buffered and
+// the AutoFlush option is enabled.  The writer returned by the WriterWrapper
+// option is passed ahead of the destination it wraps so any output it holds
+// reaches the destination before that is flushed.  It returns the passed error
+// from writing the output, if any, or otherwise the first error from flushing.
+func autoFlush(cs *ConfigState, err error, ws ...io.Writer) error {
+	if !cs.AutoFlush {
+		return err
+	}
+	for i, w := range ws {
+		if i > 0 && w == ws[i-1] {
+			continue
+		}
+		if f, ok := w.(flusher); ok {
+			if ferr := f.Flush(); err == nil {
+				err = ferr
+			}
+		}
 	}
+	return err
 }
 
 // Fdump formats and displays the passed arguments to io.Writer w.  It formats
-// exactly the same as Dump.
+// exactly the same as Dump.  Buffered writers, such as bufio.Writer, are not
+// flushed unless the AutoFlush option is enabled.
 func Fdump(w io.Writer, a ...interface{}) {
 	fdump(&Config, w, a...)
 }
 
+// FdumpN formats and displays the passed arguments to io.Writer w.  It formats
+// exactly the same as Dump.  It returns the number of bytes written and the
+// first write error encountered, at which point the dump is stopped.  Writers
+// which accept only part of a write without an error are passed the remaining
+// bytes again until they have accepted all of them.
+func FdumpN(w io.Writer, a ...interface{}) (n int, err error) {
+	return fdump(&Config, w, a...)
+}
+
 // Sdump returns a string with the passed arguments formatted exactly the same
 // as Dump.
 func Sdump(a ...interface{}) string {
+	return sdump(&Config, a...)
+}
+
+// Bdump returns a byte slice with the passed arguments formatted exactly the
+// same as Dump.  It avoids the copy Sdump makes to convert the output to a
+// string for callers which write the bytes elsewhere, such as to a file, hash,
+// or network connection.  The caller owns the returned slice.
+func Bdump(a ...interface{}) []byte {
+	return bdump(&Config, a...)
+}
+
+// sdump is a helper function to consolidate the logic from the various public
+// methods which take varying config states.
+func sdump(cs *ConfigState, a ...interface{}) string {
+	return string(bdump(cs, a...))
+}
+
+// bdump is a helper function to consolidate the logic from the various public
+// methods which take varying config states.  The buffer is pre-sized according
+// to the SdumpSizeHint option.
+func bdump(cs *ConfigState, a ...interface{}) []byte {
 	var buf bytes.Buffer
-	fdump(&Config, &buf, a...)
+	if cs.SdumpSizeHint > 0 {
+		buf.Grow(cs.SdumpSizeHint)
+	}
+	fdump(cs, &buf, a...)
+	return buf.Bytes()
+}
+
+// safeSdump is a helper function to consolidate the logic from the various
+// public methods which take varying config states.  Any panic raised while
+// dumping is recovered and returned after marking the output as aborted.
+func safeSdump(cs *ConfigState, v interface{}) (out string, recovered interface{}) {
+	var buf bytes.Buffer
+	if cs.SdumpSizeHint > 0 {
+		buf.Grow(cs.SdumpSizeHint)
+	}
+	defer func() {
+		if recovered = recover(); recovered != nil {
+			buf.Write(dumpAbortedBytes)
+			fmt.Fprintf(&buf, "%v", recovered)
+			buf.Write(closeAngleBytes)
+			out = buf.String()
+		}
+	}()
+	fdump(cs, &buf, v)
+	return buf.String(), nil
+}
+
+// SafeSdump returns a string with the passed argument formatted exactly the
+// same as Dump, but it never panics.  Any panic raised while dumping, such as
+// one from a partially constructed value, is recovered and returned separately
+// while the output produced up to that point is terminated with a
+// <dump aborted: ...> marker.  This makes it suitable for use in recover
+// handlers.  Note that panics from Stringer and error methods are already
+// displayed inline and do not abort the dump.
+func SafeSdump(v interface{}) (out string, recovered interface{}) {
+	return safeSdump(&Config, v)
+}
+
+// FdumpTo formats the passed arguments exactly the same as Dump and appends the
+// result to the passed strings.Builder.  This avoids the intermediate copy
+// made by Sdump when accumulating the output of several dumps.
+func FdumpTo(sb *strings.Builder, a ...interface{}) {
+	fdump(&Config, sb, a...)
+}
+
+// fdumpBytes is a helper function to consolidate the logic from the various
+// public functions which dump the raw memory of a value.
+func fdumpBytes(cs *ConfigState, w io.Writer, v interface{}) {
+	out := wrapWriter(cs, w)
+	dw := newDumpWriter(cs, out)
+	d := dumpState{w: dw, cs: cs}
+	d.dumpRawBytes(reflect.ValueOf(v))
+	if !cs.NoTrailingNewline {
+		dw.Write(newlineBytes)
+	}
+	dw.finish()
+	autoFlush(cs, dw.err, out, w)
+}
+
+// sdumpBytes returns a string with the raw memory representation of the value
+// the passed pointer points to.
+func sdumpBytes(cs *ConfigState, v interface{}) string {
+	var buf bytes.Buffer
+	fdumpBytes(cs, &buf, v)
 	return buf.String()
 }
 
+// dumpRawBytes displays the raw memory of the value the passed pointer points
+// to.  Values which aren't pointers are marked as such since only addressable
+// values have a memory representation which can be read.
+func (d *dumpState) dumpRawBytes(rv reflect.Value) {
+	d.w.Write(openParenBytes)
+	if !rv.IsValid() {
+		d.w.Write(interfaceBytes)
+	} else {
+		d.w.Write([]byte(typeName(d.cs, rv.Type())))
+	}
+	d.w.Write(closeParenBytes)
+	if rv.Kind() != reflect.Ptr {
+		d.w.Write(spaceBytes)
+		d.w.Write(notPointerBytes)
+		return
+	}
+	d.w.Write(openParenBytes)
+	printAddr(d.cs, d.w, make(map[uintptr]uintptr), rv.Pointer())
+	d.w.Write(closeParenBytes)
+	if rv.IsNil() {
+		return
+	}
+
+	buf, ok := rawBytes(rv)
+	if !ok {
+		d.w.Write(spaceBytes)
+		d.w.Write(rawUnavailableBytes)
+		return
+	}
+	d.w.Write(spaceBytes)
+	d.w.Write(openParenBytes)
+	d.w.Write(sizeEqualsBytes)
+	printInt(d.w, int64(len(buf)), 10)
+	d.w.Write(closeParenBytes)
+	d.w.Write(spaceBytes)
+	d.w.Write(openBraceNewlineBytes)
+	d.depth++
+	d.dumpHex(buf)
+	d.depth--
+	d.w.Write(closeBraceBytes)
+}
+
+// FdumpBytes displays the raw memory representation of the value the passed
+// pointer points to like the hexdump -C command to io.Writer w.  See DumpBytes
+// for details.
+func FdumpBytes(w io.Writer, v interface{}) {
+	fdumpBytes(&Config, w, v)
+}
+
+// SdumpBytes returns a string with the raw memory representation of the value
+// the passed pointer points to formatted exactly the same as DumpBytes.
+func SdumpBytes(v interface{}) string {
+	return sdumpBytes(&Config, v)
+}
+
+/*
+DumpBytes displays the raw memory representation of the value the passed pointer
+points to like the hexdump -C command to standard out.  Exactly the number of
+bytes reported by unsafe.Sizeof for the pointed to value are read starting at
+its address, which is useful for understanding things such as struct padding and
+endianness.
+
+v must be a pointer since only addressable values have a memory representation
+that can be read.  Other values are displayed with their type followed by a
+"<not a pointer>" marker and nil pointers are displayed without any bytes.
+
+WARNING: This reads memory directly via the unsafe package.  The bytes reflect
+whatever is in memory at the time, including padding, and pointers contained in
+the value are displayed as raw addresses without being followed.  It is only
+available when the unsafe package is, so it displays a "<raw bytes unavailable>"
+marker instead when running in environments without access to it such as Google
+App Engine or with the "safe" build tag specified.
+*/
+func DumpBytes(v interface{}) {
+	fdumpBytes(&Config, os.Stdout, v)
+}
+
 /*
 Dump displays the passed parameters to standard out with newlines, customizable
 indentation, and additional debug information such as complete types and all
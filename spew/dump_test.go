@@ -62,9 +62,24 @@ base test element are also tested to ensure proper indirection across all types.
 package spew_test
 
 import (
+	"bufio"
 	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
 	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math"
+	"net"
+	"net/url"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 	"unsafe"
 
 	"github.com/dvln/go-spew/spew"
@@ -979,6 +994,13 @@ func TestDump(t *testing.T) {
 	addErrorDumpTests()
 	addCgoDumpTests()
 
+	// The tests display unexported fields, which the global config instance
+	// hides by default.
+	defer func(exportedOnly bool) {
+		spew.Config.ExportedOnly = exportedOnly
+	}(spew.Config.ExportedOnly)
+	spew.Config.ExportedOnly = false
+
 	t.Logf("Running %d tests", len(dumpTests))
 	for i, test := range dumpTests {
 		buf := new(bytes.Buffer)
@@ -1040,3 +1062,3588 @@ func TestDumpSortedKeys(t *testing.T) {
 	}
 
 }
+
+// TestDumpMaxCycleRevisits ensures the dump is aborted once the number of
+// circular references exceeds the MaxCycleRevisits option.
+func TestDumpMaxCycleRevisits(t *testing.T) {
+	type circular struct {
+		c *circular
+	}
+	v := circular{nil}
+	v.c = &v
+	in := []circular{v, v, v}
+
+	tests := []struct {
+		max     int
+		markers int
+		aborted bool
+	}{
+		{0, 3, false},
+		{3, 3, false},
+		{2, 2, true},
+		{1, 1, true},
+	}
+	for i, test := range tests {
+		cfg := spew.ConfigState{Indent: " ", MaxCycleRevisits: test.max}
+		s := cfg.Sdump(in)
+		markers := strings.Count(s, "<already shown>")
+		aborted := strings.HasSuffix(s, "... (too many cycles)\n")
+		if markers != test.markers || aborted != test.aborted {
+			t.Errorf("MaxCycleRevisits #%d got: %s want markers: %d "+
+				"aborted: %v", i, s, test.markers, test.aborted)
+		}
+	}
+}
+
+// failingWriter is an io.Writer that accepts up to limit bytes and then fails
+// all subsequent writes.
+type failingWriter struct {
+	bytes.Buffer
+	limit int
+}
+
+var errFailingWriter = errors.New("failingWriter: write limit reached")
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if fw.Len()+len(p) > fw.limit {
+		n, _ := fw.Buffer.Write(p[:fw.limit-fw.Len()])
+		return n, errFailingWriter
+	}
+	return fw.Buffer.Write(p)
+}
+
+// TestFdumpN ensures FdumpN reports the number of bytes written and stops
+// dumping at the first write error.
+func TestFdumpN(t *testing.T) {
+	in := []string{"one", "two", "three"}
+	full := spew.Sdump(in, in)
+
+	n, err := spew.FdumpN(new(bytes.Buffer), in, in)
+	if n != len(full) || err != nil {
+		t.Errorf("FdumpN got: %d, %v want: %d, <nil>", n, err, len(full))
+	}
+
+	const limit = 20
+	fw := &failingWriter{limit: limit}
+	n, err = spew.FdumpN(fw, in, in)
+	if n != limit || err != errFailingWriter {
+		t.Errorf("FdumpN got: %d, %v want: %d, %v", n, err, limit,
+			errFailingWriter)
+	}
+	if s := fw.String(); s != full[:limit] {
+		t.Errorf("FdumpN got: %q want: %q", s, full[:limit])
+	}
+}
+
+// TestDumpFieldNameFilter ensures only struct fields matching the
+// FieldNameFilter option, and the fields needed to reach them, are dumped.
+func TestDumpFieldNameFilter(t *testing.T) {
+	type pool struct {
+		Size    int
+		Timeout int
+	}
+	type database struct {
+		Name string
+		Pool pool
+	}
+	type config struct {
+		Debug    bool
+		Database database
+		Others   []database
+	}
+	in := config{true, database{"main", pool{10, 30}},
+		[]database{{"other", pool{1, 5}}}}
+
+	cfg := spew.ConfigState{Indent: " ",
+		FieldNameFilter: regexp.MustCompile("^Timeout$")}
+	s := cfg.Sdump(in)
+	expected := "(spew_test.config) {\n" +
+		" Database: (spew_test.database) {\n" +
+		"  Pool: (spew_test.pool) {\n" +
+		"   Timeout: (int) 30\n" +
+		"  }\n" +
+		" },\n" +
+		" Others: ([]spew_test.database) (len=1 cap=1) {\n" +
+		"  (spew_test.database) {\n" +
+		"   Pool: (spew_test.pool) {\n" +
+		"    Timeout: (int) 5\n" +
+		"   }\n" +
+		"  }\n" +
+		" }\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Field name filter mismatch:\n  %v %v", s, expected)
+	}
+
+	// Matching fields are displayed in full.
+	cfg.FieldNameFilter = regexp.MustCompile("^Database$")
+	s = cfg.Sdump(in)
+	expected = "(spew_test.config) {\n" +
+		" Database: (spew_test.database) {\n" +
+		"  Name: (string) (len=4) \"main\",\n" +
+		"  Pool: (spew_test.pool) {\n" +
+		"   Size: (int) 10,\n" +
+		"   Timeout: (int) 30\n" +
+		"  }\n" +
+		" }\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Field name filter mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%+v", in)
+	expected = "{Database:{Name:main Pool:{Size:10 Timeout:30}}}"
+	if s != expected {
+		t.Errorf("Field name filter mismatch:\n  %v %v", s, expected)
+	}
+
+	// Searching for matching fields terminates for slices and maps which
+	// contain themselves.
+	sl := []interface{}{nil}
+	sl[0] = sl
+	m := map[string]interface{}{}
+	m["self"] = m
+	type cyclic struct {
+		Name  string
+		Slice []interface{}
+		Map   map[string]interface{}
+	}
+	cfg.FieldNameFilter = regexp.MustCompile("^Name$")
+	s = cfg.Sdump(cyclic{"c", sl, m})
+	expected = "(spew_test.cyclic) {\n" +
+		" Name: (string) (len=1) \"c\"\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Field name filter mismatch:\n  %v %v", s, expected)
+	}
+
+	// Values reached through a circular reference are still found to hold
+	// matching fields when they are displayed again further down.
+	type loop struct {
+		Next *loop
+		Pool *pool
+	}
+	a := &loop{Pool: &pool{Timeout: 1}}
+	b := &loop{Next: a}
+	a.Next = b
+	lcfg := spew.ConfigState{Indent: " ", StableAddresses: true,
+		FieldNameFilter: regexp.MustCompile("^Timeout$")}
+	s = lcfg.Sdump(b)
+	expected = "(*spew_test.loop)(0x1)({\n" +
+		" Next: (*spew_test.loop)(0x2)({\n" +
+		"  Next: (*spew_test.loop)(0x1)(<already shown>),\n" +
+		"  Pool: (*spew_test.pool)(0x3)({\n" +
+		"   Timeout: (int) 1\n" +
+		"  })\n" +
+		" })\n" +
+		"})\n"
+	if s != expected {
+		t.Errorf("Field name filter mismatch:\n  %v %v", s, expected)
+	}
+
+	// Hidden fields don't make the fields holding them visible.
+	type holder struct {
+		Name   string
+		secret pool
+	}
+	type outer struct {
+		Holder holder
+		Pool   pool
+	}
+	lcfg = spew.ConfigState{Indent: " ", ExportedOnly: true,
+		FieldNameFilter: regexp.MustCompile("^Timeout$")}
+	s = lcfg.Sdump(outer{Pool: pool{1, 2}})
+	expected = "(spew_test.outer) {\n" +
+		" Pool: (spew_test.pool) {\n" +
+		"  Timeout: (int) 2\n" +
+		" }\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Field name filter mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpSliceAliasing ensures slices that share a backing array are
+// annotated when the DetectSliceAliasing option is enabled.
+func TestDumpSliceAliasing(t *testing.T) {
+	backing := []int{1, 2, 3, 4}
+	other := []int{5}
+	cfg := spew.ConfigState{DetectSliceAliasing: true}
+	s := cfg.Sdump(backing[:2], other, backing[3:], []int{}, other[:0])
+	expected := "([]int) (len=2 cap=4) {\n(int) 1,\n(int) 2\n}\n" +
+		"([]int) (len=1 cap=1) {\n(int) 5\n}\n" +
+		"([]int) (len=1 cap=1) [shares backing with #1] {\n(int) 4\n}\n" +
+		"([]int) {\n}\n" +
+		"([]int) (cap=1) [shares backing with #2] {\n}\n"
+	if s != expected {
+		t.Errorf("Slice aliasing mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpCompactSmallMaps ensures small maps of scalars are dumped inline
+// according to the CompactSmallMaps option.
+func TestDumpCompactSmallMaps(t *testing.T) {
+	type maps struct {
+		Small  map[string]int
+		Large  map[string]int
+		Nested map[string][]int
+	}
+	in := maps{
+		Small:  map[string]int{"c": 3, "a": 1, "b": 2},
+		Large:  map[string]int{"a": 1, "b": 2, "c": 3, "d": 4},
+		Nested: map[string][]int{"a": nil},
+	}
+	cfg := spew.ConfigState{Indent: " ", SortKeys: true, CompactSmallMaps: 3}
+	s := cfg.Sdump(in)
+	expected := "(spew_test.maps) {\n" +
+		" Small: (map[string]int) (len=3) {a:1 b:2 c:3},\n" +
+		" Large: (map[string]int) (len=4) {\n" +
+		"  (string) (len=1) \"a\": (int) 1,\n" +
+		"  (string) (len=1) \"b\": (int) 2,\n" +
+		"  (string) (len=1) \"c\": (int) 3,\n" +
+		"  (string) (len=1) \"d\": (int) 4\n" +
+		" },\n" +
+		" Nested: (map[string][]int) (len=1) {\n" +
+		"  (string) (len=1) \"a\": ([]int) <nil>\n" +
+		" }\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Compact small maps mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sdump(map[stringer]bool{})
+	expected = "(map[spew_test.stringer]bool) {}\n"
+	if s != expected {
+		t.Errorf("Compact small maps mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpNoTrailingNewline ensures only the newline after the final value is
+// suppressed when dumping multiple values with the NoTrailingNewline option.
+func TestDumpNoTrailingNewline(t *testing.T) {
+	cfg := spew.ConfigState{NoTrailingNewline: true}
+	s := cfg.Sdump(1, nil, "a")
+	expected := "(int) 1\n(interface {}) <nil>\n(string) (len=1) \"a\""
+	if s != expected {
+		t.Errorf("No trailing newline mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// protoMessage mimics a generated protobuf message including the internal
+// bookkeeping fields and the methods of the proto.Message interface.
+type protoMessage struct {
+	state         struct{ atomicMessageInfo *int }
+	sizeCache     int32
+	unknownFields []byte
+
+	Name string
+	ID   int32
+}
+
+func (m *protoMessage) Reset()                    { *m = protoMessage{} }
+func (m *protoMessage) String() string            { return "Name:" + m.Name }
+func (m *protoMessage) ProtoReflect() interface{} { return m }
+
+// TestDumpProtoMessages ensures only the logical fields of protobuf messages
+// are dumped when the ExpandProtoMessages option is enabled.
+func TestDumpProtoMessages(t *testing.T) {
+	in := &protoMessage{sizeCache: 10, unknownFields: []byte{1}, Name: "x",
+		ID: 5}
+
+	cfg := spew.ConfigState{Indent: " "}
+	s := cfg.Sdump(in)
+	expected := fmt.Sprintf("(*spew_test.protoMessage)(%p)(Name:x)\n", in)
+	if s != expected {
+		t.Errorf("Proto message mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg.ExpandProtoMessages = true
+	s = cfg.Sdump(*in)
+	expected = "(spew_test.protoMessage) {\n" +
+		" Name: (string) (len=1) \"x\",\n" +
+		" ID: (int32) 5\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Proto message mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%+v", in)
+	expected = fmt.Sprintf("<*>(%p){Name:x ID:5}", in)
+	if s != expected {
+		t.Errorf("Proto message mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpTransform ensures values are replaced or omitted according to the
+// Transform option.
+func TestDumpTransform(t *testing.T) {
+	type record struct {
+		ID    string
+		Score float64
+		Ref   *int
+	}
+	ref := 10
+	in := record{"secret", 3.14159, &ref}
+
+	cfg := spew.ConfigState{Indent: " ", Transform: func(v reflect.Value) (reflect.Value, bool) {
+		switch v.Kind() {
+		case reflect.Float64:
+			return reflect.ValueOf(math.Floor(v.Float()*100) / 100), true
+		case reflect.String:
+			return reflect.Value{}, true
+		case reflect.Ptr:
+			return reflect.ValueOf("pointer"), true
+		}
+		return v, false
+	}}
+	s := cfg.Sdump(in)
+	expected := "(spew_test.record) {\n" +
+		" ID: (string) <omitted>,\n" +
+		" Score: (float64) 3.14,\n" +
+		" Ref: (string) (len=7) \"pointer\"\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Transform mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%+v", in)
+	expected = "{ID:<omitted> Score:3.14 Ref:pointer}"
+	if s != expected {
+		t.Errorf("Transform mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpBytes ensures the raw memory representation of values is dumped by
+// the DumpBytes family of functions.
+func TestDumpBytes(t *testing.T) {
+	v := [3]uint8{1, 2, 3}
+	pv := &v
+	s := spew.SdumpBytes(pv)
+	expected := fmt.Sprintf("(*[3]uint8)(%p) (size=3) {\n"+
+		" 00000000  01 02 03                                          |...|\n"+
+		"}\n", pv)
+	if spew.UnsafeDisabled {
+		expected = fmt.Sprintf("(*[3]uint8)(%p) <raw bytes unavailable>\n", pv)
+	}
+	if s != expected {
+		t.Errorf("DumpBytes mismatch:\n  %v %v", s, expected)
+	}
+
+	// Padding is included in the raw memory.
+	type padded struct {
+		a uint8
+		b uint32
+	}
+	pp := &padded{}
+	s = spew.SdumpBytes(pp)
+	expected = fmt.Sprintf("(*spew_test.padded)(%p) (size=8) {\n", pp)
+	if spew.UnsafeDisabled {
+		expected = fmt.Sprintf("(*spew_test.padded)(%p) <raw bytes unavailable>\n", pp)
+	}
+	if !strings.HasPrefix(s, expected) {
+		t.Errorf("DumpBytes mismatch:\n  %v %v", s, expected)
+	}
+
+	// Nil pointers and non-pointers.
+	s = spew.SdumpBytes((*int)(nil))
+	expected = "(*int)(<nil>)\n"
+	if s != expected {
+		t.Errorf("DumpBytes mismatch:\n  %v %v", s, expected)
+	}
+	s = spew.SdumpBytes(5)
+	expected = "(int) <not a pointer>\n"
+	if s != expected {
+		t.Errorf("DumpBytes mismatch:\n  %v %v", s, expected)
+	}
+
+	// The output is written through the same writers as Dump.
+	cs := spew.ConfigState{NoTrailingNewline: true,
+		WriterWrapper: func(w io.Writer) io.Writer {
+			return &prefixWriter{w: w, prefix: "> "}
+		}}
+	s = cs.SdumpBytes((*int)(nil))
+	expected = "> (*int)(<nil>)"
+	if s != expected {
+		t.Errorf("DumpBytes WriterWrapper mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpMarkEmbedded ensures embedded struct fields are flagged when the
+// MarkEmbedded option is enabled.
+func TestDumpMarkEmbedded(t *testing.T) {
+	type inner struct {
+		a int
+	}
+	type outer struct {
+		inner
+		*embed
+		e *embed
+	}
+	e := embed{"x"}
+	in := outer{inner{1}, &e, nil}
+	cfg := spew.ConfigState{Indent: " ", MarkEmbedded: true}
+	s := cfg.Sdump(in)
+	expected := fmt.Sprintf("(spew_test.outer) {\n"+
+		" (embedded) inner: (spew_test.inner) {\n"+
+		"  a: (int) 1\n"+
+		" },\n"+
+		" (embedded *spew_test.embed) embed: (*spew_test.embed)(%p)({\n"+
+		"  a: (string) (len=1) \"x\"\n"+
+		" }),\n"+
+		" e: (*spew_test.embed)(<nil>)\n"+
+		"}\n", &e)
+	if s != expected {
+		t.Errorf("Mark embedded mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestFdot ensures the object graph output in the DOT language shares nodes
+// for aliased pointers and handles circular references.
+func TestFdot(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+		Tags []string
+		Data []byte
+	}
+	a := &node{Name: "a", Data: []byte{1, 2}}
+	b := &node{Name: "b\"", Next: a}
+	a.Next = b
+	v := []*node{a, b, a}
+
+	buf := new(bytes.Buffer)
+	cfg := spew.ConfigState{Indent: " ", SortKeys: true}
+	cfg.Fdot(buf, v)
+	expected := "digraph spew {\n" +
+		"\tnode [shape=box];\n" +
+		"\tn1 [label=\"[]*spew_test.node (len=3)\\l\"];\n" +
+		"\tn2 [label=\"spew_test.node\\lName: a\\lTags: <nil>\\lData: [1 2]\\l\"];\n" +
+		"\tn3 [label=\"spew_test.node\\lName: b\\\"\\lTags: <nil>\\lData: <nil>\\l\"];\n" +
+		"\tn3 -> n2 [label=\"Next\"];\n" +
+		"\tn2 -> n3 [label=\"Next\"];\n" +
+		"\tn1 -> n2 [label=\"[0]\"];\n" +
+		"\tn1 -> n3 [label=\"[1]\"];\n" +
+		"\tn1 -> n2 [label=\"[2]\"];\n" +
+		"}\n"
+	if s := buf.String(); s != expected {
+		t.Errorf("Fdot mismatch:\n  %v %v", s, expected)
+	}
+
+	// Maps share nodes and scalars are displayed as a single node.
+	m := map[string]interface{}{"b": 2}
+	m["a"] = m
+	buf.Reset()
+	cfg.Fdot(buf, m)
+	expected = "digraph spew {\n" +
+		"\tnode [shape=box];\n" +
+		"\tn1 [label=\"map[string]interface {} (len=2)\\lb: 2\\l\"];\n" +
+		"\tn1 -> n1 [label=\"a\"];\n" +
+		"}\n"
+	if s := buf.String(); s != expected {
+		t.Errorf("Fdot mismatch:\n  %v %v", s, expected)
+	}
+
+	// Slices containing themselves share nodes as well.
+	sl := []interface{}{1, nil}
+	sl[1] = sl
+	buf.Reset()
+	cfg.Fdot(buf, sl)
+	expected = "digraph spew {\n" +
+		"\tnode [shape=box];\n" +
+		"\tn1 [label=\"[]interface {} (len=2)\\l[0]: 1\\l\"];\n" +
+		"\tn1 -> n1 [label=\"[1]\"];\n" +
+		"}\n"
+	if s := buf.String(); s != expected {
+		t.Errorf("Fdot mismatch:\n  %v %v", s, expected)
+	}
+
+	buf.Reset()
+	spew.Fdot(buf, 5)
+	expected = "digraph spew {\n" +
+		"\tnode [shape=box];\n" +
+		"\tn1 [label=\"int\\l5\\l\"];\n" +
+		"}\n"
+	if s := buf.String(); s != expected {
+		t.Errorf("Fdot mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpPath ensures only the value selected by a path is dumped and that
+// paths which don't resolve report an error.
+func TestDumpPath(t *testing.T) {
+	type pool struct {
+		Size int
+	}
+	type database struct {
+		Pool   *pool
+		Limits map[string]int
+	}
+	type config struct {
+		Database database
+		Servers  []interface{}
+	}
+	root := &config{
+		Database: database{&pool{5}, map[string]int{"conn]": 10}},
+		Servers:  []interface{}{"a", nil},
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"Database.Pool", fmt.Sprintf("(*spew_test.pool)(%p)({\n Size: "+
+			"(int) 5\n})\n", root.Database.Pool)},
+		{".Database.Pool.Size", "(int) 5\n"},
+		{"Database.Limits[\"conn]\"]", "(int) 10\n"},
+		{"Servers[0]", "(string) (len=1) \"a\"\n"},
+		{"Servers[0][0]", "(uint8) 97\n"},
+	}
+	for _, test := range tests {
+		s, err := spew.SdumpPath(root, test.path)
+		if err != nil || s != test.want {
+			t.Errorf("DumpPath %q mismatch:\n  %v %v (err %v)", test.path,
+				s, test.want, err)
+		}
+	}
+
+	errTests := []struct {
+		path string
+		want string
+	}{
+		{"Database.Pol", `spew: path "Database.Pol": spew_test.database ` +
+			`has no field "Pol" at "Database"`},
+		{"Servers[2]", `spew: path "Servers[2]": index 2 out of range ` +
+			`for []interface {} of length 2 at "Servers"`},
+		{"Servers[1].Name", `spew: path "Servers[1].Name": nil value ` +
+			`at "Servers[1]"`},
+		{"Database.Limits[x]", `spew: path "Database.Limits[x]": key ` +
+			`"x" not found in map[string]int at "Database.Limits"`},
+		{"Database[0", `spew: path "Database[0": unterminated bracket ` +
+			`at "Database"`},
+	}
+	for _, test := range errTests {
+		s, err := spew.SdumpPath(root, test.path)
+		if err == nil || err.Error() != test.want || s != "" {
+			t.Errorf("DumpPath %q error mismatch:\n  %v %v", test.path,
+				err, test.want)
+		}
+	}
+
+	// A nil value can't be navigated.
+	for _, path := range []string{"X", "[0]"} {
+		want := fmt.Sprintf("spew: path %q: nil value at \"\"", path)
+		if _, err := spew.SdumpPath(nil, path); err == nil ||
+			err.Error() != want {
+
+			t.Errorf("DumpPath nil %q error mismatch:\n  %v %v", path, err,
+				want)
+		}
+	}
+
+	// The selected value is displayed with the same options as Dump.
+	cs := spew.ConfigState{Indent: " ", LabelArgs: true}
+	want := cs.Sdump(5)
+	if s, err := cs.SdumpPath(root, "Database.Pool.Size"); err != nil ||
+		s != want {
+
+		t.Errorf("DumpPath LabelArgs mismatch:\n  %v %v (err %v)", s, want,
+			err)
+	}
+}
+
+// TestDumpMapKeyOrder ensures the MapKeyOrder option orders map keys
+// deterministically.
+func TestDumpMapKeyOrder(t *testing.T) {
+	m := map[int]string{3: "c", 1: "a", 2: "b"}
+	sorted := spew.ConfigState{Indent: " ", MapKeyOrder: spew.SortedKeyOrder}
+	keys := spew.ConfigState{Indent: " ", SortKeys: true}
+	if s, want := sorted.Sdump(m), keys.Sdump(m); s != want {
+		t.Errorf("Sorted key order mismatch:\n  %v %v", s, want)
+	}
+
+	// Keys which can't be sorted meaningfully still produce the same output
+	// every time.
+	type key struct {
+		A int
+		B interface{}
+	}
+	mk := make(map[key]int)
+	for i := 0; i < 32; i++ {
+		mk[key{i, fmt.Sprint(i)}] = i
+	}
+	stable := spew.ConfigState{Indent: " ", MapKeyOrder: spew.StableKeyOrder}
+	want := stable.Sdump(mk)
+	for i := 0; i < 10; i++ {
+		if s := stable.Sdump(mk); s != want {
+			t.Fatalf("Stable key order mismatch:\n  %v %v", s, want)
+		}
+		if s := fmt.Sprintf("%v", stable.NewFormatter(mk)); s !=
+			fmt.Sprintf("%v", stable.NewFormatter(mk)) {
+
+			t.Fatalf("Stable key order mismatch:\n  %v", s)
+		}
+	}
+	if n := strings.Count(want, "(spew_test.key)"); n != 32 {
+		t.Errorf("Stable key order dumped %d keys, want 32", n)
+	}
+}
+
+// TestDumpOmitEmptyContainers ensures empty containers are displayed tersely
+// while nil ones are still distinguishable when the OmitEmptyContainers option
+// is enabled.
+func TestDumpOmitEmptyContainers(t *testing.T) {
+	type s struct {
+		A []int
+		B []int
+		C map[string]int
+		D map[string]int
+		E [0]int
+		F []int
+	}
+	v := s{A: []int{}, C: map[string]int{}, F: []int{1}}
+	cfg := spew.ConfigState{Indent: " ", OmitEmptyContainers: true}
+	got := cfg.Sdump(v)
+	expected := "(spew_test.s) {\n" +
+		" A: ([]int) [],\n" +
+		" B: ([]int) <nil>,\n" +
+		" C: (map[string]int) {},\n" +
+		" D: (map[string]int) <nil>,\n" +
+		" E: ([0]int) [],\n" +
+		" F: ([]int) (len=1 cap=1) {\n" +
+		"  (int) 1\n" +
+		" }\n" +
+		"}\n"
+	if got != expected {
+		t.Errorf("Omit empty containers mismatch:\n  %v %v", got, expected)
+	}
+}
+
+// TestDumpDebugSpew ensures the code path which produced each value is
+// annotated when the DebugSpew option is enabled.
+func TestDumpDebugSpew(t *testing.T) {
+	type s struct {
+		A stringer
+		B customError
+		C time.Duration
+		D int
+	}
+	v := s{"x", 1, time.Second, 2}
+	cfg := spew.ConfigState{Indent: " ", DebugSpew: true, FormatDurations: true}
+	got := cfg.Sdump(v)
+	expected := "(spew_test.s) /* via reflection */ {\n" +
+		" A: (spew_test.stringer) (len=1) /* via Stringer */ stringer x,\n" +
+		" B: (spew_test.customError) /* via error */ error: 1,\n" +
+		" C: (time.Duration) /* via FormatDurations */ 1s,\n" +
+		" D: (int) /* via reflection */ 2\n" +
+		"}\n"
+	if got != expected {
+		t.Errorf("Debug spew mismatch:\n  %v %v", got, expected)
+	}
+
+	cfg.Transform = func(v reflect.Value) (reflect.Value, bool) {
+		if v.Kind() == reflect.Int {
+			return reflect.ValueOf("two"), true
+		}
+		return v, false
+	}
+	got = cfg.Sprintf("%v", v.D)
+	expected = "/* via Transform */ /* via reflection */ two"
+	if got != expected {
+		t.Errorf("Debug spew mismatch:\n  %v %v", got, expected)
+	}
+}
+
+// TestDumpMaxHexDumpBytes ensures hexdumps are cut off according to the
+// MaxHexDumpBytes option.
+func TestDumpMaxHexDumpBytes(t *testing.T) {
+	type blob struct {
+		Data []byte
+	}
+	v := blob{[]byte("0123456789abcdefghij")}
+	cfg := spew.ConfigState{Indent: " ", MaxHexDumpBytes: 18}
+	s := cfg.Sdump(v)
+	expected := "(spew_test.blob) {\n" +
+		" Data: ([]uint8) (len=20 cap=20) {\n" +
+		"  00000000  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|\n" +
+		"  00000010  67 68                                             |gh|\n" +
+		"  ... (2 more)\n" +
+		" }\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Max hexdump bytes mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg.MaxHexDumpBytes = 20
+	if s := cfg.Sdump(v); strings.Contains(s, "more bytes") {
+		t.Errorf("Max hexdump bytes mismatch:\n  %v", s)
+	}
+}
+
+// TestDumpTypeNameFunc ensures type annotations are overridden by the
+// TypeNameFunc option, including the key and element types of unnamed types.
+func TestDumpTypeNameFunc(t *testing.T) {
+	type account struct {
+		ID int
+	}
+	names := map[reflect.Type]string{
+		reflect.TypeOf(account{}): "Account",
+		reflect.TypeOf(""):        "Text",
+	}
+	cfg := spew.ConfigState{Indent: " ", SortKeys: true,
+		TypeNameFunc: func(t reflect.Type) string { return names[t] }}
+
+	a := &account{1}
+	v := map[string][]*account{"x": {a}}
+	s := cfg.Sdump(v)
+	expected := fmt.Sprintf("(map[Text][]*Account) (len=1) {\n"+
+		" (Text) (len=1) \"x\": ([]*Account) (len=1 cap=1) {\n"+
+		"  (*Account)(%p)({\n"+
+		"   ID: (int) 1\n"+
+		"  })\n"+
+		" }\n"+
+		"}\n", a)
+	if s != expected {
+		t.Errorf("Type name func mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%#v", v)
+	expected = "(map[Text][]*Account)map[x:[<*>{ID:(int)1}]]"
+	if s != expected {
+		t.Errorf("Type name func mismatch:\n  %v %v", s, expected)
+	}
+
+	// Channels keep their direction.
+	s = cfg.Sdump((<-chan chan<- string)(nil))
+	expected = "(<-chan chan<- Text) <nil>\n"
+	if s != expected {
+		t.Errorf("Type name func mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpVerboseFloats ensures negative zero, NaN payloads, and subnormals
+// are displayed distinctly when the VerboseFloats option is enabled.
+func TestDumpVerboseFloats(t *testing.T) {
+	cfg := spew.ConfigState{VerboseFloats: true}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{math.Copysign(0, -1), "(float64) -0.0\n"},
+		{0.0, "(float64) 0\n"},
+		{1.5, "(float64) 1.5\n"},
+		{math.Float64frombits(0x7ff8000000000001),
+			"(float64) NaN(0x7ff8000000000001)\n"},
+		{math.Float32frombits(0x7fc00001), "(float32) NaN(0x7fc00001)\n"},
+		{math.SmallestNonzeroFloat64, "(float64) 5e-324 (subnormal)\n"},
+		{float32(math.SmallestNonzeroFloat32),
+			"(float32) 1e-45 (subnormal)\n"},
+		{float32(1e-30), "(float32) 1e-30\n"},
+		{float32(math.Copysign(0, -1)), "(float32) -0.0\n"},
+	}
+	for i, test := range tests {
+		if s := cfg.Sdump(test.in); s != test.want {
+			t.Errorf("Verbose floats #%d mismatch:\n  %v %v", i, s,
+				test.want)
+		}
+	}
+
+	if s := cfg.Sprintf("%v", math.Copysign(0, -1)); s != "-0.0" {
+		t.Errorf("Verbose floats mismatch:\n  %v -0.0", s)
+	}
+}
+
+// TestSafeSdump ensures panics raised while dumping are recovered and returned
+// along with the output produced up to that point.
+func TestSafeSdump(t *testing.T) {
+	type s struct {
+		A int
+		B string
+	}
+	cfg := spew.ConfigState{Indent: " ",
+		Transform: func(v reflect.Value) (reflect.Value, bool) {
+			if v.Kind() == reflect.String {
+				panic("bad string")
+			}
+			return v, false
+		}}
+	out, recovered := cfg.SafeSdump(s{1, "x"})
+	expected := "(spew_test.s) {\n" +
+		" A: (int) 1,\n" +
+		" B: <dump aborted: bad string>"
+	if out != expected || recovered != "bad string" {
+		t.Errorf("SafeSdump mismatch:\n  %v %v (recovered %v)", out,
+			expected, recovered)
+	}
+
+	// Output still buffered for line wrapping is kept.
+	cfg.MaxLineWidth = 40
+	out, recovered = cfg.SafeSdump(s{7, "x"})
+	expected = "(spew_test.s) {\n" +
+		" A: (int) 7,\n" +
+		" B: <dump aborted: bad string>"
+	if out != expected || recovered != "bad string" {
+		t.Errorf("SafeSdump MaxLineWidth mismatch:\n  %v %v (recovered %v)",
+			out, expected, recovered)
+	}
+
+	out, recovered = spew.SafeSdump(5)
+	if out != "(int) 5\n" || recovered != nil {
+		t.Errorf("SafeSdump mismatch:\n  %v (recovered %v)", out, recovered)
+	}
+}
+
+// TestDumpRunLengthEncode ensures runs of identical elements are collapsed
+// when the RunLengthEncode option is enabled.
+func TestDumpRunLengthEncode(t *testing.T) {
+	v := make([]int, 10)
+	v[4], v[5], v[9] = 1, 1, 2
+	cfg := spew.ConfigState{Indent: " ", RunLengthEncode: true}
+	s := cfg.Sdump(v)
+	expected := "([]int) (len=10 cap=10) {\n" +
+		" (int) 0 (×4),\n" +
+		" (int) 1,\n" +
+		" (int) 1,\n" +
+		" (int) 0 (×3),\n" +
+		" (int) 2\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Run length encode mismatch:\n  %v %v", s, expected)
+	}
+
+	// Runs of composite elements and runs cut off by MaxElements.
+	type pair struct{ A, B int }
+	cfg.MaxElements = 4
+	s = cfg.Sdump([]pair{{}, {}, {}, {}, {}, {}})
+	expected = "([]spew_test.pair) (len=6 cap=6) {\n" +
+		" (spew_test.pair) {\n" +
+		"  A: (int) 0,\n" +
+		"  B: (int) 0\n" +
+		" } (×4),\n" +
+		" ... (2 more)\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Run length encode mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpStructStyle ensures structs, including nested ones, are displayed as
+// key=value pairs when the StructStyle option is KeyValueStructStyle.
+func TestDumpStructStyle(t *testing.T) {
+	type Bar struct {
+		Flag string
+		Data *int
+	}
+	type Foo struct {
+		Name string
+		Bar  Bar
+	}
+	v := []Foo{{"a", Bar{"two", nil}}}
+	cfg := spew.ConfigState{Indent: " ", StructStyle: spew.KeyValueStructStyle}
+	s := cfg.Sdump(v)
+	expected := "([]spew_test.Foo) (len=1 cap=1) {\n" +
+		" (spew_test.Foo) Foo{Name=a Bar=Bar{Flag=two Data=<nil>}}\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Struct style mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%v", v[0])
+	expected = "Foo{Name=a Bar=Bar{Flag=two Data=<nil>}}"
+	if s != expected {
+		t.Errorf("Struct style mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpMapKVSeparator ensures the MapKVSeparator option is used between map
+// keys and values in both block and inline output.
+func TestDumpMapKVSeparator(t *testing.T) {
+	m := map[string]int{"a": 1, "b": 2}
+	cfg := spew.ConfigState{Indent: " ", SortKeys: true, MapKVSeparator: " => "}
+	s := cfg.Sdump(m)
+	expected := "(map[string]int) (len=2) {\n" +
+		" (string) (len=1) \"a\" => (int) 1,\n" +
+		" (string) (len=1) \"b\" => (int) 2\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Map separator mismatch:\n  %v %v", s, expected)
+	}
+
+	cfg.CompactSmallMaps = 2
+	s = cfg.Sdump(m)
+	expected = "(map[string]int) (len=2) {a => 1 b => 2}\n"
+	if s != expected {
+		t.Errorf("Map separator mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%v", m)
+	expected = "map[a => 1 b => 2]"
+	if s != expected {
+		t.Errorf("Map separator mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestEqualExcept ensures values are compared deeply while values at ignored
+// paths are always considered equal.
+func TestEqualExcept(t *testing.T) {
+	type item struct {
+		ID   int
+		Name string
+	}
+	type record struct {
+		Created time.Time
+		Items   []item
+		Tags    map[string]*item
+		next    *record
+	}
+	newRecord := func(created time.Time, id int) *record {
+		r := &record{
+			Created: created,
+			Items:   []item{{id, "a"}, {id + 1, "b"}},
+			Tags:    map[string]*item{"x.y": {id, "t"}},
+		}
+		r.next = r
+		return r
+	}
+	a := newRecord(time.Unix(1, 0), 10)
+	b := newRecord(time.Unix(2, 0), 20)
+
+	tests := []struct {
+		paths []string
+		want  bool
+	}{
+		{nil, false},
+		{[]string{"Created"}, false},
+		{[]string{"Created", "Items[*].ID"}, false},
+		{[]string{"Created", "Items[*].ID", "Tags[\"x.y\"].ID"}, true},
+		{[]string{"Created", "Items[0].ID", "Items[1].ID", "Tags[x.y]"}, true},
+		{[]string{".Created", "Items", "Tags[*]"}, true},
+	}
+	for i, test := range tests {
+		if got := spew.EqualExcept(a, b, test.paths...); got != test.want {
+			t.Errorf("EqualExcept #%d got %v, want %v", i, got, test.want)
+		}
+	}
+
+	if !spew.EqualExcept(a, newRecord(a.Created, 10)) {
+		t.Errorf("EqualExcept of equal values got false")
+	}
+	if spew.EqualExcept(1, "1") || !spew.EqualExcept(nil, nil) {
+		t.Errorf("EqualExcept of mismatched types mismatch")
+	}
+}
+
+// TestDumpCollapsePointerChains ensures chains of unshared pointers are
+// collapsed when the CollapsePointerChains option is enabled.
+func TestDumpCollapsePointerChains(t *testing.T) {
+	type s struct {
+		A ***int
+		B **int
+		C *int
+	}
+	x, y := 5, 6
+	px, py := &x, &y
+	ppx := &px
+	v := s{&ppx, &py, py}
+	cfg := spew.ConfigState{Indent: " ", CollapsePointerChains: true}
+	got := cfg.Sdump(v)
+	expected := fmt.Sprintf("(spew_test.s) {\n"+
+		" A: (***int) ->5,\n"+
+		" B: (**int)(%p->%p)(6),\n"+
+		" C: (*int)(%p)(6)\n"+
+		"}\n", &py, py, py)
+	if got != expected {
+		t.Errorf("Collapse pointer chains mismatch:\n  %v %v", got, expected)
+	}
+}
+
+// TestDumpCollapsePointerChainsSelfReferential ensures counting the pointer
+// references for CollapsePointerChains terminates for maps and slices which
+// contain themselves, with and without MaxDepth.
+func TestDumpCollapsePointerChainsSelfReferential(t *testing.T) {
+	m := map[string]interface{}{}
+	m["self"] = m
+	sl := []interface{}{nil}
+	sl[0] = sl
+	for _, maxDepth := range []int{0, 3} {
+		cfg := spew.ConfigState{Indent: " ", CollapsePointerChains: true,
+			MaxDepth: maxDepth}
+		want := "(map[string]interface {}) (len=1) {\n" +
+			" (string) (len=4) \"self\": (map[string]interface {}) <already shown>\n" +
+			"}\n"
+		if got := cfg.Sdump(m); got != want {
+			t.Errorf("self-referential map MaxDepth %d\n got: %q\nwant: %q",
+				maxDepth, got, want)
+		}
+		want = "([]interface {}) (len=1 cap=1) {\n" +
+			" ([]interface {}) <already shown>\n" +
+			"}\n"
+		if got := cfg.Sdump(sl); got != want {
+			t.Errorf("self-referential slice MaxDepth %d\n got: %q\nwant: %q",
+				maxDepth, got, want)
+		}
+	}
+}
+
+// TestDumpGroupDigits ensures integers and the integer part of floats have
+// their digits grouped when the GroupDigits option is enabled.
+func TestDumpGroupDigits(t *testing.T) {
+	cfg := spew.ConfigState{GroupDigits: true}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{1234567, "(int) 1,234,567\n"},
+		{-1234, "(int) -1,234\n"},
+		{123, "(int) 123\n"},
+		{uint64(1000), "(uint64) 1,000\n"},
+		{12345.5, "(float64) 12,345.5\n"},
+		{1e21, "(float64) 1e+21\n"},
+		{uintptr(0x123456), "(uintptr) 0x123456\n"},
+	}
+	for i, test := range tests {
+		if s := cfg.Sdump(test.in); s != test.want {
+			t.Errorf("Group digits #%d mismatch:\n  %v %v", i, s,
+				test.want)
+		}
+	}
+
+	cfg.DigitSeparator = "_"
+	if s := cfg.Sprintf("%v", 1234567); s != "1_234_567" {
+		t.Errorf("Group digits mismatch:\n  %v 1_234_567", s)
+	}
+}
+
+// flushErrWriter is a writer with a Flush method which always fails.
+type flushErrWriter struct {
+	bytes.Buffer
+}
+
+var errFlush = errors.New("flushErrWriter: flush failed")
+
+func (fw *flushErrWriter) Flush() error {
+	return errFlush
+}
+
+// TestFdumpAutoFlush ensures buffered writers are flushed, and flush errors are
+// returned, when the AutoFlush option is enabled.
+func TestFdumpAutoFlush(t *testing.T) {
+	var buf bytes.Buffer
+	bw := bufio.NewWriter(&buf)
+	cfg := spew.ConfigState{Indent: " "}
+	cfg.Fdump(bw, 5)
+	if buf.Len() != 0 {
+		t.Errorf("Fdump flushed without AutoFlush: %q", buf.String())
+	}
+
+	bw.Reset(&buf)
+	cfg.AutoFlush = true
+	n, err := cfg.FdumpN(bw, 5)
+	if s := buf.String(); s != "(int) 5\n" || n != len(s) || err != nil {
+		t.Errorf("AutoFlush mismatch: %q (n %d, err %v)", s, n, err)
+	}
+
+	if _, err := cfg.FdumpN(&flushErrWriter{}, 5); err != errFlush {
+		t.Errorf("AutoFlush error mismatch: got %v, want %v", err, errFlush)
+	}
+}
+
+// TestDumpHighlightHeterogeneous ensures the dynamic types of interface
+// elements are displayed on their own line when the HighlightHeterogeneous
+// option is enabled.
+func TestDumpHighlightHeterogeneous(t *testing.T) {
+	cfg := spew.ConfigState{Indent: " ", SortKeys: true,
+		HighlightHeterogeneous: true}
+	s := cfg.Sdump([]interface{}{1, "a", nil})
+	expected := "([]interface {}) (len=3 cap=3) {\n" +
+		" -- dynamic type int --\n" +
+		" (int) 1,\n" +
+		" -- dynamic type string --\n" +
+		" (string) (len=1) \"a\",\n" +
+		" -- dynamic type <nil> --\n" +
+		" (interface {}) <nil>\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Highlight heterogeneous mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sdump(map[string]interface{}{"a": 1.5, "b": []int(nil)})
+	expected = "(map[string]interface {}) (len=2) {\n" +
+		" -- dynamic type float64 --\n" +
+		" (string) (len=1) \"a\": (float64) 1.5,\n" +
+		" -- dynamic type []int --\n" +
+		" (string) (len=1) \"b\": ([]int) <nil>\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Highlight heterogeneous mismatch:\n  %v %v", s, expected)
+	}
+
+	// Uniform element types are unaffected.
+	s = cfg.Sdump([]int{1})
+	if strings.Contains(s, "dynamic type") {
+		t.Errorf("Highlight heterogeneous mismatch:\n  %v", s)
+	}
+}
+
+// TestDeterministicLabels ensures the labels used to refer back to previously
+// displayed values are assigned in traversal order rather than derived from
+// addresses, so dumps of the same DAG are identical across runs.
+func TestDeterministicLabels(t *testing.T) {
+	type leaf struct {
+		V int
+	}
+	type node struct {
+		L, R *leaf
+		M    map[string][]int
+	}
+	newDAG := func() node {
+		shared := &leaf{1}
+		backing := []int{1, 2, 3}
+		return node{shared, shared, map[string][]int{
+			"c": backing[2:], "a": backing[:1], "b": {4},
+		}}
+	}
+
+	wantDot := "digraph spew {\n" +
+		"\tnode [shape=box];\n" +
+		"\tn1 [label=\"spew_test.node\\l\"];\n" +
+		"\tn2 [label=\"spew_test.leaf\\lV: 1\\l\"];\n" +
+		"\tn3 [label=\"map[string][]int (len=3)\\l\"];\n" +
+		"\tn4 [label=\"[]int (len=1)\\l[0]: 1\\l\"];\n" +
+		"\tn5 [label=\"[]int (len=1)\\l[0]: 4\\l\"];\n" +
+		"\tn6 [label=\"[]int (len=1)\\l[0]: 3\\l\"];\n" +
+		"\tn1 -> n2 [label=\"L\"];\n" +
+		"\tn1 -> n2 [label=\"R\"];\n" +
+		"\tn3 -> n4 [label=\"a\"];\n" +
+		"\tn3 -> n5 [label=\"b\"];\n" +
+		"\tn3 -> n6 [label=\"c\"];\n" +
+		"\tn1 -> n3 [label=\"M\"];\n" +
+		"}\n"
+	wantDump := "(map[string][]int) (len=3) {\n" +
+		" (string) (len=1) \"a\": ([]int) (len=1 cap=3) {\n" +
+		"  (int) 1\n" +
+		" },\n" +
+		" (string) (len=1) \"b\": ([]int) (len=1 cap=1) {\n" +
+		"  (int) 4\n" +
+		" },\n" +
+		" (string) (len=1) \"c\": ([]int) (len=1 cap=1) [shares backing with #1] {\n" +
+		"  (int) 3\n" +
+		" }\n" +
+		"}\n"
+	cfg := spew.ConfigState{Indent: " ", SortKeys: true,
+		DetectSliceAliasing: true}
+	for i := 0; i < 5; i++ {
+		dag := newDAG()
+		var buf bytes.Buffer
+		cfg.Fdot(&buf, dag)
+		if s := buf.String(); s != wantDot {
+			t.Fatalf("Fdot labels mismatch:\n  %v %v", s, wantDot)
+		}
+		if s := cfg.Sdump(dag.M); s != wantDump {
+			t.Fatalf("Dump labels mismatch:\n  %v %v", s, wantDump)
+		}
+	}
+}
+
+// celsius is a named numeric type used to ensure the concrete named type of a
+// value held by an interface is preserved rather than its kind.
+type celsius float64
+
+// TestDumpInterfaceNamedNumeric ensures interfaces holding values of named
+// numeric types are annotated with the named type in every unwrapping path.
+func TestDumpInterfaceNamedNumeric(t *testing.T) {
+	type holder struct {
+		T interface{}
+		P *interface{}
+	}
+	var i interface{} = celsius(21.5)
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{i, "(spew_test.celsius) 21.5\n"},
+		{[]interface{}{celsius(1), int8(2)}, "([]interface {}) (len=2 cap=2) {\n" +
+			" (spew_test.celsius) 1,\n" +
+			" (int8) 2\n" +
+			"}\n"},
+		{map[string]interface{}{"t": celsius(3)}, "(map[string]interface {}) (len=1) {\n" +
+			" (string) (len=1) \"t\": (spew_test.celsius) 3\n" +
+			"}\n"},
+		{holder{T: celsius(4), P: &i}, fmt.Sprintf("(spew_test.holder) {\n"+
+			" T: (spew_test.celsius) 4,\n"+
+			" P: (*spew_test.celsius)(%p)(21.5)\n"+
+			"}\n", &i)},
+	}
+	cfg := spew.ConfigState{Indent: " "}
+	for j, test := range tests {
+		if s := cfg.Sdump(test.in); s != test.want {
+			t.Errorf("Interface named numeric #%d mismatch:\n  %v %v", j, s,
+				test.want)
+		}
+	}
+
+	s := cfg.Sprintf("%#v", []interface{}{celsius(1)})
+	if want := "([]interface {})[(spew_test.celsius)1]"; s != want {
+		t.Errorf("Interface named numeric mismatch:\n  %v %v", s, want)
+	}
+}
+
+// TestDumpMaxMapDepth ensures nested maps are clipped independently of
+// structs according to the MaxMapDepth option.
+func TestDumpMaxMapDepth(t *testing.T) {
+	type wrapper struct {
+		Inner struct {
+			M map[string]interface{}
+		}
+	}
+	var v wrapper
+	v.Inner.M = map[string]interface{}{
+		"a": map[string]interface{}{"b": map[string]int{"c": 1}},
+	}
+	cfg := spew.ConfigState{Indent: " ", MaxMapDepth: 2}
+	s := cfg.Sdump(v)
+	expected := "(spew_test.wrapper) {\n" +
+		" Inner: (struct { M map[string]interface {} }) {\n" +
+		"  M: (map[string]interface {}) (len=1) {\n" +
+		"   (string) (len=1) \"a\": (map[string]interface {}) (len=1) {\n" +
+		"    (string) (len=1) \"b\": (map[string]int) (len=1) {... (1 more)}\n" +
+		"   }\n" +
+		"  }\n" +
+		" }\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Max map depth mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%v", v)
+	expected = "{{map[a:map[b:map[... (1 more)]]]}}"
+	if s != expected {
+		t.Errorf("Max map depth mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpExportedOnly ensures unexported fields are hidden when the
+// ExportedOnly option is set unless their struct type was allowed.
+func TestDumpExportedOnly(t *testing.T) {
+	type creds struct {
+		User     string
+		password string
+	}
+	type session struct {
+		Creds creds
+		token string
+	}
+	v := session{creds{"bob", "hunter2"}, "abc"}
+	cfg := spew.ConfigState{Indent: " ", ExportedOnly: true}
+	s := cfg.Sdump(v)
+	expected := "(spew_test.session) {\n" +
+		" Creds: (spew_test.creds) {\n" +
+		"  User: (string) (len=3) \"bob\"\n" +
+		" }\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Exported only mismatch:\n  %v %v", s, expected)
+	}
+	if !spew.NewDefaultConfig().ExportedOnly || !spew.Config.ExportedOnly {
+		t.Errorf("ExportedOnly is not enabled by default")
+	}
+
+	cfg.AllowUnexported(&session{})
+	s = cfg.Sprintf("%+v", v)
+	expected = "{Creds:{User:bob} token:abc}"
+	if s != expected {
+		t.Errorf("Exported only mismatch:\n  %v %v", s, expected)
+	}
+}
+
+// TestDumpShowRunes ensures the ShowRunes option displays runes as quoted
+// characters while leaving other integers and named types alone.
+func TestDumpShowRunes(t *testing.T) {
+	type code int32
+	cs := spew.ConfigState{Indent: " ", ShowRunes: true}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{'A', "(int32) 'A' (65)\n"},
+		{'\'', "(int32) '\\'' (39)\n"},
+		{'\x00', "(int32) '\\u0000' (0)\n"},
+		{'\U000e0001', "(int32) '\\U000e0001' (917505)\n"},
+		{rune(-1), "(int32) -1\n"},
+		{int64(65), "(int64) 65\n"},
+		{code(65), "(spew_test.code) 65\n"},
+	}
+	for i, test := range tests {
+		if s := cs.Sdump(test.in); s != test.want {
+			t.Errorf("ShowRunes #%d\n got: %q\nwant: %q", i, s, test.want)
+		}
+	}
+	if s := cs.Sprintf("%v", 'é'); s != "'é' (233)" {
+		t.Errorf("ShowRunes formatter got: %q", s)
+	}
+}
+
+// eventNode is used to test the EventSink option with circular references.
+type eventNode struct {
+	Month time.Month
+	Next  *eventNode
+}
+
+// TestDumpEventSink ensures the EventSink option receives the rendering
+// decisions in the order they are made.
+func TestDumpEventSink(t *testing.T) {
+	var got []string
+	cs := spew.ConfigState{Indent: " ", EventSink: func(ev spew.Event) {
+		got = append(got, fmt.Sprintf("%v %v %d %s", ev.Kind, ev.Type,
+			ev.Depth, ev.Detail))
+	}}
+
+	n := &eventNode{Month: time.March}
+	n.Next = n
+	cs.Sdump(n)
+	want := []string{
+		"EnterEvent *spew_test.eventNode 0 ",
+		"EnterEvent spew_test.eventNode 0 ",
+		"EnterEvent time.Month 1 ",
+		"MethodEvent time.Month 1 ",
+		"EnterEvent *spew_test.eventNode 1 ",
+		"CycleEvent *spew_test.eventNode 1 ",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EventSink cycle\n got: %q\nwant: %q", got, want)
+	}
+
+	got = nil
+	cs.MaxDepth = 1
+	cs.Sprintf("%v", [][]int{{1}})
+	want = []string{
+		"EnterEvent [][]int 0 ",
+		"EnterEvent []int 1 ",
+		"TruncateEvent []int 2 MaxDepth",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("EventSink truncate\n got: %q\nwant: %q", got, want)
+	}
+}
+
+// TestDumpMaxLineWidth ensures the MaxLineWidth option wraps long lines at
+// spaces without breaking quoted strings or hex dumps.
+func TestDumpMaxLineWidth(t *testing.T) {
+	cs := spew.ConfigState{Indent: "  ", MaxLineWidth: 24, SortKeys: true,
+		CompactSmallMaps: 10}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{
+			map[string]int{"aaaaaaaa": 1, "bbbbbbbbb": 2, "ccccccccc": 3,
+				"dddddd": 4},
+			"(map[string]int) (len=4)\n  {aaaaaaaa:1\n  bbbbbbbbb:2\n" +
+				"  ccccccccc:3 dddddd:4}\n",
+		},
+		{
+			"a \"quoted\" string value",
+			"(string) (len=23)\n  \"a \\\"quoted\\\" string value\"\n",
+		},
+		{
+			[]byte("0123456789abcdef"),
+			"([]uint8) (len=16\n  cap=16) {\n  00000000  30 31 32 33 34 35 " +
+				"36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|\n}\n",
+		},
+	}
+	for i, test := range tests {
+		if s := cs.Sdump(test.in); s != test.want {
+			t.Errorf("MaxLineWidth #%d\n got: %q\nwant: %q", i, s,
+				test.want)
+		}
+	}
+}
+
+// TestDumpNonZero ensures DumpNonZero omits zero fields recursively and
+// reports how many were omitted.
+func TestDumpNonZero(t *testing.T) {
+	type limits struct {
+		Conns   int
+		Timeout time.Duration
+	}
+	type server struct {
+		Name   string
+		Port   int
+		Tags   []string
+		Limits limits
+		Backup limits
+		Next   *server
+	}
+	v := server{Name: "api", Limits: limits{Conns: 5}}
+	cs := spew.ConfigState{Indent: " ", DisableMethods: true}
+	want := "(spew_test.server) {\n" +
+		" Name: (string) (len=3) \"api\",\n" +
+		" Limits: (spew_test.limits) {\n" +
+		"  Conns: (int) 5\n" +
+		" }\n" +
+		"}\n" +
+		"(5 zero fields omitted)\n"
+	if s := cs.SdumpNonZero(v); s != want {
+		t.Errorf("SdumpNonZero\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestDumpQualifiedNilPointers ensures the QualifiedNilPointers option
+// displays nil pointers along with their type.
+func TestDumpQualifiedNilPointers(t *testing.T) {
+	type nilPtrs struct {
+		P *int
+		Q **int
+	}
+	var n *int
+	cs := spew.ConfigState{Indent: " ", QualifiedNilPointers: true}
+	want := "(spew_test.nilPtrs) {\n P: (*int)(nil),\n" +
+		" Q: (**int)(ADDR)(nil)\n}\n"
+	s := regexp.MustCompile("0x[0-9a-f]+").ReplaceAllString(
+		cs.Sdump(nilPtrs{Q: &n}), "ADDR")
+	if s != want {
+		t.Errorf("QualifiedNilPointers dump\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "{(*int)(nil) (**int)(nil)} (*int)(nil) ([]*int)[(*int)(nil)]"
+	s = cs.Sprintf("%v %#v %#v", nilPtrs{Q: &n}, n, []*int{nil})
+	if s != want {
+		t.Errorf("QualifiedNilPointers format\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestDumpInvalidValues ensures nil interfaces and values of type
+// reflect.Value which don't hold a value are displayed consistently and never
+// cause a panic.
+func TestDumpInvalidValues(t *testing.T) {
+	type nilIface struct {
+		I interface{}
+		E error
+	}
+	cs := spew.ConfigState{Indent: " "}
+	tests := []struct {
+		in     interface{}
+		dump   string
+		format string
+	}{
+		{
+			[]interface{}{nil, nil},
+			"([]interface {}) (len=2 cap=2) {\n (interface {}) <nil>,\n" +
+				" (interface {}) <nil>\n}\n",
+			"[<nil> <nil>]",
+		},
+		{
+			nilIface{},
+			"(spew_test.nilIface) {\n I: (interface {}) <nil>,\n" +
+				" E: (error) <nil>\n}\n",
+			"{<nil> <nil>}",
+		},
+		{
+			reflect.Value{},
+			"<invalid>\n",
+			"<invalid>",
+		},
+		{
+			[]reflect.Value{{}, reflect.ValueOf(1)},
+			"([]reflect.Value) (len=2 cap=2) {\n <invalid>,\n (int) 1\n}\n",
+			"[<invalid> 1]",
+		},
+	}
+	for i, test := range tests {
+		if s := cs.Sdump(test.in); s != test.dump {
+			t.Errorf("invalid values dump #%d\n got: %q\nwant: %q", i, s,
+				test.dump)
+		}
+		if s := cs.Sprintf("%v", test.in); s != test.format {
+			t.Errorf("invalid values format #%d\n got: %q\nwant: %q", i, s,
+				test.format)
+		}
+	}
+}
+
+// snapService is used to test Snapshot and DumpChanges.
+type snapService struct {
+	Name    string
+	Limits  map[string]int
+	Servers []string
+	Owner   *snapService
+	State   interface{}
+	Created time.Time
+	hits    int
+}
+
+// TestSnapshotDumpChanges ensures DumpChanges displays only the changes since
+// the snapshot was taken, including changes to the shape of the value.
+func TestSnapshotDumpChanges(t *testing.T) {
+	svc := &snapService{
+		Name:    "api",
+		Limits:  map[string]int{"conn": 5, "rate": 1},
+		Servers: []string{"a"},
+		State:   1,
+		Created: time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+	}
+	svc.Owner = svc
+	cs := spew.ConfigState{Indent: " ", SortKeys: true}
+	snap := cs.Snapshot(svc)
+	if s := snap.SdumpChanges(svc); s != "(no changes)\n" {
+		t.Errorf("DumpChanges unchanged got: %q", s)
+	}
+
+	svc.Limits["conn"] = 10
+	delete(svc.Limits, "rate")
+	svc.Limits["burst"] = 2
+	svc.Servers = append(svc.Servers, "b")
+	svc.State = "ready"
+	svc.Created = svc.Created.Add(time.Hour)
+	svc.hits++
+	want := "Limits[\"burst\"]: <missing> => (int)2\n" +
+		"Limits[\"conn\"]: (int)5 => (int)10\n" +
+		"Limits[\"rate\"]: (int)1 => <missing>\n" +
+		"Servers: ([]string)[a] => ([]string)[a b]\n" +
+		"State: (int)1 => (string)ready\n" +
+		"Created: (time.Time)2020-01-02 03:04:05 +0000 UTC => " +
+		"(time.Time)2020-01-02 04:04:05 +0000 UTC\n"
+	if spew.UnsafeDisabled {
+		want += "hits: 0 => 1\n"
+	} else {
+		want += "hits: (int)0 => (int)1\n"
+	}
+	if s := snap.SdumpChanges(svc); s != want {
+		t.Errorf("DumpChanges\n got: %q\nwant: %q", s, want)
+	}
+
+	if s := snap.SdumpChanges(42); !strings.HasPrefix(s,
+		"(*spew_test.snapService)") || !strings.HasSuffix(s, " => (int)42\n") {
+
+		t.Errorf("DumpChanges different type got: %q", s)
+	}
+
+	// Maps and slices containing themselves are copied with the circular
+	// references preserved.
+	m := map[string]interface{}{"a": 1}
+	m["self"] = m
+	sl := []interface{}{1, nil}
+	sl[1] = sl
+	mapSnap, sliceSnap := cs.Snapshot(m), cs.Snapshot(sl)
+	m["a"], sl[0] = 2, 2
+	want = "[\"a\"]: (int)1 => (int)2\n"
+	if s := mapSnap.SdumpChanges(m); s != want {
+		t.Errorf("DumpChanges self-referential map\n got: %q\nwant: %q", s, want)
+	}
+	want = "[0]: (int)1 => (int)2\n"
+	if s := sliceSnap.SdumpChanges(sl); s != want {
+		t.Errorf("DumpChanges self-referential slice\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestDumpKeyConfig ensures the KeyConfig option displays map keys with a
+// separate configuration from their values.
+func TestDumpKeyConfig(t *testing.T) {
+	m := map[time.Month]time.Month{time.March: time.April}
+	cs := spew.ConfigState{Indent: " ", KeyConfig: &spew.ConfigState{
+		Indent: "\t", DisableMethods: true}}
+	want := "(map[time.Month]time.Month) (len=1) {\n" +
+		" (time.Month) 3: (time.Month) April\n}\n"
+	if s := cs.Sdump(m); s != want {
+		t.Errorf("KeyConfig dump\n got: %q\nwant: %q", s, want)
+	}
+	if s := cs.Sprintf("%v", m); s != "map[3:April]" {
+		t.Errorf("KeyConfig format got: %q", s)
+	}
+
+	cs.CompactSmallMaps = 1
+	want = "(map[time.Month]time.Month) (len=1) {3:April}\n"
+	if s := cs.Sdump(m); s != want {
+		t.Errorf("KeyConfig compact\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// asciiStringer is used to test the ASCIIOnly option with Stringer output.
+type asciiStringer struct{}
+
+// String returns a string with non-ASCII characters.
+func (asciiStringer) String() string {
+	return "café ☕"
+}
+
+// TestDumpASCIIOnly ensures the ASCIIOnly option escapes all non-ASCII
+// characters in both Dump and the custom formatter.
+func TestDumpASCIIOnly(t *testing.T) {
+	cs := spew.ConfigState{Indent: "·", ASCIIOnly: true,
+		RunLengthEncode: true}
+	v := struct {
+		S   string
+		St  asciiStringer
+		Z   []int
+		Bad string
+	}{"naïve 𝄞", asciiStringer{}, []int{0, 0, 0}, "\xff"}
+	want := "(struct { S string; St spew_test.asciiStringer; Z []int; " +
+		"Bad string }) {\n" +
+		"\\u00b7S: (string) (len=11) \"na\\u00efve \\U0001d11e\",\n" +
+		"\\u00b7St: (spew_test.asciiStringer) caf\\u00e9 \\u2615,\n" +
+		"\\u00b7Z: ([]int) (len=3 cap=3) {\n" +
+		"\\u00b7\\u00b7(int) 0 (x3)\n" +
+		"\\u00b7},\n" +
+		"\\u00b7Bad: (string) (len=1) \"\\xff\"\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("ASCIIOnly dump\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "{na\\u00efve \\U0001d11e caf\\u00e9 \\u2615 [0 0 0] \\xff}"
+	if s := cs.Sprintf("%v", v); s != want {
+		t.Errorf("ASCIIOnly format\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestDumpStableAddresses ensures the StableAddresses option replaces pointer
+// addresses with fake sequential ones that still distinguish pointers.
+func TestDumpStableAddresses(t *testing.T) {
+	a, b := 1, 2
+	pa := &a
+	v := []interface{}{&a, &b, pa, &pa}
+	cs := spew.ConfigState{Indent: " ", StableAddresses: true}
+	want := "([]interface {}) (len=4 cap=4) {\n" +
+		" (*int)(0x1)(1),\n" +
+		" (*int)(0x2)(2),\n" +
+		" (*int)(0x1)(1),\n" +
+		" (**int)(0x3->0x1)(1)\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("StableAddresses dump\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "<*>(0x1)1 <*>(0x2)2 <*>(0x1)1"
+	if s := cs.Sprintf("%+v %+v %+v", &a, &b, pa); s != want {
+		t.Errorf("StableAddresses format\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// identGraph is a value-based graph whose edges are resolved through a lookup
+// table, so a cycle in the graph doesn't involve any pointers.
+type identGraph struct {
+	ID    int
+	Edges []identEdge
+}
+
+// identEdge is an edge of an identGraph which resolves its target by value.
+type identEdge struct {
+	To identGraph
+}
+
+// SpewIdentity returns the ID of the node as its identity.
+func (g identGraph) SpewIdentity() interface{} {
+	return g.ID
+}
+
+// TestDumpSpewIdentity ensures values implementing the SpewIdentifier
+// interface are displayed as already shown when a value with the same
+// identity is being displayed by one of their ancestors.
+func TestDumpSpewIdentity(t *testing.T) {
+	leaf := identGraph{ID: 1}
+	v := identGraph{ID: 1, Edges: []identEdge{{To: leaf},
+		{To: identGraph{ID: 2}}}}
+	cs := spew.ConfigState{Indent: " "}
+	want := "(spew_test.identGraph) {\n" +
+		" ID: (int) 1,\n" +
+		" Edges: ([]spew_test.identEdge) (len=2 cap=2) {\n" +
+		"  (spew_test.identEdge) {\n" +
+		"   To: (spew_test.identGraph) <already shown>\n" +
+		"  },\n" +
+		"  (spew_test.identEdge) {\n" +
+		"   To: (spew_test.identGraph) {\n" +
+		"    ID: (int) 2,\n" +
+		"    Edges: ([]spew_test.identEdge) <nil>\n" +
+		"   }\n" +
+		"  }\n" +
+		" }\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("SpewIdentity dump\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "{1 [{<shown>} {{2 <nil>}}]}"
+	if s := cs.Sprintf("%v", v); s != want {
+		t.Errorf("SpewIdentity format\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// failingValuer is a driver.Valuer whose Value method always fails.
+type failingValuer struct {
+	ID int
+}
+
+// Value returns an error.
+func (failingValuer) Value() (driver.Value, error) {
+	return nil, errors.New("broken")
+}
+
+// TestDumpUseValuer ensures the UseValuer option displays driver.Valuer types
+// as the result of their Value method.
+func TestDumpUseValuer(t *testing.T) {
+	v := struct {
+		Name  sql.NullString
+		Age   sql.NullInt64
+		Other failingValuer
+	}{Name: sql.NullString{String: "bob", Valid: true}}
+	cs := spew.ConfigState{Indent: " ", UseValuer: true}
+	want := "(struct { Name sql.NullString; Age sql.NullInt64; " +
+		"Other spew_test.failingValuer }) {\n" +
+		" Name: (sql.NullString) (valuer) (string) (len=3) \"bob\",\n" +
+		" Age: (sql.NullInt64) (valuer) <null>,\n" +
+		" Other: (spew_test.failingValuer) {\n" +
+		"  ID: (int) 0\n" +
+		" }\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("UseValuer dump\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "{bob <null> {0}}"
+	if s := cs.Sprintf("%v", v); s != want {
+		t.Errorf("UseValuer format\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestDumpEllipsis ensures the Ellipsis option unifies all truncation markers.
+func TestDumpEllipsis(t *testing.T) {
+	v := struct {
+		List  []int
+		Bytes []byte
+		Deep  [][]int
+		Maps  map[string]map[string]int
+	}{
+		List:  []int{1, 2, 3},
+		Bytes: []byte("0123456789abcdefXYZ"),
+		Deep:  [][]int{{1, 2}},
+		Maps:  map[string]map[string]int{"a": {"b": 1}},
+	}
+	cs := spew.ConfigState{Indent: " ", Ellipsis: "[cut]", MaxElements: 2,
+		MaxHexDumpBytes: 16, MaxDepth: 2, MaxMapDepth: 1}
+	want := "(struct { List []int; Bytes []uint8; Deep [][]int; " +
+		"Maps map[string]map[string]int }) {\n" +
+		" List: ([]int) (len=3 cap=3) {\n" +
+		"  (int) 1,\n" +
+		"  (int) 2,\n" +
+		"  [cut] (1 more)\n" +
+		" },\n" +
+		" Bytes: ([]uint8) (len=19 cap=19) {\n" +
+		"  00000000  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66" +
+		"  |0123456789abcdef|\n" +
+		"  [cut] (3 more)\n" +
+		" },\n" +
+		" Deep: ([][]int) (len=1 cap=1) {\n" +
+		"  ([]int) (len=2 cap=2) {\n" +
+		"   [cut] (2 more)\n" +
+		"  }\n" +
+		" },\n" +
+		" Maps: (map[string]map[string]int) (len=1) {\n" +
+		"  (string) (len=1) \"a\": (map[string]int) (len=1) " +
+		"{[cut] (1 more)}\n" +
+		" }\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("Ellipsis dump\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "{[1 2 [cut] (1 more)] [48 49 [cut] (17 more)] " +
+		"[[[cut] (2 more)]] map[a:map[[cut] (1 more)]]}"
+	if s := cs.Sprintf("%v", v); s != want {
+		t.Errorf("Ellipsis format\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// sexpFlag is used to test SdumpSexp with Stringer output.
+type sexpFlag int
+
+// String returns the name of the flag.
+func (f sexpFlag) String() string {
+	return "flagTwo"
+}
+
+// sexpFoo is used to test SdumpSexp.
+type sexpFoo struct {
+	flag   sexpFlag
+	data   *int
+	tags   []string
+	limits map[string]float64
+	next   *sexpFoo
+}
+
+// TestSdumpSexp ensures SdumpSexp produces the expected s-expressions.
+func TestSdumpSexp(t *testing.T) {
+	v := &sexpFoo{flag: 2, tags: []string{"a", "b c"},
+		limits: map[string]float64{"rate": 1.5, "conn": 5}}
+	v.next = v
+	cs := spew.ConfigState{SortKeys: true}
+	want := "(sexpFoo (flag flagTwo) (data nil) (tags (\"a\" \"b c\")) " +
+		"(limits ((\"conn\" . 5) (\"rate\" . 1.5))) (next #cyclic))\n"
+	if spew.UnsafeDisabled {
+		want = strings.Replace(want, "flagTwo", "2", 1)
+	}
+	if s := cs.SdumpSexp(v); s != want {
+		t.Errorf("SdumpSexp\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "((1 . true) (2 . nil)) \"x y\" (complex 1 -2)"
+	s := strings.TrimSuffix(cs.SdumpSexp(map[int]interface{}{1: true,
+		2: nil}), "\n") + " " + strings.TrimSuffix(cs.SdumpSexp(
+		errors.New("x y")), "\n") + " " +
+		strings.TrimSuffix(cs.SdumpSexp(complex(1, -2)), "\n")
+	if s != want {
+		t.Errorf("SdumpSexp scalars\n got: %q\nwant: %q", s, want)
+	}
+
+	m := map[string]interface{}{"a": 1}
+	m["self"] = m
+	sl := []interface{}{1, nil}
+	sl[1] = sl
+	want = "((\"a\" . 1) (\"self\" . #cyclic)) (1 #cyclic)"
+	s = strings.TrimSuffix(cs.SdumpSexp(m), "\n") + " " +
+		strings.TrimSuffix(cs.SdumpSexp(sl), "\n")
+	if s != want {
+		t.Errorf("SdumpSexp self-referential\n got: %q\nwant: %q", s, want)
+	}
+
+	acs := spew.ConfigState{ASCIIOnly: true}
+	want = "\"h\\u00e9llo\"\n"
+	if s := acs.SdumpSexp("h\u00e9llo"); s != want {
+		t.Errorf("SdumpSexp ASCIIOnly\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestMarkNilMapValues ensures the MarkNilMapValues option marks nil pointer
+// and nil interface map values in both Dump and the custom formatter.
+func TestMarkNilMapValues(t *testing.T) {
+	cs := spew.ConfigState{Indent: " ", SortKeys: true, MarkNilMapValues: true}
+	v := map[string]*int{"a": nil}
+	want := "(map[string]*int) (len=1) {\n (string) (len=1) \"a\": <nil value>\n}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("MarkNilMapValues\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "map[a:<nil value> b:1]"
+	if s := cs.Sprintf("%v", map[string]interface{}{"a": nil, "b": 1}); s != want {
+		t.Errorf("MarkNilMapValues %%v\n got: %q\nwant: %q", s, want)
+	}
+
+	cs.MarkNilMapValues = false
+	want = "(map[string]*int) (len=1) {\n (string) (len=1) \"a\": (*int)(<nil>)\n}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("MarkNilMapValues disabled\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestSdumpSizeHint ensures the SdumpSizeHint option does not alter the output.
+func TestSdumpSizeHint(t *testing.T) {
+	v := []string{"a", "b"}
+	cs := spew.ConfigState{Indent: " "}
+	want := cs.Sdump(v)
+	for _, hint := range []int{-1, 1, 4096} {
+		cs.SdumpSizeHint = hint
+		if s := cs.Sdump(v); s != want {
+			t.Errorf("SdumpSizeHint %d\n got: %q\nwant: %q", hint, s, want)
+		}
+		if s, _ := cs.SafeSdump(v); s != want {
+			t.Errorf("SdumpSizeHint %d SafeSdump\n got: %q\nwant: %q",
+				hint, s, want)
+		}
+	}
+}
+
+// BenchmarkSdumpSizeHint compares the allocations made by Sdump for a large
+// value with and without the SdumpSizeHint option.
+func BenchmarkSdumpSizeHint(b *testing.B) {
+	v := make([]string, 1000)
+	for i := range v {
+		v[i] = fmt.Sprintf("entry %d", i)
+	}
+	size := len(spew.Sdump(v))
+	for _, hint := range []int{0, size} {
+		cs := spew.ConfigState{Indent: " ", SdumpSizeHint: hint}
+		b.Run(fmt.Sprintf("hint=%d", hint), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				cs.Sdump(v)
+			}
+		})
+	}
+}
+
+// TestSortKeysFallback ensures SortKeys orders maps whose keys can't be sorted
+// natively by their spewed strings even when SpewKeys is not set.
+func TestSortKeysFallback(t *testing.T) {
+	type key struct{ X int }
+	m := map[key]int{{3}: 3, {1}: 1, {12}: 12, {2}: 2}
+	cs := spew.ConfigState{SortKeys: true}
+	want := "map[{12}:12 {1}:1 {2}:2 {3}:3]"
+	for i := 0; i < 10; i++ {
+		if s := cs.Sprintf("%v", m); s != want {
+			t.Fatalf("SortKeys fallback\n got: %q\nwant: %q", s, want)
+		}
+	}
+
+	// Keys holding channels are ordered by their remaining content rather
+	// than by where the channels happen to be allocated.
+	type chanKey struct {
+		C chan int
+		N int
+	}
+	cm := make(map[chanKey]int)
+	for _, n := range []int{3, 1, 2} {
+		cm[chanKey{make(chan int), n}] = n
+	}
+	cs.StableAddresses = true
+	want = "map[{0x1 1}:1 {0x2 2}:2 {0x3 3}:3]"
+	if s := cs.Sprintf("%v", cm); s != want {
+		t.Errorf("SortKeys fallback channels\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// hashNode is used to test the HashNodes option.
+type hashNode struct {
+	Name string
+	Tags []string
+	Next *hashNode
+}
+
+// TestHashNodes ensures the HashNodes option annotates nodes with hashes which
+// are identical for identical content regardless of where it appears.
+func TestHashNodes(t *testing.T) {
+	cs := spew.ConfigState{Indent: " ", HashNodes: true}
+	hashRE := regexp.MustCompile(`\[hash=([0-9a-f]{16})\] \{\n *Name`)
+
+	inner := &hashNode{Name: "leaf", Tags: []string{"x"}}
+	top := cs.Sdump(*inner)
+	nested := cs.Sdump(hashNode{Name: "root", Next: inner})
+	topHashes := hashRE.FindAllStringSubmatch(top, -1)
+	nestedHashes := hashRE.FindAllStringSubmatch(nested, -1)
+	if len(topHashes) != 1 || len(nestedHashes) != 2 {
+		t.Fatalf("HashNodes missing hashes:\n%s\n%s", top, nested)
+	}
+	if topHashes[0][1] != nestedHashes[1][1] {
+		t.Errorf("HashNodes hashes differ for identical content: %s != %s",
+			topHashes[0][1], nestedHashes[1][1])
+	}
+	if nestedHashes[0][1] == nestedHashes[1][1] {
+		t.Errorf("HashNodes hashes match for different content")
+	}
+	if !strings.Contains(top, "([]string) (len=1 cap=1) [hash=") {
+		t.Errorf("HashNodes slice is missing a hash:\n%s", top)
+	}
+
+	// Nil slices and scalars are not annotated.
+	s := cs.Sdump(hashNode{})
+	if strings.Count(s, "[hash=") != 1 {
+		t.Errorf("HashNodes annotated unexpected nodes:\n%s", s)
+	}
+
+	// Cycles must terminate.
+	inner.Next = inner
+	if s := cs.Sdump(inner); !strings.Contains(s, "<already shown>") {
+		t.Errorf("HashNodes cycle not detected:\n%s", s)
+	}
+
+	// Every node is only rendered once, so the Transform option is only
+	// called once for values nested several levels deep.
+	calls := 0
+	cs.Transform = func(v reflect.Value) (reflect.Value, bool) {
+		if v.Kind() == reflect.String {
+			calls++
+		}
+		return v, false
+	}
+	cs.Sdump([]hashNode{{Name: "a", Next: &hashNode{Name: "b"}}})
+	if calls != 2 {
+		t.Errorf("HashNodes transformed strings %d times, want 2", calls)
+	}
+}
+
+// docTagConfig is used to test the DocTag option.
+type docTagConfig struct {
+	Host    string
+	Timeout int `doc:"connection timeout\n\tin  seconds"`
+	Retries int `doc:"number of retries"`
+}
+
+// TestDocTag ensures the DocTag option displays field documentation as
+// trailing comments.
+func TestDocTag(t *testing.T) {
+	v := docTagConfig{Host: "db", Timeout: 30, Retries: 2}
+	cs := spew.ConfigState{Indent: " ", DocTag: "doc"}
+	want := "(spew_test.docTagConfig) {\n" +
+		" Host: (string) (len=2) \"db\",\n" +
+		" Timeout: (int) 30, // connection timeout in seconds\n" +
+		" Retries: (int) 2 // number of retries\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("DocTag\n got: %q\nwant: %q", s, want)
+	}
+
+	cs.DocTag = ""
+	if s := cs.Sdump(v); strings.Contains(s, "//") {
+		t.Errorf("DocTag disabled unexpectedly displayed comments:\n%s", s)
+	}
+}
+
+// flatNode is used to test the FlattenPointers option.
+type flatNode struct {
+	Name  string
+	Left  *flatNode
+	Right *flatNode
+}
+
+// TestFlattenPointers ensures the FlattenPointers option displays pointer
+// targets directly while preserving aliasing and circular references.
+func TestFlattenPointers(t *testing.T) {
+	shared := &flatNode{Name: "shared"}
+	root := &flatNode{Name: "root", Left: shared, Right: shared}
+	shared.Left = root
+	cs := spew.ConfigState{Indent: " ", FlattenPointers: true}
+	want := "#1 (spew_test.flatNode) {\n" +
+		" Name: (string) (len=4) \"root\",\n" +
+		" Left: #2 (spew_test.flatNode) {\n" +
+		"  Name: (string) (len=6) \"shared\",\n" +
+		"  Left: <seen #1>,\n" +
+		"  Right: (*spew_test.flatNode)(<nil>)\n" +
+		" },\n" +
+		" Right: <seen #2>\n" +
+		"}\n"
+	if s := cs.Sdump(root); s != want {
+		t.Errorf("FlattenPointers\n got: %q\nwant: %q", s, want)
+	}
+
+	// Unshared pointers are not labeled.
+	n := 5
+	want = "(struct { P *int }) {\n P: (int) 5\n}\n"
+	if s := cs.Sdump(struct{ P *int }{&n}); s != want {
+		t.Errorf("FlattenPointers unshared\n got: %q\nwant: %q", s, want)
+	}
+
+	// Maps and slices which contain themselves are circular.
+	m := map[string]interface{}{}
+	m["self"] = m
+	want = "(map[string]interface {}) (len=1) {\n" +
+		" (string) (len=4) \"self\": (map[string]interface {}) <already shown>\n" +
+		"}\n"
+	if s := cs.Sdump(m); s != want {
+		t.Errorf("FlattenPointers self-referential map\n got: %q\nwant: %q", s, want)
+	}
+	sl := []interface{}{nil}
+	sl[0] = sl
+	want = "([]interface {}) (len=1 cap=1) {\n" +
+		" ([]interface {}) <already shown>\n" +
+		"}\n"
+	if s := cs.Sdump(sl); s != want {
+		t.Errorf("FlattenPointers self-referential slice\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestTimeLayout ensures the TimeLayout option formats times and pointers to
+// times according to the configured layout.
+func TestTimeLayout(t *testing.T) {
+	tm := time.Date(2024, 3, 9, 14, 5, 7, 123000000, time.UTC)
+	cs := spew.ConfigState{TimeLayout: "2006-01-02 15:04"}
+	want := "(time.Time) 2024-03-09 14:05\n"
+	if s := cs.Sdump(tm); s != want {
+		t.Errorf("TimeLayout\n got: %q\nwant: %q", s, want)
+	}
+	if s := cs.Sdump(&tm); !strings.HasSuffix(s, ")(2024-03-09 14:05)\n") {
+		t.Errorf("TimeLayout pointer\n got: %q", s)
+	}
+	want = "{When:2024-03-09 14:05}"
+	if s := cs.Sprintf("%+v", struct{ When time.Time }{tm}); s != want {
+		t.Errorf("TimeLayout %%+v\n got: %q\nwant: %q", s, want)
+	}
+
+	// The zero time is formatted by the layout as well.
+	cs = *spew.NewDefaultConfig()
+	want = "(time.Time) 0001-01-01T00:00:00Z\n"
+	if s := cs.Sdump(time.Time{}); s != want {
+		t.Errorf("TimeLayout zero\n got: %q\nwant: %q", s, want)
+	}
+	want = "(time.Time) 2024-03-09T14:05:07.123Z\n"
+	if s := cs.Sdump(tm); s != want {
+		t.Errorf("TimeLayout default\n got: %q\nwant: %q", s, want)
+	}
+
+	// An empty layout falls back to the String method.
+	cs.TimeLayout = ""
+	want = "(time.Time) " + tm.String() + "\n"
+	if s := cs.Sdump(tm); s != want {
+		t.Errorf("TimeLayout empty\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestIncludeCaller ensures the IncludeCaller option precedes the output with
+// the location of the call site.
+func TestIncludeCaller(t *testing.T) {
+	cs := spew.ConfigState{IncludeCaller: true}
+	_, _, line, _ := runtime.Caller(0)
+	s := cs.Sdump(1)
+	want := fmt.Sprintf("dump_test.go:%d:\n(int) 1\n", line+1)
+	if s != want {
+		t.Errorf("IncludeCaller\n got: %q\nwant: %q", s, want)
+	}
+
+	// The location is not included within Printf output.
+	if s := cs.Sprintf("% v", 1); s != "(int) 1" {
+		t.Errorf("IncludeCaller %% v\n got: %q", s)
+	}
+}
+
+// bitPerm is used to test RegisterBitflags.
+type bitPerm int8
+
+// String returns the name of single permissions only.
+func (p bitPerm) String() string {
+	return "perm"
+}
+
+// TestRegisterBitflags ensures registered bitmask types are displayed as their
+// set flags.
+func TestRegisterBitflags(t *testing.T) {
+	cs := spew.ConfigState{}
+	cs.RegisterBitflags(reflect.TypeOf(bitPerm(0)),
+		map[uint64]string{1: "read", 2: "write", 4: "exec"})
+	tests := []struct {
+		in   bitPerm
+		want string
+	}{
+		{5, "(spew_test.bitPerm) read|exec (0b101)\n"},
+		{2, "(spew_test.bitPerm) write (0b10)\n"},
+		{0x1b, "(spew_test.bitPerm) read|write|0x18 (0b11011)\n"},
+		{0, "(spew_test.bitPerm) 0 (0b0)\n"},
+		{-1, "(spew_test.bitPerm) read|write|exec|0xf8 (0b11111111)\n"},
+	}
+	for _, test := range tests {
+		if s := cs.Sdump(test.in); s != test.want {
+			t.Errorf("RegisterBitflags %d\n got: %q\nwant: %q", test.in,
+				s, test.want)
+		}
+	}
+
+	cs.RegisterBitflags(reflect.TypeOf(bitPerm(0)),
+		map[uint64]string{0: "none", 1: "read"})
+	want := "{none (0b0) read (0b1)}"
+	if s := cs.Sprintf("%v", struct{ A, B bitPerm }{0, 1}); s != want {
+		t.Errorf("RegisterBitflags %%v\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestShowSliceIndices ensures the ShowSliceIndices option labels array and
+// slice elements with their aligned indices, including collapsed runs.
+func TestShowSliceIndices(t *testing.T) {
+	v := make([]int, 11)
+	v[10] = 1
+	cs := spew.ConfigState{Indent: " ", ShowSliceIndices: true}
+	s := cs.Sdump(v)
+	if !strings.Contains(s, "\n [ 0] (int) 0,\n [ 1] (int) 0,\n") ||
+		!strings.HasSuffix(s, "\n [10] (int) 1\n}\n") {
+		t.Errorf("ShowSliceIndices\n got: %q", s)
+	}
+
+	cs.RunLengthEncode = true
+	want := "([]int) (len=11 cap=11) {\n" +
+		" [ 0] (int) 0 (×10),\n" +
+		" [10] (int) 1\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("ShowSliceIndices RunLengthEncode\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "([1][]string) (len=1 cap=1) {\n" +
+		" [0] ([]string) (len=1 cap=1) {\n" +
+		"  [0] (string) (len=1) \"a\"\n" +
+		" }\n" +
+		"}\n"
+	cs.RunLengthEncode = false
+	if s := cs.Sdump([1][]string{{"a"}}); s != want {
+		t.Errorf("ShowSliceIndices nested\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// fieldsFoo is used to test Fields.
+type fieldsFoo struct {
+	flag   sexpFlag
+	data   int
+	Tags   []string
+	Limits map[string]int
+	Next   *fieldsFoo
+	Raw    []byte
+	Secret string
+}
+
+// TestFields ensures Fields flattens values into dotted keys.
+func TestFields(t *testing.T) {
+	v := &fieldsFoo{flag: 2, data: 5, Tags: []string{"a", "b"},
+		Limits: map[string]int{"conn": 3}, Raw: []byte{1}, Secret: "x"}
+	v.Next = v
+	cs := spew.ConfigState{Transform: func(v reflect.Value) (reflect.Value, bool) {
+		if v.Kind() == reflect.String && v.String() == "x" {
+			return reflect.Value{}, true
+		}
+		return v, false
+	}}
+	flag := interface{}("flagTwo")
+	if spew.UnsafeDisabled {
+		flag = int64(2)
+	}
+	want := map[string]interface{}{
+		"fieldsFoo.flag":        flag,
+		"fieldsFoo.data":        5,
+		"fieldsFoo.Tags.0":      "a",
+		"fieldsFoo.Tags.1":      "b",
+		"fieldsFoo.Limits.conn": 3,
+		"fieldsFoo.Next":        "<already shown>",
+		"fieldsFoo.Raw":         []byte{1},
+		"fieldsFoo.Secret":      "<omitted>",
+	}
+	if spew.UnsafeDisabled {
+		want["fieldsFoo.data"] = int64(5)
+	}
+	if got := cs.Fields(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields\n got: %#v\nwant: %#v", got, want)
+	}
+
+	cs = spew.ConfigState{ExportedOnly: true}
+	want = map[string]interface{}{"A": 1, "C": nil}
+	if got := cs.Fields(struct {
+		A int
+		b int
+		C *int
+	}{A: 1, b: 2}); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields ExportedOnly\n got: %#v\nwant: %#v", got, want)
+	}
+
+	want = map[string]interface{}{"int": 42}
+	if got := spew.Fields(42); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields scalar\n got: %#v\nwant: %#v", got, want)
+	}
+
+	sl := []interface{}{1, nil}
+	sl[1] = sl
+	want = map[string]interface{}{"0": 1, "1": "<already shown>"}
+	if got := spew.Fields(sl); !reflect.DeepEqual(got, want) {
+		t.Errorf("Fields self-referential slice\n got: %#v\nwant: %#v", got, want)
+	}
+}
+
+// bothFoo is used to test the ShowBothMethodAndInternals option.
+type bothFoo struct {
+	N int
+}
+
+// String returns a summary which hides the internals.
+func (f bothFoo) String() string {
+	return "all good"
+}
+
+// TestShowBothMethodAndInternals ensures the ShowBothMethodAndInternals option
+// displays the method output along with the internals.
+func TestShowBothMethodAndInternals(t *testing.T) {
+	cs := spew.ConfigState{Indent: " ", ShowBothMethodAndInternals: true}
+	want := "(spew_test.bothFoo) \"all good\" {\n N: (int) -1\n}\n"
+	if s := cs.Sdump(bothFoo{-1}); s != want {
+		t.Errorf("ShowBothMethodAndInternals\n got: %q\nwant: %q", s, want)
+	}
+
+	// It takes precedence over ContinueOnMethod.
+	cs.ContinueOnMethod = true
+	if s := cs.Sdump(bothFoo{-1}); s != want {
+		t.Errorf("ShowBothMethodAndInternals ContinueOnMethod\n got: %q\nwant: %q",
+			s, want)
+	}
+
+	want = "\"all good\" {N:-1}"
+	if s := cs.Sprintf("%+v", bothFoo{-1}); s != want {
+		t.Errorf("ShowBothMethodAndInternals %%+v\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestCorruptSlice ensures slices with corrupted headers are marked as corrupt
+// rather than crashing.
+func TestCorruptSlice(t *testing.T) {
+	type sliceHeader struct {
+		data     unsafe.Pointer
+		len, cap int
+	}
+	v := []int{1, 2}
+	hdr := (*sliceHeader)(unsafe.Pointer(&v))
+	hdr.len = 1000
+
+	want := "([]int) (len=1000 cap=2) <corrupt slice>\n"
+	if s := spew.Sdump(v); s != want {
+		t.Errorf("corrupt slice\n got: %q\nwant: %q", s, want)
+	}
+	want = "<corrupt slice>"
+	if s := spew.Sprintf("%v", v); s != want {
+		t.Errorf("corrupt slice %%v\n got: %q\nwant: %q", s, want)
+	}
+
+	hdr.len, hdr.cap = -1, -1
+	want = "([]int) (len=-1 cap=-1) <corrupt slice>\n"
+	if s := spew.Sdump(v); s != want {
+		t.Errorf("negative slice\n got: %q\nwant: %q", s, want)
+	}
+	hdr.len, hdr.cap = 2, 2
+}
+
+// TestCorruptSliceNestedPanic ensures an index out of range panic raised while
+// displaying the elements of a valid slice is not mistaken for a corrupt slice.
+func TestCorruptSliceNestedPanic(t *testing.T) {
+	var empty []int
+	cs := spew.ConfigState{Indent: " ", Transform: func(v reflect.Value) (reflect.Value, bool) {
+		if v.Kind() == reflect.String {
+			return reflect.ValueOf(empty[v.Len()]), true
+		}
+		return v, false
+	}}
+	func() {
+		defer func() {
+			if r := recover(); r == nil {
+				t.Errorf("Sdump: expected the Transform panic to propagate")
+			}
+		}()
+		s := cs.Sdump([]interface{}{1, "a", 2})
+		t.Errorf("Sdump: unexpected output %q", s)
+	}()
+
+	// The fmt package recovers the panic itself and reports it in place.
+	s := cs.Sprintf("%v", []interface{}{1, "a", 2})
+	if !strings.Contains(s, "PANIC=") || strings.Contains(s, "<corrupt slice>") {
+		t.Errorf("Sprintf: unexpected output %q", s)
+	}
+
+	cs.Transform = nil
+	cs.RunLengthEncode = true
+	want := "([]string) (len=4 cap=4) {\n (string) (len=1) \"a\" (×3),\n (string) (len=1) \"b\"\n}\n"
+	if s := cs.Sdump([]string{"a", "a", "a", "b"}); s != want {
+		t.Errorf("RunLengthEncode\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestSdumpMarkdown ensures SdumpMarkdown wraps the dump in a fenced code block
+// which can't be broken out of by backticks in the values.
+func TestSdumpMarkdown(t *testing.T) {
+	cs := spew.ConfigState{Indent: " ", NoTrailingNewline: true}
+	want := "```go\n(string) (len=2) \"ab\"\n```\n"
+	if s := cs.SdumpMarkdown("ab"); s != want {
+		t.Errorf("SdumpMarkdown\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "`````go\n(string) (len=6) \"a````b\"\n`````\n"
+	if s := cs.SdumpMarkdown("a````b"); s != want {
+		t.Errorf("SdumpMarkdown backticks\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// policyFoo is used to test the UnexportedPolicy option.
+type policyFoo struct {
+	Name   string
+	secret int
+	When   time.Time
+}
+
+// TestUnexportedPolicy ensures the UnexportedPolicy option hides unexported
+// fields according to the package of the type defining them.
+func TestUnexportedPolicy(t *testing.T) {
+	v := policyFoo{Name: "a", secret: 1}
+	cs := spew.ConfigState{Indent: " ", DisableMethods: true,
+		UnexportedPolicy: spew.UnexportedOwnPackagesOnly,
+		OwnPackages:      []string{"github.com/dvln/go-spew/"}}
+	want := "(spew_test.policyFoo) {\n" +
+		" Name: (string) (len=1) \"a\",\n" +
+		" secret: (int) 1,\n" +
+		" When: (time.Time) {\n" +
+		" }\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("UnexportedOwnPackagesOnly\n got: %q\nwant: %q", s, want)
+	}
+
+	cs.UnexportedPolicy = spew.UnexportedNone
+	if s := cs.Sdump(v); strings.Contains(s, "secret") {
+		t.Errorf("UnexportedNone displayed unexported fields:\n%s", s)
+	}
+
+	cs.UnexportedPolicy = spew.UnexportedAll
+	if s := cs.Sdump(v); !strings.Contains(s, "secret") ||
+		!strings.Contains(s, "wall") {
+		t.Errorf("UnexportedAll hid unexported fields:\n%s", s)
+	}
+}
+
+// TestCollapseRepeatedTypes ensures the CollapseRepeatedTypes option omits the
+// annotations of homogeneous elements only.
+func TestCollapseRepeatedTypes(t *testing.T) {
+	cs := spew.ConfigState{Indent: " ", CollapseRepeatedTypes: true}
+	want := "([]int) (len=2 cap=2) {\n 1,\n 2\n}\n"
+	if s := cs.Sdump([]int{1, 2}); s != want {
+		t.Errorf("CollapseRepeatedTypes\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "([]interface {}) (len=2 cap=2) (elem=string) {\n" +
+		" (len=1) \"a\",\n" +
+		" (len=1) \"b\"\n" +
+		"}\n"
+	if s := cs.Sdump([]interface{}{"a", "b"}); s != want {
+		t.Errorf("CollapseRepeatedTypes interface\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "([]interface {}) (len=2 cap=2) {\n" +
+		" (string) (len=1) \"a\",\n" +
+		" (int) 1\n" +
+		"}\n"
+	if s := cs.Sdump([]interface{}{"a", 1}); s != want {
+		t.Errorf("CollapseRepeatedTypes heterogeneous\n got: %q\nwant: %q",
+			s, want)
+	}
+
+	cs.RunLengthEncode = true
+	want = "([4]int) (len=4 cap=4) {\n 0 (×3),\n 1\n}\n"
+	if s := cs.Sdump([4]int{0, 0, 0, 1}); s != want {
+		t.Errorf("CollapseRepeatedTypes RunLengthEncode\n got: %q\nwant: %q",
+			s, want)
+	}
+}
+
+// hugeStringer is used to test the MaxStringLen option with a Stringer which
+// returns a huge string.
+type hugeStringer struct{}
+
+// String returns a megabyte of text.
+func (hugeStringer) String() string {
+	return strings.Repeat("x", 1<<20)
+}
+
+// TestMaxStringLen ensures the MaxStringLen option limits both string values
+// and the output of methods.
+func TestMaxStringLen(t *testing.T) {
+	cs := spew.ConfigState{MaxStringLen: 4}
+	want := "(spew_test.hugeStringer) xxxx ... (1048572 more)\n"
+	if s := cs.Sdump(hugeStringer{}); s != want {
+		t.Errorf("MaxStringLen Stringer\n got: %q\nwant: %q", s, want)
+	}
+	want = "<*>oops ... (8 more)"
+	if s := cs.Sprintf("%v", errors.New("oops, failed")); s != want {
+		t.Errorf("MaxStringLen error\n got: %q\nwant: %q", s, want)
+	}
+
+	// Strings are cut at a character boundary.
+	want = "(string) (len=7) \"ab\" ... (5 more)\n"
+	if s := cs.Sdump("ab€cd"); s != want {
+		t.Errorf("MaxStringLen string\n got: %q\nwant: %q", s, want)
+	}
+	want = "abc"
+	if s := cs.Sprintf("%v", "abc"); s != want {
+		t.Errorf("MaxStringLen short\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestExpandImageTypes ensures the ExpandImageTypes option displays geometry
+// and color types compactly.
+func TestExpandImageTypes(t *testing.T) {
+	cs := spew.ConfigState{ExpandImageTypes: true, DisableMethods: true}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{image.Rect(0, 0, 640, 480), "(image.Rectangle) (0,0)-(640,480)\n"},
+		{image.Pt(-1, 2), "(image.Point) (-1,2)\n"},
+		{color.RGBA{0xff, 0x80, 0, 0xff}, "(color.RGBA) #FF8000FF\n"},
+		{color.NRGBA64{1, 2, 3, 4}, "(color.NRGBA64) #0001000200030004\n"},
+	}
+	for _, test := range tests {
+		if s := cs.Sdump(test.in); s != test.want {
+			t.Errorf("ExpandImageTypes\n got: %q\nwant: %q", s, test.want)
+		}
+	}
+
+	want := "{Bounds:(1,2)-(3,4) Fill:#01020304}"
+	v := struct {
+		Bounds image.Rectangle
+		Fill   color.RGBA
+	}{image.Rect(1, 2, 3, 4), color.RGBA{1, 2, 3, 4}}
+	if s := cs.Sprintf("%+v", v); s != want {
+		t.Errorf("ExpandImageTypes %%+v\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// prefixWriter writes a prefix ahead of each line written to the wrapped
+// writer.
+type prefixWriter struct {
+	w      io.Writer
+	prefix string
+	mid    bool
+}
+
+func (pw *prefixWriter) Write(p []byte) (int, error) {
+	for _, line := range bytes.SplitAfter(p, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if !pw.mid {
+			io.WriteString(pw.w, pw.prefix)
+		}
+		pw.w.Write(line)
+		pw.mid = line[len(line)-1] != '\n'
+	}
+	return len(p), nil
+}
+
+// TestWriterWrapper ensures the output of dumps is written through the writer
+// returned by the WriterWrapper option, and that buffered wrappers and their
+// destinations are both flushed when the AutoFlush option is enabled.
+func TestWriterWrapper(t *testing.T) {
+	cfg := spew.ConfigState{Indent: " ", WriterWrapper: func(w io.Writer) io.Writer {
+		return &prefixWriter{w: w, prefix: "> "}
+	}}
+	s := cfg.Sdump([]int{1, 2})
+	want := "> ([]int) (len=2 cap=2) {\n>  (int) 1,\n>  (int) 2\n> }\n"
+	if s != want {
+		t.Errorf("WriterWrapper\n got: %q\nwant: %q", s, want)
+	}
+
+	// The fences of Markdown output are written through the wrapper too.
+	want = "> ```go\n> (int) 1\n> ```\n"
+	if s := cfg.SdumpMarkdown(1); s != want {
+		t.Errorf("WriterWrapper SdumpMarkdown\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "> int\n"
+	if s := cfg.SdumpSchema(1); s != want {
+		t.Errorf("WriterWrapper SdumpSchema\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "> \"a\"\n"
+	if s := cfg.SdumpSexp("a"); s != want {
+		t.Errorf("WriterWrapper SdumpSexp\n got: %q\nwant: %q", s, want)
+	}
+
+	var buf bytes.Buffer
+	dst := bufio.NewWriter(&buf)
+	cfg = spew.ConfigState{AutoFlush: true, WriterWrapper: func(w io.Writer) io.Writer {
+		return bufio.NewWriter(&prefixWriter{w: w, prefix: "> "})
+	}}
+	n, err := cfg.FdumpN(dst, 5)
+	if s := buf.String(); s != "> (int) 5\n" || n != len("(int) 5\n") || err != nil {
+		t.Errorf("WriterWrapper AutoFlush mismatch: %q (n %d, err %v)", s, n, err)
+	}
+}
+
+// TestSdumpSideBySide ensures SdumpSideBySide aligns the matching lines of two
+// dumps and marks the lines which differ or only exist on one side.
+func TestSdumpSideBySide(t *testing.T) {
+	type foo struct {
+		Name string
+		Tags []string
+		N    int
+	}
+	cs := spew.ConfigState{Indent: " ", SideBySideWidth: 20}
+	s := cs.SdumpSideBySide(foo{Name: "bar", N: 1},
+		foo{Name: "baz", Tags: []string{"a"}, N: 1})
+	want := "(spew_test.foo) {      (spew_test.foo) {\n" +
+		" Name: (string) (... |  Name: (string) (...\n" +
+		" Tags: ([]string)... |  Tags: ([]string)...\n" +
+		"                     >   (string) (len=1...\n" +
+		"                     >  },\n" +
+		" N: (int) 1             N: (int) 1\n" +
+		"}                      }\n"
+	if s != want {
+		t.Errorf("SdumpSideBySide\n got: %q\nwant: %q", s, want)
+	}
+
+	s = cs.SdumpSideBySide([]int{1, 2, 3}, []int{1, 3})
+	want = "([]int) (len=3 ca... | ([]int) (len=2 ca...\n" +
+		" (int) 1,               (int) 1,\n" +
+		" (int) 2,            <\n" +
+		" (int) 3                (int) 3\n" +
+		"}                      }\n"
+	if s != want {
+		t.Errorf("SdumpSideBySide\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestShowContainerCounts ensures the ShowContainerCounts option displays the
+// element counts of containers along with their type.
+func TestShowContainerCounts(t *testing.T) {
+	cs := spew.ConfigState{Indent: " ", ShowContainerCounts: true}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{map[string]int{"a": 1}, "(map[string]int len=1) {\n (string) (len=1) \"a\": (int) 1\n}\n"},
+		{make([]int, 1, 4), "([]int len=1 cap=4) {\n (int) 0\n}\n"},
+		{[]int{}, "([]int len=0 cap=0) {\n}\n"},
+		{[2]bool{}, "([2]bool len=2 cap=2) {\n (bool) false,\n (bool) false\n}\n"},
+		{"ab", "(string) (len=2) \"ab\"\n"},
+	}
+	for _, test := range tests {
+		if s := cs.Sdump(test.in); s != test.want {
+			t.Errorf("ShowContainerCounts\n got: %q\nwant: %q", s, test.want)
+		}
+	}
+}
+
+// TestFieldSort ensures the FieldSort option displays struct fields in the
+// configured order.
+func TestFieldSort(t *testing.T) {
+	type config struct {
+		Port    int
+		Name    string
+		Retries int
+		Host    string
+	}
+	v := config{Port: 80, Name: "web", Retries: 3, Host: "localhost"}
+	tests := []struct {
+		order spew.FieldOrder
+		want  string
+	}{
+		{spew.DeclarationFieldOrder, "{Port:80 Name:web Retries:3 Host:localhost}"},
+		{spew.NameFieldOrder, "{Host:localhost Name:web Port:80 Retries:3}"},
+		{spew.TypeThenNameFieldOrder, "{Port:80 Retries:3 Host:localhost Name:web}"},
+	}
+	for _, test := range tests {
+		cs := spew.ConfigState{FieldSort: test.order}
+		if s := cs.Sprintf("%+v", v); s != test.want {
+			t.Errorf("FieldSort %d\n got: %q\nwant: %q", test.order, s, test.want)
+		}
+	}
+
+	cs := spew.ConfigState{Indent: " ", FieldSort: spew.TypeThenNameFieldOrder}
+	want := "(spew_test.config) {\n" +
+		" Port: (int) 80,\n" +
+		" Retries: (int) 3,\n" +
+		" Host: (string) (len=9) \"localhost\",\n" +
+		" Name: (string) (len=3) \"web\"\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("FieldSort Dump\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestSelfReferentialMap ensures maps which contain themselves through an
+// interface value are detected as circular rather than recursing until the
+// maximum depth, or forever when there is none.
+func TestSelfReferentialMap(t *testing.T) {
+	m := map[string]interface{}{}
+	m["self"] = m
+	cs := spew.ConfigState{Indent: " "}
+	want := "(map[string]interface {}) (len=1) {\n" +
+		" (string) (len=4) \"self\": (map[string]interface {}) <already shown>\n" +
+		"}\n"
+	if s := cs.Sdump(m); s != want {
+		t.Errorf("self-referential map\n got: %q\nwant: %q", s, want)
+	}
+	want = "map[self:<shown>]"
+	if s := cs.Sprintf("%v", m); s != want {
+		t.Errorf("self-referential map %%v\n got: %q\nwant: %q", s, want)
+	}
+
+	// The same map displayed twice side by side isn't circular.
+	s := cs.Sprintf("%v", []interface{}{m, m})
+	want = "[map[self:<shown>] map[self:<shown>]]"
+	if s != want {
+		t.Errorf("repeated self-referential map\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestBytesEncoding ensures the BytesEncoding option displays byte arrays and
+// slices in the configured encoding.
+func TestBytesEncoding(t *testing.T) {
+	tests := []struct {
+		enc  spew.BytesEncoding
+		in   interface{}
+		want string
+	}{
+		{spew.Base64BytesEncoding, []byte{1, 2, 3},
+			"([]uint8) (len=3 cap=3) (base64) \"AQID\"\n"},
+		{spew.HexStringBytesEncoding, [2]byte{0xca, 0xfe},
+			"([2]uint8) (len=2 cap=2) (hex) \"cafe\"\n"},
+		{spew.StringBytesEncoding, []byte("hi\n"),
+			"([]uint8) (len=3 cap=3) \"hi\\n\"\n"},
+		{spew.Base64BytesEncoding, []byte{},
+			"([]uint8) {\n}\n"},
+		{spew.Base64BytesEncoding, []int{1},
+			"([]int) (len=1 cap=1) {\n (int) 1\n}\n"},
+	}
+	for _, test := range tests {
+		cs := spew.ConfigState{Indent: " ", BytesEncoding: test.enc}
+		if s := cs.Sdump(test.in); s != test.want {
+			t.Errorf("BytesEncoding %d\n got: %q\nwant: %q", test.enc, s, test.want)
+		}
+	}
+
+	cs := spew.ConfigState{BytesEncoding: spew.HexStringBytesEncoding,
+		MaxHexDumpBytes: 2}
+	want := "([]uint8) (len=4 cap=4) (hex) \"0102\" ... (2 more)\n"
+	if s := cs.Sdump([]byte{1, 2, 3, 4}); s != want {
+		t.Errorf("BytesEncoding MaxHexDumpBytes\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestBdump ensures Bdump returns the same output as Sdump as a byte slice.
+func TestBdump(t *testing.T) {
+	v := map[string]int{"one": 1}
+	want := spew.Sdump(v)
+	if b := spew.Bdump(v); string(b) != want {
+		t.Errorf("Bdump\n got: %q\nwant: %q", b, want)
+	}
+
+	cs := spew.ConfigState{Indent: "\t", SdumpSizeHint: 1024}
+	want = cs.Sdump(v, 5)
+	if b := cs.Bdump(v, 5); string(b) != want {
+		t.Errorf("ConfigState.Bdump\n got: %q\nwant: %q", b, want)
+	}
+}
+
+// TestUnexportedInterfaceMethods ensures the Stringer methods of the dynamic
+// values of unexported interface fields are invoked exactly the same as those
+// of exported ones, including pointer receiver methods on the unaddressable
+// values held by the interfaces unless DisablePointerMethods is set.
+func TestUnexportedInterfaceMethods(t *testing.T) {
+	type holder struct {
+		Exp    interface{}
+		unexp  interface{}
+		PExp   interface{}
+		punexp interface{}
+	}
+	v := holder{Exp: stringer("a"), unexp: stringer("a"),
+		PExp: pstringer("b"), punexp: pstringer("b")}
+
+	// Unexported fields can't be accessed to call methods without unsafe and
+	// unaddressable values can't be made addressable either.
+	want := "{Exp:stringer a unexp:stringer a PExp:stringer b punexp:stringer b}"
+	if spew.UnsafeDisabled {
+		want = "{Exp:stringer a unexp:a PExp:b punexp:b}"
+	}
+	var ucs spew.ConfigState
+	if s := ucs.Sprintf("%+v", v); s != want {
+		t.Errorf("unexported interface methods\n got: %q\nwant: %q", s, want)
+	}
+
+	cs := spew.ConfigState{Indent: " ", DisablePointerMethods: true}
+	want = "(spew_test.holder) {\n" +
+		" Exp: (spew_test.stringer) (len=1) stringer a,\n" +
+		" unexp: (spew_test.stringer) (len=1) stringer a,\n" +
+		" PExp: (spew_test.pstringer) (len=1) \"b\",\n" +
+		" punexp: (spew_test.pstringer) (len=1) \"b\"\n" +
+		"}\n"
+	if spew.UnsafeDisabled {
+		want = strings.Replace(want, "unexp: (spew_test.stringer) (len=1) stringer a",
+			"unexp: (spew_test.stringer) (len=1) \"a\"", 1)
+	}
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("unexported interface methods DisablePointerMethods\n"+
+			" got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestPrintLegend ensures the PrintLegend option appends a legend of only the
+// markers which appear in the output.
+func TestPrintLegend(t *testing.T) {
+	m := map[string]interface{}{"tags": []string(nil)}
+	m["self"] = m
+	cs := spew.ConfigState{Indent: " ", PrintLegend: true, SortKeys: true}
+	want := "(map[string]interface {}) (len=2) {\n" +
+		" (string) (len=4) \"self\": (map[string]interface {}) <already shown>,\n" +
+		" (string) (len=4) \"tags\": ([]string) <nil>\n" +
+		"}\n" +
+		"Legend:\n" +
+		" <nil>: nil pointer, interface, slice, map, channel, or function\n" +
+		" <already shown>: circular reference to a value which is already being displayed\n"
+	if s := cs.Sdump(m); s != want {
+		t.Errorf("PrintLegend\n got: %q\nwant: %q", s, want)
+	}
+
+	cs = spew.ConfigState{PrintLegend: true, NoTrailingNewline: true,
+		MaxElements: 1}
+	want = "([]int) (len=2 cap=2) {\n(int) 1,\n... (1 more)\n}\n" +
+		"Legend:\n... (N more): number of elements, characters, or bytes which were not displayed"
+	if s := cs.Sdump([]int{1, 2}); s != want {
+		t.Errorf("PrintLegend NoTrailingNewline\n got: %q\nwant: %q", s, want)
+	}
+
+	if s := cs.Sdump(5); s != "(int) 5" {
+		t.Errorf("PrintLegend without markers\n got: %q\nwant: %q", s, "(int) 5")
+	}
+}
+
+// TestPointerTrackLimit ensures the PointerTrackLimit option stops tracking
+// pointers for cycle detection once the limit is reached.
+func TestPointerTrackLimit(t *testing.T) {
+	type node struct {
+		Next *node
+	}
+	a, b, c := &node{}, &node{}, &node{}
+	a.Next, b.Next, c.Next = b, c, b
+
+	// The cycle from c back to b is detected when b is tracked.
+	cs := spew.ConfigState{Indent: " "}
+	want := cs.Sdump(a)
+	cs.PointerTrackLimit = 2
+	if s := cs.Sdump(a); s != want {
+		t.Errorf("PointerTrackLimit within limit\n got: %q\nwant: %q", s, want)
+	}
+
+	// Once only a is tracked the cycle is followed until MaxDepth.
+	cs = spew.ConfigState{PointerTrackLimit: 1, MaxDepth: 4}
+	s := cs.Sprintf("%v", a)
+	if strings.Contains(s, "<shown>") || !strings.Contains(s, "... (1 more)") {
+		t.Errorf("PointerTrackLimit beyond limit: %q", s)
+	}
+	cs.PointerTrackLimit = 0
+	s = cs.Sprintf("%v", a)
+	if !strings.Contains(s, "<shown>") {
+		t.Errorf("PointerTrackLimit unlimited: %q", s)
+	}
+}
+
+// TestRuneSlicesAsString ensures the RuneSlicesAsString option displays arrays
+// and slices of runes as the text they represent.
+func TestRuneSlicesAsString(t *testing.T) {
+	type code int32
+	cs := spew.ConfigState{Indent: " ", RuneSlicesAsString: true}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{[]rune("héllo"), "([]int32) (len=5 cap=5) ([]rune as string) \"héllo\"\n"},
+		{[2]rune{'o', 'k'}, "([2]int32) (len=2 cap=2) ([2]rune as string) \"ok\"\n"},
+		{[]code{1}, "([]spew_test.code) (len=1 cap=1) {\n (spew_test.code) 1\n}\n"},
+	}
+	for _, test := range tests {
+		if s := cs.Sdump(test.in); s != test.want {
+			t.Errorf("RuneSlicesAsString\n got: %q\nwant: %q", s, test.want)
+		}
+	}
+
+	v := struct{ Text []rune }{[]rune("hi")}
+	if s := cs.Sprintf("%+v", v); s != "{Text:hi}" {
+		t.Errorf("RuneSlicesAsString %%+v\n got: %q\nwant: %q", s, "{Text:hi}")
+	}
+}
+
+// methodSet is used to test the ShowMethodSet option.
+type methodSet struct {
+	N int
+}
+
+func (m methodSet) String() string                                       { return "" }
+func (m *methodSet) Reset()                                              {}
+func (m *methodSet) Printf(format string, a ...interface{}) (int, error) { return 0, nil }
+
+// TestShowMethodSet ensures the ShowMethodSet option lists the methods of
+// structs along with the difference between the value and pointer method sets.
+func TestShowMethodSet(t *testing.T) {
+	cs := spew.ConfigState{Indent: " ", ShowMethodSet: true,
+		DisableMethods: true}
+	want := "(spew_test.methodSet) {\n" +
+		" N: (int) 1\n" +
+		" // func (spew_test.methodSet) String() string\n" +
+		" // func (*spew_test.methodSet) Printf(string, ...interface {}) (int, error)\n" +
+		" // func (*spew_test.methodSet) Reset()\n" +
+		"}\n"
+	if s := cs.Sdump(methodSet{1}); s != want {
+		t.Errorf("ShowMethodSet\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// trickleWriter accepts at most a few bytes per call to Write without
+// returning an error, like a slow network writer applying backpressure.
+type trickleWriter struct {
+	buf   bytes.Buffer
+	limit int
+}
+
+func (tw *trickleWriter) Write(p []byte) (int, error) {
+	if len(p) > tw.limit {
+		p = p[:tw.limit]
+	}
+	return tw.buf.Write(p)
+}
+
+// TestFdumpPartialWrites ensures writers which accept only part of a write are
+// passed the remaining bytes again and that writers which stop accepting bytes
+// stop the dump with io.ErrShortWrite.
+func TestFdumpPartialWrites(t *testing.T) {
+	v := map[string][]string{"key": {"héllo", "world"}}
+	for _, cs := range []spew.ConfigState{
+		{Indent: " "},
+		{Indent: " ", MaxLineWidth: 20},
+		{Indent: " ", ASCIIOnly: true},
+	} {
+		want := cs.Sdump(v)
+		tw := &trickleWriter{limit: 3}
+		n, err := cs.FdumpN(tw, v)
+		if s := tw.buf.String(); s != want || n != len(want) || err != nil {
+			t.Errorf("partial writes\n got: %q (n %d, err %v)\nwant: %q",
+				s, n, err, want)
+		}
+	}
+
+	tw := &trickleWriter{limit: 0}
+	if _, err := spew.FdumpN(tw, v); err != io.ErrShortWrite {
+		t.Errorf("stalled writer error mismatch: got %v, want %v", err,
+			io.ErrShortWrite)
+	}
+}
+
+// TestKindHandlers ensures the KindHandlers option displays values of a kind
+// via its handler while individually handled types take precedence.
+func TestKindHandlers(t *testing.T) {
+	type perm uint32
+	cs := spew.ConfigState{Indent: " ", KindHandlers: map[reflect.Kind]func(reflect.Value) string{
+		reflect.Float64: func(v reflect.Value) string {
+			return fmt.Sprintf("%.2f", v.Float())
+		},
+		reflect.Uint32: func(v reflect.Value) string { return "uint32" },
+		reflect.Int64:  func(v reflect.Value) string { return "int64" },
+	}}
+	cs.RegisterBitflags(reflect.TypeOf(perm(0)), map[uint64]string{1: "read"})
+	v := struct {
+		F float64
+		p perm
+		D time.Duration
+	}{3.14159, 1, time.Second}
+	want := "{F:3.14 p:read (0b1) D:1s}"
+	cs.FormatDurations = true
+	if s := cs.Sprintf("%+v", v); s != want {
+		t.Errorf("KindHandlers\n got: %q\nwant: %q", s, want)
+	}
+
+	cs.KindHandlers[reflect.Float64] = func(v reflect.Value) string { panic("oops") }
+	want = "(float64) (PANIC=oops)1.5\n"
+	if s := cs.Sdump(1.5); s != want {
+		t.Errorf("KindHandlers panic\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// TestDerefMapKeys ensures the DerefMapKeys option displays pointer map keys by
+// the values they point to and sorts them accordingly.
+func TestDerefMapKeys(t *testing.T) {
+	type key struct {
+		ID int
+	}
+	m := map[*key]string{{2}: "b", {1}: "a", nil: "n"}
+	cs := spew.ConfigState{Indent: " ", SortKeys: true, DerefMapKeys: true}
+	want := "(map[*spew_test.key]string) (len=3) {\n" +
+		" (*spew_test.key)(<nil>): (string) (len=1) \"n\",\n" +
+		" (*spew_test.key)({\n  ID: (int) 1\n }): (string) (len=1) \"a\",\n" +
+		" (*spew_test.key)({\n  ID: (int) 2\n }): (string) (len=1) \"b\"\n" +
+		"}\n"
+	if s := cs.Sdump(m); s != want {
+		t.Errorf("DerefMapKeys\n got: %q\nwant: %q", s, want)
+	}
+
+	want = "map[<nil>:n <*>{ID:1}:a <*>{ID:2}:b]"
+	if s := cs.Sprintf("%+v", m); s != want {
+		t.Errorf("DerefMapKeys %%+v\n got: %q\nwant: %q", s, want)
+	}
+}
+
+// summarized is used to test the StringerAtMaxDepth option.
+type summarized struct {
+	A int
+}
+
+func (summarized) String() string { return "summary" }
+
+// TestStringerAtMaxDepth ensures the StringerAtMaxDepth option displays values
+// cut off by MaxDepth via their Stringer alone only at the depth boundary.
+func TestStringerAtMaxDepth(t *testing.T) {
+	v := struct{ In summarized }{summarized{1}}
+	cs := spew.ConfigState{Indent: " ", MaxDepth: 1, ContinueOnMethod: true,
+		StringerAtMaxDepth: true}
+	want := "(struct { In spew_test.summarized }) {\n" +
+		" In: (spew_test.summarized) summary\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("StringerAtMaxDepth\n got: %q\nwant: %q", s, want)
+	}
+	if s := cs.Sprintf("%+v", v); s != "{In:summary}" {
+		t.Errorf("StringerAtMaxDepth %%+v\n got: %q\nwant: %q", s, "{In:summary}")
+	}
+
+	// Within the expanded region the value is continued into as usual.
+	cs.MaxDepth = 2
+	want = "(struct { In spew_test.summarized }) {\n" +
+		" In: (spew_test.summarized) (summary) {\n" +
+		"  A: (int) 1\n" +
+		" }\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("StringerAtMaxDepth within MaxDepth\n got: %q\nwant: %q", s, want)
+	}
+
+	cs.MaxDepth = 1
+	cs.StringerAtMaxDepth = false
+	want = "(struct { In spew_test.summarized }) {\n" +
+		" In: (spew_test.summarized) (summary) {\n" +
+		"  ... (1 more)\n" +
+		" }\n" +
+		"}\n"
+	if s := cs.Sdump(v); s != want {
+		t.Errorf("StringerAtMaxDepth disabled\n got: %q\nwant: %q", s, want)
+	}
+	if !spew.NewDefaultConfig().StringerAtMaxDepth || !spew.Config.StringerAtMaxDepth {
+		t.Errorf("StringerAtMaxDepth is not enabled by default")
+	}
+}
+
+// TestExpandNetAddrs ensures the ExpandNetAddrs option displays URLs and
+// network addresses via their String methods.
+func TestExpandNetAddrs(t *testing.T) {
+	u, _ := url.Parse("https://example.com/a?b=c")
+	_, ipNet, _ := net.ParseCIDR("10.0.0.0/8")
+	mac, _ := net.ParseMAC("01:02:03:04:05:06")
+	cs := spew.ConfigState{ExpandNetAddrs: true, ContinueOnMethod: true}
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{*u, "(url.URL) https://example.com/a?b=c\n"},
+		{net.ParseIP("10.0.0.1"), "(net.IP) 10.0.0.1\n"},
+		{*ipNet, "(net.IPNet) 10.0.0.0/8\n"},
+		{mac, "(net.HardwareAddr) 01:02:03:04:05:06\n"},
+	}
+	for i, test := range tests {
+		if got := cs.Sdump(test.in); got != test.want {
+			t.Errorf("ExpandNetAddrs #%d\n got: %q\nwant: %q", i, got, test.want)
+		}
+	}
+	if got, want := cs.Sprintf("%v", u), "<*>https://example.com/a?b=c"; got != want {
+		t.Errorf("ExpandNetAddrs Sprintf got: %q want: %q", got, want)
+	}
+	if got, want := cs.Sprintf("%v", mac), "01:02:03:04:05:06"; got != want {
+		t.Errorf("ExpandNetAddrs Sprintf got: %q want: %q", got, want)
+	}
+
+	// Method invocation remains disabled by DisableMethods.
+	cs.DisableMethods = true
+	got := cs.Sdump(mac)
+	if want := "(net.HardwareAddr) (len=6 cap=6) {\n" +
+		"00000000  01 02 03 04 05 06                                 |......|\n" +
+		"}\n"; got != want {
+		t.Errorf("ExpandNetAddrs DisableMethods\n got: %q\nwant: %q", got, want)
+	}
+}
+
+// TestFingerprint ensures Fingerprint returns the same hash for structurally
+// equal values and a different one for values which differ.
+func TestFingerprint(t *testing.T) {
+	type node struct {
+		Name string
+		Next *node
+		Tags map[string]int
+	}
+	newNode := func(name string) *node {
+		tags := make(map[string]int)
+		for i := 0; i < 20; i++ {
+			tags[fmt.Sprintf("tag%d", i)] = i
+		}
+		return &node{Name: name, Next: &node{Name: "tail"}, Tags: tags}
+	}
+
+	a, b := newNode("head"), newNode("head")
+	fa := spew.Fingerprint(a)
+	if len(fa) != 64 {
+		t.Errorf("Fingerprint length got: %d want: 64", len(fa))
+	}
+	if fb := spew.Fingerprint(b); fa != fb {
+		t.Errorf("Fingerprint of equal values differs\n a: %s\n b: %s", fa, fb)
+	}
+	b.Next.Name = "other"
+	if fb := spew.Fingerprint(b); fa == fb {
+		t.Errorf("Fingerprint of different values is the same: %s", fa)
+	}
+
+	cs := spew.ConfigState{Indent: " ", IncludeCaller: true}
+	if got, want := cs.Fingerprint(a), cs.Fingerprint(newNode("head")); got != want {
+		t.Errorf("Fingerprint with IncludeCaller differs\n got: %s\nwant: %s", got, want)
+	}
+
+	// Function values are fingerprinted by name rather than by address.
+	type handler struct{ Fn func() string }
+	h := handler{Fn: func() string { return "handled" }}
+	named := spew.ConfigState{Indent: " ", ResolveFuncNames: true}
+	if got, want := spew.Fingerprint(h), named.Fingerprint(h); got != want {
+		t.Errorf("Fingerprint of func field differs\n got: %s\nwant: %s", got, want)
+	}
+}
+
+// TestFloatFormat ensures the FloatFormat option controls the notation floats
+// and the parts of complex numbers are displayed in.
+func TestFloatFormat(t *testing.T) {
+	tests := []struct {
+		format byte
+		in     interface{}
+		want   string
+	}{
+		{0, 1.5e21, "(float64) 1.5e+21\n"},
+		{'g', float32(250), "(float32) 250\n"},
+		{'e', 250.5, "(float64) 2.505e+02\n"},
+		{'f', 1.5e21, "(float64) 1500000000000000000000\n"},
+		{'f', float32(0.1), "(float32) 0.1\n"},
+		{'e', complex(1.5, -2), "(complex128) (1.5e+00-2e+00i)\n"},
+		{'?', 1.5e21, "(float64) 1.5e+21\n"},
+	}
+	for i, test := range tests {
+		cs := spew.ConfigState{FloatFormat: test.format}
+		if got := cs.Sdump(test.in); got != test.want {
+			t.Errorf("FloatFormat #%d\n got: %q\nwant: %q", i, got, test.want)
+		}
+	}
+
+	cs := spew.ConfigState{FloatFormat: 'e'}
+	if got, want := cs.Sprintf("%v", []float64{1000, 0.25}), "[1e+03 2.5e-01]"; got != want {
+		t.Errorf("FloatFormat Sprintf got: %q want: %q", got, want)
+	}
+}
+
+// TestLabelArgs ensures the LabelArgs option labels each top-level argument of
+// Dump and the Printf family with its index.
+func TestLabelArgs(t *testing.T) {
+	cs := spew.ConfigState{LabelArgs: true}
+	got := cs.Sdump(1, "a", nil)
+	want := "arg[0]:\n(int) 1\narg[1]:\n(string) (len=1) \"a\"\narg[2]:\n(interface {}) <nil>\n"
+	if got != want {
+		t.Errorf("LabelArgs Sdump\n got: %q\nwant: %q", got, want)
+	}
+
+	got = cs.Sprintf("%v %d", []int{1}, 2)
+	want = "arg[0]:\n[1] arg[1]:\n2"
+	if got != want {
+		t.Errorf("LabelArgs Sprintf\n got: %q\nwant: %q", got, want)
+	}
+
+	got = cs.Sprintf("% v", 1)
+	want = "arg[0]:\n(int) 1"
+	if got != want {
+		t.Errorf("LabelArgs Sprintf space flag\n got: %q\nwant: %q", got, want)
+	}
+
+	if got := fmt.Sprintf("%v", cs.NewFormatter(1)); got != "1" {
+		t.Errorf("LabelArgs NewFormatter got: %q want: %q", got, "1")
+	}
+}
+
+// valueTree is a recursive type whose children are held by value.
+type valueTree struct {
+	N        int
+	Children []valueTree
+}
+
+// TestDeepValueTree ensures deeply nested, but finite, trees of values are
+// dumped fully while slices which contain themselves through an interface
+// value are detected as circular.
+func TestDeepValueTree(t *testing.T) {
+	const levels = 50
+	tree := valueTree{N: 0}
+	for i := 1; i < levels; i++ {
+		tree = valueTree{N: i, Children: []valueTree{tree}}
+	}
+
+	cs := spew.ConfigState{Indent: " "}
+	s := cs.Sdump(tree)
+	if got, want := strings.Count(s, "\n"), (levels-1)*5+4; got != want {
+		t.Errorf("deep value tree line count got: %d want: %d", got, want)
+	}
+	leaf := strings.Repeat(" ", 2*(levels-1)+1) + "N: (int) 0,\n"
+	if !strings.Contains(s, leaf) || strings.Contains(s, "<already shown>") ||
+		strings.Contains(s, "more)") {
+
+		t.Errorf("deep value tree not dumped fully:\n%s", s)
+	}
+
+	want := ""
+	for i := levels - 1; i > 0; i-- {
+		want += fmt.Sprintf("{%d [", i)
+	}
+	want += "{0 <nil>}" + strings.Repeat("]}", levels-1)
+	if got := cs.Sprintf("%v", tree); got != want {
+		t.Errorf("deep value tree %%v\n got: %q\nwant: %q", got, want)
+	}
+
+	self := make([]interface{}, 1)
+	self[0] = self
+	want = "([]interface {}) (len=1 cap=1) {\n" +
+		" ([]interface {}) <already shown>\n" +
+		"}\n"
+	if got := cs.Sdump(self); got != want {
+		t.Errorf("self-referential slice\n got: %q\nwant: %q", got, want)
+	}
+	if got, want := cs.Sprintf("%v", []interface{}{self, self}),
+		"[[<shown>] [<shown>]]"; got != want {
+		t.Errorf("self-referential slice %%v\n got: %q\nwant: %q", got, want)
+	}
+}
+
+// TestConfigPresets ensures the config presets set the documented options on
+// top of the default config.
+func TestConfigPresets(t *testing.T) {
+	want := spew.NewDefaultConfig()
+	want.SortKeys = true
+	want.StableAddresses = true
+	if got := spew.ConfigForGoldenTests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ConfigForGoldenTests got: %+v want: %+v", got, want)
+	}
+
+	want = spew.NewDefaultConfig()
+	want.SortKeys = true
+	want.MaxDepth = 8
+	want.MaxElements = 64
+	want.MaxStringLen = 256
+	want.NoTrailingNewline = true
+	if got := spew.ConfigForLogging(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ConfigForLogging got: %+v want: %+v", got, want)
+	}
+
+	want = spew.NewDefaultConfig()
+	want.StructStyle = spew.KeyValueStructStyle
+	want.CompactSmallMaps = 8
+	want.OmitEmptyContainers = true
+	want.CollapseRepeatedTypes = true
+	if got := spew.ConfigCompact(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ConfigCompact got: %+v want: %+v", got, want)
+	}
+
+	// Golden test output is identical across separately allocated values.
+	cs := spew.ConfigForGoldenTests()
+	a := map[string]*int{"a": new(int), "b": new(int)}
+	b := map[string]*int{"b": new(int), "a": new(int)}
+	if sa, sb := cs.Sdump(a), cs.Sdump(b); sa != sb {
+		t.Errorf("ConfigForGoldenTests output differs\n a: %q\n b: %q", sa, sb)
+	}
+}
+
+// TestDumpChannelContents ensures the DumpChannelContents option displays the
+// elements buffered in channels and leaves them in the channels, but only when
+// UnsafeChannelDrain acknowledges the drain.
+func TestDumpChannelContents(t *testing.T) {
+	ch := make(chan int, 4)
+	ch <- 1
+	ch <- 2
+	cs := spew.ConfigState{Indent: " ", StableAddresses: true,
+		DumpChannelContents: true}
+	want := "(chan int) (len=2 cap=4) 0x1\n"
+	if got := cs.Sdump(ch); got != want {
+		t.Errorf("DumpChannelContents without UnsafeChannelDrain\n got: %q\nwant: %q",
+			got, want)
+	}
+
+	cs.UnsafeChannelDrain = true
+	want = "(chan int) (len=2 cap=4) 0x1 {\n" +
+		" (int) 1,\n" +
+		" (int) 2\n" +
+		"}\n"
+	if got := cs.Sdump(ch); got != want {
+		t.Errorf("DumpChannelContents\n got: %q\nwant: %q", got, want)
+	}
+	if len(ch) != 2 || <-ch != 1 || <-ch != 2 {
+		t.Errorf("DumpChannelContents did not restore the channel")
+	}
+
+	// Receive-only channels can't be restored and are left alone.
+	ch <- 3
+	var recv <-chan int = ch
+	want = "(<-chan int) (len=1 cap=4) 0x1\n"
+	if got := cs.Sdump(recv); got != want {
+		t.Errorf("DumpChannelContents receive-only\n got: %q\nwant: %q", got, want)
+	}
+
+	// The elements of closed channels can't be sent back.
+	close(ch)
+	want = "(chan int) (len=1 cap=4) 0x1 {\n" +
+		" (int) 3\n" +
+		"} <1 not restored>\n"
+	if got := cs.Sdump(ch); got != want {
+		t.Errorf("DumpChannelContents closed\n got: %q\nwant: %q", got, want)
+	}
+}
+
+// Types used to test the ShowTypeChain option.
+type (
+	chainInt   int
+	chainAlias chainInt
+	chainIDs   []chainInt
+)
+
+// TestShowTypeChain ensures the ShowTypeChain option follows the type
+// annotations of defined types with their underlying type.
+func TestShowTypeChain(t *testing.T) {
+	ci := chainInt(5)
+	tests := []struct {
+		in   interface{}
+		want string
+	}{
+		{ci, "(spew_test.chainInt -> int) 5\n"},
+		{chainAlias(6), "(spew_test.chainAlias -> int) 6\n"},
+		{chainIDs{1}, "(spew_test.chainIDs -> []spew_test.chainInt) (len=1 cap=1) {\n" +
+			"(spew_test.chainInt -> int) 1\n}\n"},
+		{struct{ D time.Duration }{time.Second},
+			"(struct { D time.Duration }) {\nD: (time.Duration -> int64) 1s\n}\n"},
+		{errors.New("x"), "(*errors.errorString -> struct)({\ns: (string) (len=1) \"x\"\n})\n"},
+		{3, "(int) 3\n"},
+	}
+	cs := spew.ConfigState{ShowTypeChain: true, FormatDurations: true,
+		DisableMethods: true}
+	for i, test := range tests {
+		got := cs.Sdump(test.in)
+		got = regexp.MustCompile(`\)\(0x[0-9a-f]+\)`).ReplaceAllString(got, ")")
+		if got != test.want {
+			t.Errorf("ShowTypeChain #%d\n got: %q\nwant: %q", i, got, test.want)
+		}
+	}
+}
+
+// TestFieldNameFunc ensures the FieldNameFunc option renames struct fields,
+// including embedded ones, in Dump and formatter output.
+func TestFieldNameFunc(t *testing.T) {
+	type XInner struct{ XN int }
+	type outer struct {
+		XInner
+		XName string
+		Keep  bool
+	}
+	cs := spew.ConfigState{Indent: " ",
+		FieldNameFunc: func(field reflect.StructField) string {
+			if field.Name == "Keep" {
+				return ""
+			}
+			return strings.TrimPrefix(field.Name, "X")
+		}}
+	v := outer{XInner{1}, "a", true}
+	want := "(spew_test.outer) {\n" +
+		" Inner: (spew_test.XInner) {\n" +
+		"  N: (int) 1\n" +
+		" },\n" +
+		" Name: (string) (len=1) \"a\",\n" +
+		" Keep: (bool) true\n" +
+		"}\n"
+	if got := cs.Sdump(v); got != want {
+		t.Errorf("FieldNameFunc Dump\n got: %q\nwant: %q", got, want)
+	}
+	want = "{Inner:{N:1} Name:a Keep:true}"
+	if got := cs.Sprintf("%+v", v); got != want {
+		t.Errorf("FieldNameFunc %%+v\n got: %q\nwant: %q", got, want)
+	}
+}
+
+// countingStringer counts the calls of its String method.
+type countingStringer struct{ calls int }
+
+func (c *countingStringer) String() string {
+	c.calls++
+	return "counted"
+}
+
+// TestCacheStringerResults ensures the CacheStringerResults option calls the
+// String method of a value referenced many times only once per dump.
+func TestCacheStringerResults(t *testing.T) {
+	c := &countingStringer{}
+	v := []*countingStringer{c, c, c}
+	cs := spew.ConfigState{Indent: " "}
+	cs.Sdump(v)
+	uncached := c.calls
+	if uncached != 3 {
+		t.Errorf("uncached String calls got: %d want: 3", uncached)
+	}
+
+	cs.CacheStringerResults = true
+	c.calls = 0
+	s := cs.Sdump(v)
+	if c.calls != 1 {
+		t.Errorf("cached String calls got: %d want: 1", c.calls)
+	}
+	if n := strings.Count(s, "counted"); n != 3 {
+		t.Errorf("cached String results shown got: %d want: 3\n%s", n, s)
+	}
+
+	// The cache is discarded after each dump.
+	cs.Sdump(v)
+	if c.calls != 2 {
+		t.Errorf("String calls after second dump got: %d want: 2", c.calls)
+	}
+	c.calls = 0
+	cs.Sprintf("%v", v)
+	if c.calls != 1 {
+		t.Errorf("cached String calls via Sprintf got: %d want: 1", c.calls)
+	}
+}
+
+// TestGroupFields ensures the GroupFields option separates struct fields by
+// the groups set by their spewgroup tags.
+func TestGroupFields(t *testing.T) {
+	type config struct {
+		Name string
+		Host string `spewgroup:"network"`
+		Port int    `spewgroup:"network"`
+		Dir  string `spewgroup:"storage"`
+		Tags []string
+	}
+	v := config{"a", "h", 1, "/d", nil}
+	cs := spew.ConfigState{Indent: " ", GroupFields: true}
+	want := "(spew_test.config) {\n" +
+		" Name: (string) (len=1) \"a\",\n" +
+		"\n" +
+		" // network\n" +
+		" Host: (string) (len=1) \"h\",\n" +
+		" Port: (int) 1,\n" +
+		"\n" +
+		" // storage\n" +
+		" Dir: (string) (len=2) \"/d\",\n" +
+		"\n" +
+		" Tags: ([]string) <nil>\n" +
+		"}\n"
+	if got := cs.Sdump(v); got != want {
+		t.Errorf("GroupFields\n got: %q\nwant: %q", got, want)
+	}
+
+	// Structs without groups are displayed as usual.
+	want = "(struct { A int }) {\n A: (int) 1\n}\n"
+	if got := cs.Sdump(struct{ A int }{1}); got != want {
+		t.Errorf("GroupFields ungrouped\n got: %q\nwant: %q", got, want)
+	}
+}
+
+// dagNode is a node of a directed acyclic graph used to test FlattenPointers.
+type dagNode struct {
+	N    int
+	Kids []*dagNode
+}
+
+// TestFlattenPointersConsistency ensures the FlattenPointers option labels
+// shared and circular references the same way in Dump and Printf output.
+func TestFlattenPointersConsistency(t *testing.T) {
+	shared := &dagNode{N: 2}
+	dag := &dagNode{N: 1, Kids: []*dagNode{shared, {N: 3, Kids: []*dagNode{shared}}}}
+	cs := spew.ConfigState{Indent: " ", FlattenPointers: true}
+
+	dump := cs.Sdump(dag)
+	if !strings.Contains(dump, "#2 (spew_test.dagNode) {\n   N: (int) 2,") ||
+		!strings.Contains(dump, "<seen #2>") {
+
+		t.Errorf("FlattenPointers Dump shared labels:\n%s", dump)
+	}
+	want := "{1 [#2 {2 <nil>} {3 [<seen #2>]}]}"
+	if got := cs.Sprintf("%v", dag); got != want {
+		t.Errorf("FlattenPointers %%v\n got: %q\nwant: %q", got, want)
+	}
+
+	// Circular references are marked on both paths as well.
+	cyc := &dagNode{N: 1}
+	cyc.Kids = []*dagNode{cyc}
+	dump = cs.Sdump(cyc)
+	if !strings.Contains(dump, "#1 (spew_test.dagNode)") ||
+		!strings.Contains(dump, "<seen #1>") {
+
+		t.Errorf("FlattenPointers Dump circular labels:\n%s", dump)
+	}
+	want = "#1 {1 [<seen #1>]}"
+	if got := cs.Sprintf("%v", cyc); got != want {
+		t.Errorf("FlattenPointers circular %%v\n got: %q\nwant: %q", got, want)
+	}
+
+	// Containers which contain themselves don't send the formatter into
+	// unbounded recursion while counting references.
+	m := map[string]interface{}{}
+	m["self"] = m
+	if got, want := cs.Sprintf("%v", m), "map[self:<shown>]"; got != want {
+		t.Errorf("FlattenPointers self-referential map %%v\n got: %q\nwant: %q",
+			got, want)
+	}
+	sl := []interface{}{nil}
+	sl[0] = sl
+	if got, want := cs.Sprintf("%v", sl), "[<shown>]"; got != want {
+		t.Errorf("FlattenPointers self-referential slice %%v\n got: %q\nwant: %q",
+			got, want)
+	}
+}
+
+// schemaItem is used to test SdumpSchema.
+type schemaItem struct {
+	Name string
+	When time.Time
+	Next *schemaItem
+	Tags []string
+	meta map[string]int
+}
+
+// TestSdumpSchema ensures SdumpSchema outlines the types of a value with one
+// sample element per container and honors MaxDepth.
+func TestSdumpSchema(t *testing.T) {
+	item := &schemaItem{Name: "a", Tags: make([]string, 1000),
+		meta: map[string]int{"b": 2, "a": 1}}
+	item.Next = item
+	cs := spew.ConfigState{Indent: " ", SortKeys: true}
+	meta := " meta: map[string]int (len 2) {\n" +
+		"  string: int\n" +
+		" }\n"
+	want := "*spew_test.schemaItem -> spew_test.schemaItem {\n" +
+		" Name: string,\n" +
+		" When: time.Time,\n" +
+		" Next: *spew_test.schemaItem <already shown>,\n" +
+		" Tags: []string (len 1000) [\n" +
+		"  string\n" +
+		" ],\n" +
+		meta +
+		"}\n"
+	if got := cs.SdumpSchema(item); got != want {
+		t.Errorf("SdumpSchema\n got: %q\nwant: %q", got, want)
+	}
+
+	payload := []interface{}{map[string]interface{}{"x": 1.5}, nil}
+	want = "[]interface {} (len 2) [\n" +
+		" map[string]interface {} (len 1) {\n" +
+		"  string: float64\n" +
+		" }\n" +
+		"]\n"
+	if got := cs.SdumpSchema(payload); got != want {
+		t.Errorf("SdumpSchema payload\n got: %q\nwant: %q", got, want)
+	}
+
+	cs.MaxDepth = 1
+	want = "[]interface {} (len 2) [\n" +
+		" map[string]interface {} (len 1) <max depth reached>\n" +
+		"]\n"
+	if got := cs.SdumpSchema(payload); got != want {
+		t.Errorf("SdumpSchema MaxDepth\n got: %q\nwant: %q", got, want)
+	}
+
+	if got, want := cs.SdumpSchema(nil), "interface {} <nil>\n"; got != want {
+		t.Errorf("SdumpSchema nil got: %q want: %q", got, want)
+	}
+}
+
+// TestCycleMarker ensures the CycleMarker and CycleMarkerFunc options replace
+// the markers of circular references while still stopping the traversal.
+func TestCycleMarker(t *testing.T) {
+	type node struct{ Next *node }
+	n := &node{}
+	n.Next = n
+	cs := spew.ConfigState{Indent: " ", CycleMarker: "<-- cycle back to root"}
+	s := cs.Sdump(n)
+	s = regexp.MustCompile(`0x[0-9a-f]+`).ReplaceAllString(s, "ADDR")
+	want := "(*spew_test.node)(ADDR)({\n" +
+		" Next: (*spew_test.node)(ADDR)(<-- cycle back to root)\n" +
+		"})\n"
+	if s != want {
+		t.Errorf("CycleMarker Dump\n got: %q\nwant: %q", s, want)
+	}
+	if got, want := cs.Sprintf("%v", n), "<*>{<*><-- cycle back to root}"; got != want {
+		t.Errorf("CycleMarker %%v\n got: %q\nwant: %q", got, want)
+	}
+
+	cs.CycleMarkerFunc = func(label int) string {
+		if label == 2 {
+			return ""
+		}
+		return fmt.Sprintf("<cycle %d>", label)
+	}
+	got := cs.Sprintf("%v", []*node{n, n})
+	want = "[<*>{<*><cycle 1>} <*>{<*><-- cycle back to root}]"
+	if got != want {
+		t.Errorf("CycleMarkerFunc %%v\n got: %q\nwant: %q", got, want)
+	}
+}
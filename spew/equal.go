@@ -0,0 +1,217 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// visitKey identifies a pair of references which are being compared so
+// circular data structures are only compared once.
+type visitKey struct {
+	a, b uintptr
+	vt   reflect.Type
+}
+
+// equalState contains information about the state of a comparison.
+type equalState struct {
+	ignores [][]pathSegment
+	path    []pathSegment
+	visited map[visitKey]bool
+}
+
+// ignored returns whether the current path matches one of the ignored paths.
+// The wildcard * matches any index or map key.
+func (e *equalState) ignored() bool {
+next:
+	for _, ignore := range e.ignores {
+		if len(ignore) != len(e.path) {
+			continue
+		}
+		for i, seg := range ignore {
+			cur := e.path[i]
+			if seg.index != cur.index ||
+				(seg.name != cur.name && !(seg.index && seg.name == "*")) {
+
+				continue next
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// push adds the passed segment to the current path, returning a function which
+// removes it again.
+func (e *equalState) push(name string, index bool) func() {
+	e.path = append(e.path, pathSegment{name: name, index: index})
+	return func() { e.path = e.path[:len(e.path)-1] }
+}
+
+// keyName returns the text of the path segment which selects the passed map
+// key.
+func keyName(key reflect.Value) string {
+	if key.Kind() == reflect.Interface && !key.IsNil() {
+		key = key.Elem()
+	}
+	if key.Kind() == reflect.String {
+		return key.String()
+	}
+	return fmt.Sprint(key)
+}
+
+// equal returns whether the passed values are deeply equal in the same way as
+// reflect.DeepEqual, except values at ignored paths are always equal.
+func (e *equalState) equal(a, b reflect.Value) bool {
+	if !a.IsValid() || !b.IsValid() {
+		return a.IsValid() == b.IsValid()
+	}
+	if a.Type() != b.Type() {
+		return false
+	}
+	if e.ignored() {
+		return true
+	}
+
+	// Only compare references which have already been seen once to avoid
+	// following circular data structures forever.
+	switch a.Kind() {
+	case reflect.Map, reflect.Slice, reflect.Ptr:
+		if a.Pointer() != 0 && b.Pointer() != 0 {
+			key := visitKey{a.Pointer(), b.Pointer(), a.Type()}
+			if e.visited[key] {
+				return true
+			}
+			e.visited[key] = true
+		}
+	}
+
+	switch a.Kind() {
+	case reflect.Array, reflect.Slice:
+		if a.Kind() == reflect.Slice && a.IsNil() != b.IsNil() {
+			return false
+		}
+		if a.Len() != b.Len() {
+			return false
+		}
+		for i := 0; i < a.Len(); i++ {
+			pop := e.push(strconv.Itoa(i), true)
+			eq := e.equal(a.Index(i), b.Index(i))
+			pop()
+			if !eq {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Map:
+		if a.IsNil() != b.IsNil() || a.Len() != b.Len() {
+			return false
+		}
+		for _, key := range a.MapKeys() {
+			pop := e.push(keyName(key), true)
+			av, bv := a.MapIndex(key), b.MapIndex(key)
+			eq := bv.IsValid() && e.equal(av, bv)
+			pop()
+			if !eq {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Ptr, reflect.Interface:
+		if a.IsNil() || b.IsNil() {
+			return a.IsNil() == b.IsNil()
+		}
+		return e.equal(a.Elem(), b.Elem())
+
+	case reflect.Struct:
+		vt := a.Type()
+		for i := 0; i < a.NumField(); i++ {
+			pop := e.push(vt.Field(i).Name, false)
+			eq := e.equal(a.Field(i), b.Field(i))
+			pop()
+			if !eq {
+				return false
+			}
+		}
+		return true
+
+	case reflect.Func:
+		// Functions are only equal when both are nil.
+		return a.IsNil() && b.IsNil()
+
+	case reflect.Bool:
+		return a.Bool() == b.Bool()
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		return a.Int() == b.Int()
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		return a.Uint() == b.Uint()
+
+	case reflect.Float32, reflect.Float64:
+		return a.Float() == b.Float()
+
+	case reflect.Complex64, reflect.Complex128:
+		return a.Complex() == b.Complex()
+
+	case reflect.String:
+		return a.String() == b.String()
+
+	case reflect.Chan, reflect.UnsafePointer:
+		return a.Pointer() == b.Pointer()
+	}
+	return false
+}
+
+/*
+EqualExcept returns whether the passed values are deeply equal while ignoring
+the values at the passed paths, which are always considered equal.  This is
+useful for golden tests where some fields, such as timestamps and generated
+IDs, are inherently non-deterministic.
+
+The paths use the same syntax as DumpPath, such as "Config.Created" or
+"Items[0].ID", with the addition of the wildcard * which matches any index or
+map key, such as "Items[*].ID".  Other than the ignored paths, values are
+compared the same as reflect.DeepEqual.
+
+EqualExcept panics if any of the paths are malformed.
+*/
+func EqualExcept(a, b interface{}, paths ...string) bool {
+	e := equalState{visited: make(map[visitKey]bool)}
+	for _, path := range paths {
+		segments, err := parsePath(path)
+		if err != nil {
+			panic(err)
+		}
+		for i, seg := range segments {
+			if seg.index && len(seg.name) >= 2 && seg.name[0] == '"' {
+				if name, err := strconv.Unquote(seg.name); err == nil {
+					segments[i].name = name
+				}
+			}
+			segments[i].offset = 0
+		}
+		e.ignores = append(e.ignores, segments)
+	}
+	return e.equal(reflect.ValueOf(a), reflect.ValueOf(b))
+}
@@ -0,0 +1,87 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"reflect"
+	"strconv"
+)
+
+// EventKind identifies the kind of rendering decision described by an Event.
+type EventKind int
+
+const (
+	// EnterEvent is emitted when a value is about to be displayed.
+	EnterEvent EventKind = iota
+
+	// MethodEvent is emitted when a value is displayed using its error or
+	// Stringer interface.
+	MethodEvent
+
+	// TruncateEvent is emitted when the elements of a value are not
+	// displayed due to a limit.  The Detail field of the event names the
+	// option which imposed the limit, such as MaxDepth or MaxMapDepth.
+	TruncateEvent
+
+	// CycleEvent is emitted when a circular reference is detected.
+	CycleEvent
+)
+
+// eventKindStrings is a map of event kinds back to their constant names for
+// pretty printing.
+var eventKindStrings = map[EventKind]string{
+	EnterEvent:    "EnterEvent",
+	MethodEvent:   "MethodEvent",
+	TruncateEvent: "TruncateEvent",
+	CycleEvent:    "CycleEvent",
+}
+
+// String returns the EventKind in human-readable form.
+func (k EventKind) String() string {
+	if s, ok := eventKindStrings[k]; ok {
+		return s
+	}
+	return "EventKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Event describes a single rendering decision made while displaying a value.
+// Events are passed to the EventSink option in the same order the decisions
+// are reflected in the output.
+type Event struct {
+	// Kind is the kind of decision.
+	Kind EventKind
+
+	// Type is the type of the value the decision was made for.  It is nil
+	// when the type is not known, such as for the elements of iterators.
+	Type reflect.Type
+
+	// Depth is the nesting depth of the value where the top-level value is
+	// at depth zero.
+	Depth int
+
+	// Detail provides additional information for some kinds of events.
+	Detail string
+}
+
+// emitEvent passes an event with the passed details to the EventSink option
+// when it is set.
+func emitEvent(cs *ConfigState, kind EventKind, t reflect.Type, depth int, detail string) {
+	if cs.EventSink == nil {
+		return
+	}
+	cs.EventSink(Event{Kind: kind, Type: t, Depth: depth, Detail: detail})
+}
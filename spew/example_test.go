@@ -100,9 +100,6 @@ func ExampleDump() {
 
 	// Output:
 	// (spew_test.Foo) {
-	//  unexportedField: (spew_test.Bar) {
-	//   data: (uintptr) <nil>
-	//  },
 	//  ExportedField: (map[interface {}]interface {}) (len=1) {
 	//   (string) (len=3) "one": (bool) true
 	//  }
@@ -126,11 +123,11 @@ func ExamplePrintf() {
 
 	// Create a circular data type.
 	type circular struct {
-		ui8 uint8
-		c   *circular
+		Ui8 uint8
+		C   *circular
 	}
-	c := circular{ui8: 1}
-	c.c = &c
+	c := circular{Ui8: 1}
+	c.C = &c
 
 	// Print!
 	spew.Printf("ppui8: %v\n", ppui8)
@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"reflect"
+	"strconv"
+)
+
+// fieldsState contains information about the state of a flatten operation.
+type fieldsState struct {
+	cs       *ConfigState
+	fields   map[string]interface{}
+	visiting map[interface{}]bool
+	matches  fieldMatcher
+}
+
+// fieldsKey returns the passed key appended to the passed prefix separated by a
+// dot unless the prefix is empty.
+func fieldsKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+// scalar returns the value to record for the passed scalar value.  The value
+// itself is used when an interface to it can be obtained and otherwise its
+// underlying basic value is used.
+func (s *fieldsState) scalar(v reflect.Value) interface{} {
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			switch v.Kind() {
+			case reflect.Bool:
+				return v.Bool()
+			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+				reflect.Int64:
+				return v.Int()
+			case reflect.Uint, reflect.Uint8, reflect.Uint16,
+				reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+				return v.Uint()
+			case reflect.Float32, reflect.Float64:
+				return v.Float()
+			case reflect.Complex64, reflect.Complex128:
+				return v.Complex()
+			case reflect.String:
+				return v.String()
+			}
+			return s.cs.Sprint(v)
+		}
+		v = unsafeReflectValue(v)
+	}
+	return v.Interface()
+}
+
+// rendered returns the string the passed value is displayed as when it is a
+// time formatted according to the TimeLayout option or provides Stringer or
+// error output according to the DisableMethods option.
+func (s *fieldsState) rendered(v reflect.Value) (string, bool) {
+	var buf bytes.Buffer
+	if handleTime(s.cs, &buf, v) {
+		return buf.String(), true
+	}
+	if !s.cs.DisableMethods && !isExpandedProto(s.cs, v.Type()) &&
+		handleMethods(s.cs, &buf, v, nil) {
+
+		return buf.String(), true
+	}
+	return "", false
+}
+
+// flatten records the passed value under the passed key, or under keys
+// prefixed by the passed key for each of its elements when it is a composite.
+func (s *fieldsState) flatten(key string, v reflect.Value, unfiltered bool) {
+	v = unpackReflectValue(v)
+	if !v.IsValid() {
+		s.fields[key] = nil
+		return
+	}
+	v, omitted, _ := transform(s.cs, v)
+	if omitted {
+		s.fields[key] = string(omittedAngleBytes)
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			s.fields[key] = nil
+			return
+		}
+	}
+	if str, ok := s.rendered(v); ok {
+		s.fields[key] = str
+		return
+	}
+
+	// Pointers, maps, and slices which are already being flattened by an
+	// ancestor are circular references.
+	ref, ok := containerToken(v)
+	if v.Kind() == reflect.Ptr {
+		ref, ok = v.Pointer(), true
+	}
+	if ok {
+		if s.visiting[ref] {
+			s.fields[key] = string(circularBytes)
+			return
+		}
+		s.visiting[ref] = true
+		defer delete(s.visiting, ref)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		s.flatten(key, v.Elem(), unfiltered)
+
+	case reflect.Ptr:
+		s.flatten(key, v.Elem(), unfiltered)
+
+	case reflect.Map:
+		if v.Len() == 0 {
+			s.fields[key] = s.scalar(v)
+			return
+		}
+		keys := v.MapKeys()
+		sortMapKeys(keys, s.cs)
+		for _, k := range keys {
+			s.flatten(fieldsKey(key, s.cs.Sprint(s.scalar(k))), v.MapIndex(k),
+				unfiltered)
+		}
+
+	case reflect.Array, reflect.Slice:
+		if v.Len() == 0 || v.Type().Elem().Kind() == reflect.Uint8 {
+			s.fields[key] = s.scalar(v)
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			s.flatten(fieldsKey(key, strconv.Itoa(i)), v.Index(i), unfiltered)
+		}
+
+	case reflect.Struct:
+		vt := v.Type()
+		fields := visibleFields(s.cs, v, unfiltered, &s.matches)
+		if len(fields) == 0 {
+			s.fields[key] = s.scalar(v)
+			return
+		}
+		for _, i := range fields {
+			vtf := vt.Field(i)
+			s.flatten(fieldsKey(key, fieldName(s.cs, vtf)), v.Field(i),
+				unfiltered || fieldNameMatches(s.cs, vtf))
+		}
+
+	default:
+		s.fields[key] = s.scalar(v)
+	}
+}
+
+// fields is a helper function to consolidate the logic from the various public
+// methods which take varying config states.
+func fields(cs *ConfigState, v interface{}) map[string]interface{} {
+	s := fieldsState{cs: cs, fields: make(map[string]interface{}),
+		visiting: make(map[interface{}]bool)}
+	rv := reflect.ValueOf(v)
+	prefix := ""
+	if rv.IsValid() {
+		t := rv.Type()
+		for t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		if t.Name() != "" {
+			prefix = structName(cs, t)
+		}
+	}
+	s.flatten(prefix, rv, false)
+	return s.fields
+}
+
+// Fields flattens the passed value into a map of dotted keys to scalar values
+// suitable for the fields of structured loggers.  See the package level Fields
+// for details.
+func (c *ConfigState) Fields(v interface{}) map[string]interface{} {
+	return fields(c, v)
+}
+
+/*
+Fields flattens the passed value into a map of dotted keys to scalar values,
+which is suitable for passing to the fields of structured loggers such as logr
+and zap without this package having to import them.  For example:
+
+	logger.WithFields(spew.Fields(foo))
+
+produces fields such as:
+
+	{"Foo.flag": "flagTwo", "Foo.data": 5, "Foo.tags.0": "a"}
+
+Keys are prefixed by the name of the type of the passed value when it has one.
+Struct fields, slice and array indices, and map keys each add a dotted
+component to the key of the value containing them.  Pointers and interfaces are
+followed transparently.  Scalars, byte slices, times formatted according to the
+TimeLayout option, and the output of Stringer and error methods are recorded as
+values, while nil values are recorded as nil and circular references as
+<already shown>.  The ExportedOnly, FieldNameFilter, and Transform options are
+honored so values hidden or omitted from dumps are also excluded from the
+fields.
+*/
+func Fields(v interface{}) map[string]interface{} {
+	return fields(&Config, v)
+}
@@ -0,0 +1,83 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// fingerprintConfig returns a copy of the passed config state which produces
+// deterministic output suitable for fingerprinting.  Map keys are sorted unless
+// another stable order is configured, addresses are replaced by stable fake
+// ones, function values are displayed by name since their code addresses change
+// between builds and runs, and the options which annotate the output with details of the call or
+// send it elsewhere are disabled.
+func fingerprintConfig(cs *ConfigState) *ConfigState {
+	fcs := *cs
+	if fcs.MapKeyOrder == HashKeyOrder {
+		fcs.SortKeys = true
+	}
+	fcs.StableAddresses = true
+	fcs.ResolveFuncNames = true
+	fcs.IncludeCaller = false
+	fcs.PrintLegend = false
+	fcs.HashNodes = false
+	fcs.EventSink = nil
+	fcs.WriterWrapper = nil
+	return &fcs
+}
+
+// fingerprint is a helper function to consolidate the logic from the various
+// public methods which take varying config states.
+func fingerprint(cs *ConfigState, v interface{}) string {
+	h := sha256.New()
+	fdump(fingerprintConfig(cs), h, v)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Fingerprint returns a stable hash of the passed value dumped with the
+// configuration options of c.  See the package level Fingerprint for details.
+func (c *ConfigState) Fingerprint(v interface{}) string {
+	return fingerprint(c, v)
+}
+
+/*
+Fingerprint returns a stable hash of the passed value, which is convenient for
+detecting whether a value, such as a loaded configuration, changed between runs
+without storing and comparing full dumps.
+
+The hash is the SHA-256 digest of the output of Dump, as a string of 64
+lowercase hexadecimal digits, where the value is dumped with sorted map keys,
+with pointer addresses replaced by the stable fake addresses of the
+StableAddresses option, and with function values displayed by name as with the
+ResolveFuncNames option.  The IncludeCaller, PrintLegend, HashNodes, EventSink,
+and WriterWrapper options are ignored.  Consequently, values which are
+structurally equal produce the same fingerprint, even when they live at
+different addresses or their maps were filled in a different order.
+
+Fingerprints are stable across runs and processes for the same value as long
+as the configuration options and the version of this package remain the same.
+They are not guaranteed to be stable across versions which change how values
+are displayed, and since the output includes type names, renaming a type or
+moving it to another package changes the fingerprint as well.  Values whose
+dump isn't deterministic by nature, such as channels with changing lengths,
+don't have stable fingerprints either.
+*/
+func Fingerprint(v interface{}) string {
+	return fingerprint(&Config, v)
+}
@@ -35,8 +35,19 @@ type formatState struct {
 	value          interface{}
 	fs             fmt.State
 	depth          int
+	mapDepth       int
 	pointers       map[uintptr]int
+	fakeAddrs      map[uintptr]uintptr
+	identities     map[interface{}]bool
+	cycles         int
+	methods        methodCache
+	pointerRefs    map[uintptr]int
+	flatLabels     map[uintptr]int
+	unfiltered     bool
+	matches        fieldMatcher
+	derefKey       bool
 	ignoreNextType bool
+	argIndex       int
 	cs             *ConfigState
 }
 
@@ -101,10 +112,84 @@ func (f *formatState) unpackValue(v reflect.Value) reflect.Value {
 	return v
 }
 
+// formatFlatPtr displays the value the passed pointer chain ultimately points
+// to directly according to the FlattenPointers option.  Shared and circular
+// targets are labeled exactly the same as they are by Dump.  It returns false
+// without displaying anything for chains which lead to a nil pointer or nil
+// interface so they are displayed as usual.
+func (f *formatState) formatFlatPtr(v reflect.Value) bool {
+	var chain []uintptr
+	ve := v
+	for ve.Kind() == reflect.Ptr || ve.Kind() == reflect.Interface {
+		if ve.IsNil() {
+			return false
+		}
+		if ve.Kind() == reflect.Ptr {
+			chain = append(chain, ve.Pointer())
+		}
+		ve = ve.Elem()
+	}
+
+	if f.flatLabels == nil {
+		f.flatLabels = make(map[uintptr]int)
+	}
+	for _, addr := range chain {
+		if label, ok := f.flatLabels[addr]; ok {
+			emitEvent(f.cs, CycleEvent, v.Type(), f.depth, "")
+			if label == 0 {
+				checkCycles(f.cs, &f.cycles)
+				printCycleMarker(f.cs, f.fs, f.cycles, circularShortBytes)
+				return true
+			}
+			f.fs.Write(seenLabelBytes)
+			printInt(f.fs, int64(label), 10)
+			f.fs.Write(closeAngleBytes)
+			return true
+		}
+	}
+
+	// Label the target when any pointer in the chain is shared.
+	label := 0
+	for _, addr := range chain {
+		if f.pointerRefs[addr] > 1 {
+			label = len(f.flatLabels) + 1
+			break
+		}
+	}
+	for _, addr := range chain {
+		f.flatLabels[addr] = label
+	}
+	if label != 0 {
+		f.fs.Write(hashBytes)
+		printInt(f.fs, int64(label), 10)
+		f.fs.Write(spaceBytes)
+	}
+	f.ignoreNextType = false
+	f.format(ve)
+	return true
+}
+
 // formatPtr handles formatting of pointers by indirecting them as necessary.
 func (f *formatState) formatPtr(v reflect.Value) {
-	// Display nil if top level pointer is nil.
+	// Display the targets of pointers directly when enabled.
+	if f.cs.FlattenPointers && f.formatFlatPtr(v) {
+		return
+	}
+
+	// Display nil pointers along with their type when enabled.
 	showTypes := f.fs.Flag('#')
+	if v.IsNil() && f.cs.QualifiedNilPointers {
+		f.ignoreNextType = false
+		f.fs.Write(openParenBytes)
+		f.fs.Write([]byte(typeName(f.cs, v.Type())))
+		f.fs.Write(closeParenBytes)
+		f.fs.Write(openParenBytes)
+		f.fs.Write(nilBytes)
+		f.fs.Write(closeParenBytes)
+		return
+	}
+
+	// Display nil if top level pointer is nil.
 	if v.IsNil() && (!showTypes || f.ignoreNextType) {
 		f.fs.Write(nilAngleBytes)
 		return
@@ -138,10 +223,11 @@ func (f *formatState) formatPtr(v reflect.Value) {
 		pointerChain = append(pointerChain, addr)
 		if pd, ok := f.pointers[addr]; ok && pd < f.depth {
 			cycleFound = true
+			emitEvent(f.cs, CycleEvent, ve.Type(), f.depth, "")
 			indirects--
 			break
 		}
-		f.pointers[addr] = f.depth
+		trackPointer(f.cs, f.pointers, addr, f.depth)
 
 		ve = ve.Elem()
 		if ve.Kind() == reflect.Interface {
@@ -154,10 +240,11 @@ func (f *formatState) formatPtr(v reflect.Value) {
 	}
 
 	// Display type or indirection level depending on flags.
-	if showTypes && !f.ignoreNextType {
+	qualifiedNil := nilFound && f.cs.QualifiedNilPointers
+	if (showTypes && !f.ignoreNextType) || qualifiedNil {
 		f.fs.Write(openParenBytes)
 		f.fs.Write(bytes.Repeat(asteriskBytes, indirects))
-		f.fs.Write([]byte(ve.Type().String()))
+		f.fs.Write([]byte(typeName(f.cs, ve.Type())))
 		f.fs.Write(closeParenBytes)
 	} else {
 		if nilFound || cycleFound {
@@ -168,25 +255,32 @@ func (f *formatState) formatPtr(v reflect.Value) {
 		f.fs.Write(closeAngleBytes)
 	}
 
-	// Display pointer information depending on flags.
-	if f.fs.Flag('+') && (len(pointerChain) > 0) {
+	// Display pointer information depending on flags unless map keys are
+	// displayed by their pointees.
+	if f.fs.Flag('+') && (len(pointerChain) > 0) && !f.derefKey {
 		f.fs.Write(openParenBytes)
 		for i, addr := range pointerChain {
 			if i > 0 {
 				f.fs.Write(pointerChainBytes)
 			}
-			printHexPtr(f.fs, addr)
+			printAddr(f.cs, f.fs, f.fakeAddrs, addr)
 		}
 		f.fs.Write(closeParenBytes)
 	}
 
 	// Display dereferenced value.
 	switch {
+	case qualifiedNil:
+		f.fs.Write(openParenBytes)
+		f.fs.Write(nilBytes)
+		f.fs.Write(closeParenBytes)
+
 	case nilFound == true:
 		f.fs.Write(nilAngleBytes)
 
 	case cycleFound == true:
-		f.fs.Write(circularShortBytes)
+		checkCycles(f.cs, &f.cycles)
+		printCycleMarker(f.cs, f.fs, f.cycles, circularShortBytes)
 
 	default:
 		f.ignoreNextType = true
@@ -194,20 +288,85 @@ func (f *formatState) formatPtr(v reflect.Value) {
 	}
 }
 
+// formatIter handles formatting of the sequence of key and value pairs
+// provided by an iterator.
+func (f *formatState) formatIter(seq func(yield func(k, v interface{}) bool)) {
+	f.fs.Write(openBraceBytes)
+	f.depth++
+	if (f.cs.MaxDepth != 0) && (f.depth > f.cs.MaxDepth) {
+		printMore(f.cs, f.fs, -1)
+		emitEvent(f.cs, TruncateEvent, nil, f.depth, "MaxDepth")
+	} else {
+		n := 0
+		seq(func(key, val interface{}) bool {
+			if n > 0 {
+				f.fs.Write(spaceBytes)
+			}
+			if f.cs.MaxElements != 0 && n >= f.cs.MaxElements {
+				printMore(f.cs, f.fs, -1)
+				return false
+			}
+			f.ignoreNextType = true
+			f.format(f.unpackValue(reflect.ValueOf(&key).Elem()))
+			f.fs.Write(mapSeparator(f.cs, colonBytes))
+			f.ignoreNextType = true
+			f.format(f.unpackValue(reflect.ValueOf(&val).Elem()))
+			n++
+			return true
+		})
+	}
+	f.depth--
+	f.fs.Write(closeBraceBytes)
+}
+
+// formatSlice handles formatting of the elements of arrays and slices.  The
+// caller is expected to have rejected slices whose headers are corrupt via
+// isCorruptSlice.  Should an element still turn out to be inaccessible, the
+// remaining elements are replaced by a corrupt slice marker.
+func (f *formatState) formatSlice(v reflect.Value) {
+	numEntries := v.Len()
+	shown := numShown(f.cs, numEntries)
+	for i := 0; i < shown; i++ {
+		if i > 0 {
+			f.fs.Write(spaceBytes)
+		}
+		elem, ok := sliceElem(v, i)
+		if !ok {
+			f.ignoreNextType = false
+			f.fs.Write(corruptSliceBytes)
+			return
+		}
+		f.ignoreNextType = true
+		f.format(f.unpackValue(elem))
+	}
+	if shown < numEntries {
+		f.fs.Write(spaceBytes)
+		printMore(f.cs, f.fs, numEntries-shown)
+	}
+}
+
 // format is the main workhorse for providing the Formatter interface.  It
 // uses the passed reflect value to figure out what kind of object we are
 // dealing with and formats it appropriately.  It is a recursive function,
 // however circular data structures are detected and handled properly.
 func (f *formatState) format(v reflect.Value) {
-	// Handle invalid reflect values immediately.
+	// Handle invalid reflect values immediately.  This includes values of
+	// type reflect.Value which don't hold a value.
+	v = unpackReflectValue(v)
 	kind := v.Kind()
 	if kind == reflect.Invalid {
+		f.ignoreNextType = false
 		f.fs.Write(invalidAngleBytes)
 		return
 	}
 
+	// Give the Transform option a chance to replace the value.
+	v, omitted, replaced := transform(f.cs, v)
+	kind = v.Kind()
+	emitEvent(f.cs, EnterEvent, v.Type(), f.depth, "")
+
 	// Handle pointers specially.
-	if kind == reflect.Ptr {
+	if kind == reflect.Ptr && !omitted {
 		f.formatPtr(v)
 		return
 	}
@@ -215,21 +374,126 @@ func (f *formatState) format(v reflect.Value) {
 	// Print type information unless already handled elsewhere.
 	if !f.ignoreNextType && f.fs.Flag('#') {
 		f.fs.Write(openParenBytes)
-		f.fs.Write([]byte(v.Type().String()))
+		f.fs.Write([]byte(typeName(f.cs, v.Type())))
 		f.fs.Write(closeParenBytes)
 	}
 	f.ignoreNextType = false
 
+	if replaced {
+		printVia(f.cs, f.fs, "Transform")
+	}
+
+	// Display the marker for values omitted by the Transform option.
+	if omitted {
+		f.fs.Write(omittedAngleBytes)
+		return
+	}
+
+	// Detect logical cycles of values which provide their own identity.
+	if token, ok := identityToken(v); ok {
+		if f.identities[token] {
+			emitEvent(f.cs, CycleEvent, v.Type(), f.depth, "SpewIdentity")
+			checkCycles(f.cs, &f.cycles)
+			printCycleMarker(f.cs, f.fs, f.cycles, circularShortBytes)
+			return
+		}
+		if f.identities == nil {
+			f.identities = make(map[interface{}]bool)
+		}
+		f.identities[token] = true
+		defer delete(f.identities, token)
+	}
+
+	// Detect maps and slices which contain themselves.
+	if token, ok := containerToken(v); ok {
+		if f.identities[token] {
+			emitEvent(f.cs, CycleEvent, v.Type(), f.depth, "")
+			checkCycles(f.cs, &f.cycles)
+			printCycleMarker(f.cs, f.fs, f.cycles, circularShortBytes)
+			return
+		}
+		if f.identities == nil {
+			f.identities = make(map[interface{}]bool)
+		}
+		f.identities[token] = true
+		defer delete(f.identities, token)
+	}
+
+	// Display durations in their human readable form when enabled.
+	if handled := handleDuration(f.cs, f.fs, v); handled {
+		return
+	}
+
+	// Display times in the configured layout when enabled.
+	if handled := handleTime(f.cs, f.fs, v); handled {
+		return
+	}
+
+	// Display registered bitmasks as their set flags.
+	if handled := handleBitflags(f.cs, f.fs, v); handled {
+		return
+	}
+
+	// Display geometry and color types compactly when enabled.
+	if handled := handleImage(f.cs, f.fs, v); handled {
+		return
+	}
+
+	// Display network addresses via their String methods when enabled.
+	if handled := handleNetAddr(f.cs, f.fs, v); handled {
+		return
+	}
+
+	// Display the pointees of weak pointers when enabled.
+	if ptr, ok := weakPointee(f.cs, v); ok {
+		printVia(f.cs, f.fs, "ShowRuntimeState")
+		if ptr.IsNil() {
+			f.fs.Write(collectedAngleBytes)
+			return
+		}
+		f.format(ptr)
+		return
+	}
+
+	// Display the driver values of database types when enabled.
+	if val, ok := valuerValue(f.cs, v); ok {
+		printVia(f.cs, f.fs, "Valuer")
+		if f.fs.Flag('#') {
+			f.fs.Write(valuerBytes)
+		}
+		if val == nil {
+			f.fs.Write(nullAngleBytes)
+			return
+		}
+		f.format(reflect.ValueOf(val))
+		return
+	}
+
+	// Display the elements of iterators when enabled.
+	if seq, ok := iterSeq(f.cs, v); ok {
+		printVia(f.cs, f.fs, "SpewIterator")
+		f.formatIter(seq)
+		return
+	}
+
 	// Call Stringer/error interfaces if they exist and the handle methods
 	// flag is enabled.
-	if !f.cs.DisableMethods {
+	if !f.cs.DisableMethods && !isExpandedProto(f.cs, v.Type()) {
 		if (kind != reflect.Invalid) && (kind != reflect.Interface) {
-			if handled := handleMethods(f.cs, f.fs, v); handled {
+			mcs := methodConfig(f.cs, v, f.depth)
+			if handled := handleMethods(mcs, f.fs, v, f.methods); handled {
+				emitEvent(f.cs, MethodEvent, v.Type(), f.depth, "")
 				return
 			}
 		}
 	}
 
+	// Display the value via the handler for its kind when one is set.
+	if handled := handleKind(f.cs, f.fs, v); handled {
+		return
+	}
+
+	printVia(f.cs, f.fs, "reflection")
 	switch kind {
 	case reflect.Invalid:
 		// Do nothing.  We should never get here since invalid has already
@@ -239,50 +503,59 @@ func (f *formatState) format(v reflect.Value) {
 		printBool(f.fs, v.Bool())
 
 	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Int:
-		printInt(f.fs, v.Int(), 10)
+		if f.cs.ShowRunes && v.Type() == runeType {
+			printRune(f.fs, v.Int())
+			break
+		}
+		printIntValue(f.cs, f.fs, v.Int())
 
 	case reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uint:
-		printUint(f.fs, v.Uint(), 10)
+		printUintValue(f.cs, f.fs, v.Uint())
 
 	case reflect.Float32:
-		printFloat(f.fs, v.Float(), 32)
+		printFloatValue(f.cs, f.fs, v.Float(), 32)
 
 	case reflect.Float64:
-		printFloat(f.fs, v.Float(), 64)
+		printFloatValue(f.cs, f.fs, v.Float(), 64)
 
 	case reflect.Complex64:
-		printComplex(f.fs, v.Complex(), 32)
+		printComplex(f.fs, v.Complex(), floatFormat(f.cs), 32)
 
 	case reflect.Complex128:
-		printComplex(f.fs, v.Complex(), 64)
+		printComplex(f.fs, v.Complex(), floatFormat(f.cs), 64)
 
 	case reflect.Slice:
 		if v.IsNil() {
 			f.fs.Write(nilAngleBytes)
 			break
 		}
+		if isCorruptSlice(v) {
+			f.fs.Write(corruptSliceBytes)
+			break
+		}
 		fallthrough
 
 	case reflect.Array:
+		// Display arrays and slices of runes as the text they represent
+		// when enabled.
+		if text, ok := runeSliceText(f.cs, v); ok {
+			printText(f.cs, f.fs, text, false)
+			break
+		}
+
 		f.fs.Write(openBracketBytes)
 		f.depth++
 		if (f.cs.MaxDepth != 0) && (f.depth > f.cs.MaxDepth) {
-			f.fs.Write(maxShortBytes)
+			printMore(f.cs, f.fs, v.Len())
+			emitEvent(f.cs, TruncateEvent, v.Type(), f.depth, "MaxDepth")
 		} else {
-			numEntries := v.Len()
-			for i := 0; i < numEntries; i++ {
-				if i > 0 {
-					f.fs.Write(spaceBytes)
-				}
-				f.ignoreNextType = true
-				f.format(f.unpackValue(v.Index(i)))
-			}
+			f.formatSlice(v)
 		}
 		f.depth--
 		f.fs.Write(closeBracketBytes)
 
 	case reflect.String:
-		f.fs.Write([]byte(v.String()))
+		printText(f.cs, f.fs, v.String(), false)
 
 	case reflect.Interface:
 		// The only time we should get here is for nil interfaces due to
@@ -304,45 +577,75 @@ func (f *formatState) format(v reflect.Value) {
 
 		f.fs.Write(openMapBytes)
 		f.depth++
+		f.mapDepth++
 		if (f.cs.MaxDepth != 0) && (f.depth > f.cs.MaxDepth) {
-			f.fs.Write(maxShortBytes)
+			printMore(f.cs, f.fs, v.Len())
+			emitEvent(f.cs, TruncateEvent, v.Type(), f.depth, "MaxDepth")
+		} else if f.cs.MaxMapDepth != 0 && f.mapDepth > f.cs.MaxMapDepth {
+			printMore(f.cs, f.fs, v.Len())
+			emitEvent(f.cs, TruncateEvent, v.Type(), f.depth, "MaxMapDepth")
 		} else {
+			numEntries := v.Len()
 			keys := v.MapKeys()
-			if f.cs.SortKeys {
-				sortValues(keys, f.cs)
-			}
+			sortMapKeys(keys, f.cs)
+			keys = keys[:numShown(f.cs, numEntries)]
+			kcs := keyConfig(f.cs)
 			for i, key := range keys {
 				if i > 0 {
 					f.fs.Write(spaceBytes)
 				}
 				f.ignoreNextType = true
+				cs, derefKey := f.cs, f.derefKey
+				f.cs = kcs
+				f.derefKey = derefKey || cs.DerefMapKeys
 				f.format(f.unpackValue(key))
-				f.fs.Write(colonBytes)
+				f.cs, f.derefKey = cs, derefKey
+				f.fs.Write(mapSeparator(f.cs, colonBytes))
 				f.ignoreNextType = true
-				f.format(f.unpackValue(v.MapIndex(key)))
+				if isNilMapValue(f.cs, v.MapIndex(key)) {
+					f.fs.Write(nilValueAngleBytes)
+				} else {
+					f.format(f.unpackValue(v.MapIndex(key)))
+				}
+			}
+			if len(keys) < numEntries {
+				f.fs.Write(spaceBytes)
+				printMore(f.cs, f.fs, numEntries-len(keys))
 			}
 		}
+		f.mapDepth--
 		f.depth--
 		f.fs.Write(closeMapBytes)
 
 	case reflect.Struct:
-		numFields := v.NumField()
+		keyValue := f.cs.StructStyle == KeyValueStructStyle
+		if keyValue {
+			f.fs.Write([]byte(structName(f.cs, v.Type())))
+		}
 		f.fs.Write(openBraceBytes)
 		f.depth++
 		if (f.cs.MaxDepth != 0) && (f.depth > f.cs.MaxDepth) {
-			f.fs.Write(maxShortBytes)
+			printMore(f.cs, f.fs, v.NumField())
+			emitEvent(f.cs, TruncateEvent, v.Type(), f.depth, "MaxDepth")
 		} else {
 			vt := v.Type()
-			for i := 0; i < numFields; i++ {
+			fields := visibleFields(f.cs, v, f.unfiltered, &f.matches)
+			for i, fieldIndex := range fields {
 				if i > 0 {
 					f.fs.Write(spaceBytes)
 				}
-				vtf := vt.Field(i)
-				if f.fs.Flag('+') || f.fs.Flag('#') {
-					f.fs.Write([]byte(vtf.Name))
+				vtf := vt.Field(fieldIndex)
+				if keyValue {
+					f.fs.Write([]byte(fieldName(f.cs, vtf)))
+					f.fs.Write(equalsBytes)
+				} else if f.fs.Flag('+') || f.fs.Flag('#') {
+					f.fs.Write([]byte(fieldName(f.cs, vtf)))
 					f.fs.Write(colonBytes)
 				}
-				f.format(f.unpackValue(v.Field(i)))
+				unfiltered := f.unfiltered
+				f.unfiltered = unfiltered || fieldNameMatches(f.cs, vtf)
+				f.format(f.unpackValue(v.Field(fieldIndex)))
+				f.unfiltered = unfiltered
 			}
 		}
 		f.depth--
@@ -351,8 +654,11 @@ func (f *formatState) format(v reflect.Value) {
 	case reflect.Uintptr:
 		printHexPtr(f.fs, uintptr(v.Uint()))
 
-	case reflect.UnsafePointer, reflect.Chan, reflect.Func:
-		printHexPtr(f.fs, v.Pointer())
+	case reflect.UnsafePointer, reflect.Chan:
+		printAddr(f.cs, f.fs, f.fakeAddrs, v.Pointer())
+
+	case reflect.Func:
+		printFunc(f.cs, f.fs, v)
 
 	// There were not any other types at the time this code was written, but
 	// fall back to letting the default fmt package handle it if any get added.
@@ -369,8 +675,17 @@ func (f *formatState) format(v reflect.Value) {
 // Format satisfies the fmt.Formatter interface. See NewFormatter for usage
 // details.
 func (f *formatState) Format(fs fmt.State, verb rune) {
+	if f.cs.ASCIIOnly {
+		fs = asciiState{fs}
+	}
 	f.fs = fs
 
+	// Label the argument on a line of its own when it is one of the
+	// arguments of the Printf family and the LabelArgs option is enabled.
+	if f.cs.LabelArgs && f.argIndex >= 0 {
+		printArgLabel(fs, f.argIndex)
+	}
+
 	// Use standard formatting for verbs that are not v.
 	if verb != 'v' {
 		format := f.constructOrigFormat(verb)
@@ -378,6 +693,26 @@ func (f *formatState) Format(fs fmt.State, verb rune) {
 		return
 	}
 
+	// The - flag forces map keys to be sorted for this call.
+	if fs.Flag('-') && !f.cs.SortKeys {
+		cs := *f.cs
+		cs.SortKeys = true
+		origCS := f.cs
+		f.cs = &cs
+		defer func() { f.cs = origCS }()
+	}
+
+	// The space flag displays the value exactly the same as Dump, minus the
+	// trailing newline, for multi-line output within a Printf call.
+	if fs.Flag(' ') {
+		cs := *f.cs
+		cs.NoTrailingNewline = true
+		cs.IncludeCaller = false
+		cs.LabelArgs = false
+		fdump(&cs, fs, f.value)
+		return
+	}
+
 	if f.value == nil {
 		if fs.Flag('#') {
 			fs.Write(interfaceBytes)
@@ -386,14 +721,23 @@ func (f *formatState) Format(fs fmt.State, verb rune) {
 		return
 	}
 
+	f.cycles = 0
+	f.methods = newMethodCache(f.cs)
+	if f.cs.FlattenPointers {
+		f.pointerRefs = make(map[uintptr]int)
+		f.flatLabels = nil
+		countPointerRefs(f.cs, reflect.ValueOf(f.value), f.pointerRefs)
+	}
+	defer recoverAbort(fs)
 	f.format(reflect.ValueOf(f.value))
 }
 
 // newFormatter is a helper function to consolidate the logic from the various
 // public methods which take varying config states.
 func newFormatter(cs *ConfigState, v interface{}) fmt.Formatter {
-	fs := &formatState{value: v, cs: cs}
+	fs := &formatState{value: v, cs: cs, argIndex: -1}
 	fs.pointers = make(map[uintptr]int)
+	fs.fakeAddrs = make(map[uintptr]uintptr)
 	return fs
 }
 
@@ -410,6 +754,13 @@ standard fmt package for formatting.  In addition, the custom formatter ignores
 the width and precision arguments (however they will still work on the format
 specifiers not handled by the custom formatter).
 
+The - flag may be combined with any of these, such as %-v or %-#v, to force map
+keys to be sorted for that call as if the SortKeys option were set.
+
+The space flag, as in % v, instead produces the full multi-line indented output
+of Dump, without the trailing newline, so it can be used inline in a Printf
+call.  It may be combined with the - flag, as in %- v, to sort map keys.
+
 Typically this function shouldn't be called directly.  It is much easier to make
 use of the custom formatter by calling one of the convenience functions such as
 Printf, Println, or Fprintf.
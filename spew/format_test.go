@@ -69,6 +69,7 @@ package spew_test
 import (
 	"bytes"
 	"fmt"
+	"strings"
 	"testing"
 	"unsafe"
 
@@ -1484,6 +1485,13 @@ func TestFormatter(t *testing.T) {
 	addErrorFormatterTests()
 	addPassthroughFormatterTests()
 
+	// The tests display unexported fields, which the global config instance
+	// hides by default.
+	defer func(exportedOnly bool) {
+		spew.Config.ExportedOnly = exportedOnly
+	}(spew.Config.ExportedOnly)
+	spew.Config.ExportedOnly = false
+
 	t.Logf("Running %d tests", len(formatterTests))
 	for i, test := range formatterTests {
 		buf := new(bytes.Buffer)
@@ -1556,3 +1564,76 @@ func TestPrintSortedKeys(t *testing.T) {
 		t.Errorf("Sorted keys mismatch 6:\n  %v %v", s, expected)
 	}
 }
+
+func TestPrintMaxCycleRevisits(t *testing.T) {
+	type circular struct {
+		c *circular
+	}
+	v := circular{nil}
+	v.c = &v
+	in := []circular{v, v, v}
+
+	cfg := spew.ConfigState{MaxCycleRevisits: 2}
+	s := cfg.Sprint(in)
+	expected := "[{<*>{<*><shown>}} {<*>{<*><shown>}} {<*>{<*>... (too many cycles)"
+	if s != expected {
+		t.Errorf("Max cycle revisits mismatch:\n  %v %v", s, expected)
+	}
+
+	// Ensure the count is reset for each use of the formatter.
+	f := cfg.NewFormatter([]circular{v, v})
+	for i := 0; i < 2; i++ {
+		s = fmt.Sprint(f)
+		expected = "[{<*>{<*><shown>}} {<*>{<*><shown>}}]"
+		if s != expected {
+			t.Errorf("Max cycle revisits mismatch #%d:\n  %v %v", i, s,
+				expected)
+		}
+	}
+}
+
+func TestPrintSortedKeysFlag(t *testing.T) {
+	cfg := spew.ConfigState{}
+	s := cfg.Sprintf("%-v", map[int]string{1: "1", 3: "3", 2: "2"})
+	expected := "map[1:1 2:2 3:3]"
+	if s != expected {
+		t.Errorf("Sorted keys flag mismatch 1:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprintf("%-#v", map[string]int{"b": 2, "a": 1})
+	expected = "(map[string]int)map[a:1 b:2]"
+	if s != expected {
+		t.Errorf("Sorted keys flag mismatch 2:\n  %v %v", s, expected)
+	}
+}
+
+// TestPrintDumpFlag ensures the space flag produces the same output as Dump
+// without the trailing newline.
+func TestPrintDumpFlag(t *testing.T) {
+	type s struct {
+		A map[string]int
+	}
+	v := s{map[string]int{"b": 2, "a": 1}}
+	cfg := spew.ConfigState{Indent: " "}
+	got := cfg.Sprintf("state:\n%- v\n", v)
+	expected := "state:\n" +
+		"(spew_test.s) {\n" +
+		" A: (map[string]int) (len=2) {\n" +
+		"  (string) (len=1) \"a\": (int) 1,\n" +
+		"  (string) (len=1) \"b\": (int) 2\n" +
+		" }\n" +
+		"}\n"
+	if got != expected {
+		t.Errorf("Dump flag mismatch:\n  %v %v", got, expected)
+	}
+
+	sorted := spew.ConfigState{Indent: " ", SortKeys: true}
+	if got, want := cfg.Sprintf("%- v", v), strings.TrimSuffix(sorted.Sdump(v),
+		"\n"); got != want {
+
+		t.Errorf("Dump flag mismatch:\n  %v %v", got, want)
+	}
+	if got := cfg.Sprintf("% v", nil); got != "(interface {}) <nil>" {
+		t.Errorf("Dump flag mismatch:\n  %v", got)
+	}
+}
@@ -0,0 +1,63 @@
+// Copyright (c) 2015 Dave Collins <dave@davec.name>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// NOTE: Due to the following build constraints, this file will only be compiled
+// when the code is built with Go 1.23 or newer which introduced range over
+// function iterators.
+// +build go1.23
+
+package spew
+
+import (
+	"iter"
+	"reflect"
+)
+
+// SpewIterator is an optional interface types which represent a lazy sequence
+// can implement in order to have the elements of the sequence displayed when
+// the ExpandIterators option is enabled.
+type SpewIterator interface {
+	SpewIter() iter.Seq2[any, any]
+}
+
+// iterSeq returns the sequence provided by the passed value when iterator
+// expansion is enabled and the value implements the SpewIterator interface,
+// either directly or via a pointer receiver.
+func iterSeq(cs *ConfigState, v reflect.Value) (func(yield func(k, v interface{}) bool), bool) {
+	if !cs.ExpandIterators {
+		return nil, false
+	}
+
+	// We need an interface to check if the type implements the SpewIterator
+	// interface.  Use unsafe, when it's available, to bypass the visibility
+	// restrictions on things like unexported struct fields.
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return nil, false
+		}
+		v = unsafeReflectValue(v)
+	}
+	if !UnsafeDisabled && !v.CanAddr() {
+		v = unsafeReflectValue(v)
+	}
+	if it, ok := v.Interface().(SpewIterator); ok {
+		return it.SpewIter(), true
+	}
+	if v.CanAddr() {
+		if it, ok := v.Addr().Interface().(SpewIterator); ok {
+			return it.SpewIter(), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,105 @@
+// Copyright (c) 2015 Dave Collins <dave@davec.name>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// NOTE: Due to the following build constraints, this file will only be compiled
+// when the code is built with Go 1.23 or newer which introduced range over
+// function iterators.
+// +build go1.23
+
+package spew_test
+
+import (
+	"iter"
+	"testing"
+
+	"github.com/dvln/go-spew/spew"
+)
+
+// naturals is a lazy sequence of the natural numbers up to an optional limit
+// which implements the SpewIterator interface.
+type naturals struct {
+	limit int
+}
+
+func (n naturals) SpewIter() iter.Seq2[any, any] {
+	return func(yield func(any, any) bool) {
+		for i := 1; n.limit == 0 || i <= n.limit; i++ {
+			if !yield(i-1, i) {
+				return
+			}
+		}
+	}
+}
+
+// pnaturals is the same as naturals except it implements the SpewIterator
+// interface via a pointer receiver.
+type pnaturals struct {
+	limit int
+}
+
+func (n *pnaturals) SpewIter() iter.Seq2[any, any] {
+	return naturals(*n).SpewIter()
+}
+
+// TestDumpIterators ensures the elements of iterators are dumped when the
+// ExpandIterators option is enabled.
+func TestDumpIterators(t *testing.T) {
+	cfg := spew.ConfigState{Indent: " ", ExpandIterators: true}
+	s := cfg.Sdump(naturals{2})
+	expected := "(spew_test.naturals) {\n" +
+		" (int) 0: (int) 1,\n" +
+		" (int) 1: (int) 2\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Iterator mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sdump(naturals{-1})
+	expected = "(spew_test.naturals) {\n}\n"
+	if s != expected {
+		t.Errorf("Iterator mismatch:\n  %v %v", s, expected)
+	}
+
+	// Infinite iterators are bounded by the MaxElements option.
+	cfg.MaxElements = 2
+	s = cfg.Sdump(naturals{})
+	expected = "(spew_test.naturals) {\n" +
+		" (int) 0: (int) 1,\n" +
+		" (int) 1: (int) 2,\n" +
+		" ...\n" +
+		"}\n"
+	if s != expected {
+		t.Errorf("Iterator mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprint(naturals{})
+	expected = "{0:1 1:2 ...}"
+	if s != expected {
+		t.Errorf("Iterator mismatch:\n  %v %v", s, expected)
+	}
+
+	s = cfg.Sprint([]pnaturals{{1}})
+	expected = "[{0:1}]"
+	if s != expected {
+		t.Errorf("Iterator mismatch:\n  %v %v", s, expected)
+	}
+
+	// Iterators are displayed normally when expansion is disabled.
+	cfg.ExpandIterators = false
+	s = cfg.Sprint(naturals{2})
+	expected = "{2}"
+	if s != expected {
+		t.Errorf("Iterator mismatch:\n  %v %v", s, expected)
+	}
+}
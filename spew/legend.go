@@ -0,0 +1,106 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"io"
+)
+
+// legendEntry describes one of the special markers which can appear in the
+// output of Dump for the legend displayed by the PrintLegend option.
+type legendEntry struct {
+	marker []byte
+	text   string
+	desc   string
+}
+
+// legendEntries houses the markers described by the legend in the order they
+// are listed.  The text of the entries without one is the marker itself.
+var legendEntries = []legendEntry{
+	{nilAngleBytes, "", "nil pointer, interface, slice, map, channel, or function"},
+	{nilValueAngleBytes, "", "map entry which is present but holds nil"},
+	{circularBytes, "", "circular reference to a value which is already being displayed"},
+	{seenLabelBytes, "<seen #N>", "reference to the value labeled #N displayed earlier"},
+	{moreBytes, "", "number of elements, characters, or bytes which were not displayed"},
+	{omittedAngleBytes, "", "value omitted by the Transform option"},
+	{invalidAngleBytes, "", "invalid value, such as a zero reflect.Value"},
+	{collectedAngleBytes, "", "weak pointer whose value has been garbage collected"},
+	{nullAngleBytes, "", "database value which is NULL"},
+	{corruptSliceBytes, "", "slice whose length and capacity are inconsistent"},
+	{tooManyCyclesBytes, "", "dump stopped by the MaxCycleRevisits option"},
+}
+
+// legendTracker records which of the markers described by the legend have been
+// written.  Markers are always written on their own, so comparing each write
+// against them is sufficient.
+type legendTracker struct {
+	used []bool
+}
+
+// note records the marker the passed bytes are, if any.
+func (lt *legendTracker) note(p []byte) {
+	for i := range legendEntries {
+		if bytes.Equal(p, legendEntries[i].marker) {
+			lt.used[i] = true
+			return
+		}
+	}
+}
+
+// legendText returns the text the passed entry is listed with.  The markers for
+// elements which were not displayed are listed with the configured ellipsis.
+func legendText(cs *ConfigState, entry *legendEntry) string {
+	switch {
+	case entry.text != "":
+		return entry.text
+	case bytes.Equal(entry.marker, moreBytes):
+		return string(ellipsis(cs)) + " (N more)"
+	}
+	return string(entry.marker)
+}
+
+// printLegend writes the legend of the markers recorded by the passed tracker
+// to w.  Nothing is written when no markers were used.  The passed flag
+// specifies whether the output written so far ends with a newline.
+func printLegend(cs *ConfigState, w io.Writer, lt *legendTracker, terminated bool) {
+	var buf bytes.Buffer
+	for i := range legendEntries {
+		if !lt.used[i] {
+			continue
+		}
+		entry := &legendEntries[i]
+		buf.WriteString(cs.Indent)
+		buf.WriteString(legendText(cs, entry))
+		buf.Write(colonSpaceBytes)
+		buf.WriteString(entry.desc)
+		buf.Write(newlineBytes)
+	}
+	if buf.Len() == 0 {
+		return
+	}
+
+	if !terminated {
+		w.Write(newlineBytes)
+	}
+	w.Write(legendBytes)
+	out := buf.Bytes()
+	if !terminated {
+		out = out[:len(out)-1]
+	}
+	w.Write(out)
+}
@@ -0,0 +1,111 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+// markdownFence returns the fence used to wrap the passed output in a fenced
+// code block.  Fences must be longer than any run of backticks within the
+// block, so the fence is one backtick longer than the longest run in the
+// output, or the usual three backticks when that is longer.
+func markdownFence(out []byte) string {
+	longest, run := 0, 0
+	for _, b := range out {
+		if b != '`' {
+			run = 0
+			continue
+		}
+		run++
+		if run > longest {
+			longest = run
+		}
+	}
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// fdumpMarkdown is a helper function to consolidate the logic from the various
+// public methods which take varying writers and config states.
+func fdumpMarkdown(cs *ConfigState, w io.Writer, a ...interface{}) {
+	// The writer wrapper and flushing apply to the whole fenced block, so
+	// they are only applied once it has been assembled.
+	var buf bytes.Buffer
+	mcs := *cs
+	mcs.NoTrailingNewline = false
+	mcs.WriterWrapper = nil
+	mcs.AutoFlush = false
+	fdump(&mcs, &buf, a...)
+	fence := markdownFence(buf.Bytes())
+	block := make([]byte, 0, buf.Len()+2*len(fence)+4)
+	block = append(block, fence+"go\n"...)
+	block = append(block, buf.Bytes()...)
+	block = append(block, fence+"\n"...)
+
+	out := wrapWriter(cs, w)
+	_, err := writeFull(out, block)
+	autoFlush(cs, err, out, w)
+}
+
+// FdumpMarkdown formats and displays the passed arguments to io.Writer w
+// exactly the same as Dump wrapped in a Markdown fenced code block.  See
+// SdumpMarkdown for details.
+func (c *ConfigState) FdumpMarkdown(w io.Writer, a ...interface{}) {
+	fdumpMarkdown(c, w, a...)
+}
+
+// SdumpMarkdown returns a string with the passed arguments formatted exactly
+// the same as Dump wrapped in a Markdown fenced code block.  See the package
+// level SdumpMarkdown for details.
+func (c *ConfigState) SdumpMarkdown(a ...interface{}) string {
+	var buf bytes.Buffer
+	fdumpMarkdown(c, &buf, a...)
+	return buf.String()
+}
+
+// FdumpMarkdown formats and displays the passed arguments to io.Writer w
+// exactly the same as Dump wrapped in a Markdown fenced code block.  See
+// SdumpMarkdown for details.
+func FdumpMarkdown(w io.Writer, a ...interface{}) {
+	fdumpMarkdown(&Config, w, a...)
+}
+
+/*
+SdumpMarkdown returns a string with the passed arguments formatted exactly the
+same as Dump wrapped in a Markdown fenced code block with a go language hint,
+which is convenient for pasting into documentation and issues:
+
+	```go
+	(main.Foo) {
+	 Name: (string) (len=3) "bar"
+	}
+	```
+
+Backticks within string values can't break out of the block since the fence
+is always made longer than the longest run of backticks in the output as
+required by Markdown.
+*/
+func SdumpMarkdown(a ...interface{}) string {
+	var buf bytes.Buffer
+	fdumpMarkdown(&Config, &buf, a...)
+	return buf.String()
+}
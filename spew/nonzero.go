@@ -0,0 +1,122 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+// nonZeroFields returns the passed field indices of the passed struct value
+// with the fields which hold the zero value of their type removed.  The number
+// of removed fields is added to the count of omitted zero fields.
+func (d *dumpState) nonZeroFields(v reflect.Value, fields []int) []int {
+	nonZero := fields[:0]
+	for _, i := range fields {
+		if v.Field(i).IsZero() {
+			*d.zeroFields++
+			continue
+		}
+		nonZero = append(nonZero, i)
+	}
+	return nonZero
+}
+
+// fdumpNonZero is a helper function to consolidate the logic from the various
+// public methods which take varying writers and config states.
+func fdumpNonZero(cs *ConfigState, w io.Writer, v interface{}) {
+	// Structs must be displayed in the block style in order to omit fields.
+	ncs := *cs
+	ncs.StructStyle = BlockStructStyle
+
+	out := wrapWriter(cs, w)
+	dw := newDumpWriter(&ncs, out)
+	slices := make([]sliceBacking, 0)
+	zeroFields := 0
+	d := dumpState{w: dw, cs: &ncs, slices: &slices, zeroFields: &zeroFields,
+		fakeAddrs: make(map[uintptr]uintptr), methods: newMethodCache(&ncs)}
+	d.pointers = make(map[uintptr]int)
+	d.dumpTop(reflect.ValueOf(v))
+	dw.Write(newlineBytes)
+	fmt.Fprintf(dw, "(%d zero fields omitted)", zeroFields)
+	if !cs.NoTrailingNewline {
+		dw.Write(newlineBytes)
+	}
+	dw.finish()
+	autoFlush(cs, dw.err, out, w)
+}
+
+// FdumpNonZero displays the passed value to io.Writer w with all struct fields
+// which hold the zero value of their type omitted.  See DumpNonZero for
+// details.
+func (c *ConfigState) FdumpNonZero(w io.Writer, v interface{}) {
+	fdumpNonZero(c, w, v)
+}
+
+// SdumpNonZero returns a string with the passed value formatted with all struct
+// fields which hold the zero value of their type omitted.  See DumpNonZero for
+// details.
+func (c *ConfigState) SdumpNonZero(v interface{}) string {
+	var buf bytes.Buffer
+	fdumpNonZero(c, &buf, v)
+	return buf.String()
+}
+
+// DumpNonZero displays the passed value to standard out with all struct fields
+// which hold the zero value of their type omitted.  See the package level
+// DumpNonZero for details.
+func (c *ConfigState) DumpNonZero(v interface{}) {
+	fdumpNonZero(c, os.Stdout, v)
+}
+
+// FdumpNonZero displays the passed value to io.Writer w with all struct fields
+// which hold the zero value of their type omitted.  See DumpNonZero for
+// details.
+func FdumpNonZero(w io.Writer, v interface{}) {
+	fdumpNonZero(&Config, w, v)
+}
+
+// SdumpNonZero returns a string with the passed value formatted with all struct
+// fields which hold the zero value of their type omitted.  See DumpNonZero for
+// details.
+func SdumpNonZero(v interface{}) string {
+	var buf bytes.Buffer
+	fdumpNonZero(&Config, &buf, v)
+	return buf.String()
+}
+
+/*
+DumpNonZero displays the passed value to standard out with all struct fields
+which hold the zero value of their type omitted, which is the quickest way to
+see what is actually set in a large configuration struct.  The rest of the
+value is formatted exactly the same as Dump.
+
+Struct fields are omitted recursively, so nested structs only show their fields
+which are set while nested structs which are entirely zero are omitted
+altogether.  The number of omitted fields is displayed after the value, such as:
+
+	(struct { Name string; Port int; TLS *tls.Config }) {
+	 Name: (string) (len=3) "api"
+	}
+	(2 zero fields omitted)
+*/
+func DumpNonZero(v interface{}) {
+	fdumpNonZero(&Config, os.Stdout, v)
+}
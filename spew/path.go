@@ -0,0 +1,297 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// pathDeref follows any pointers and interfaces of the passed value until
+// reaching a concrete value.  The traversed portion of the path is used to
+// report nil values.
+func pathDeref(v reflect.Value, path, traversed string) (reflect.Value, error) {
+	if !v.IsValid() {
+		return v, fmt.Errorf("spew: path %q: nil value at %q", path,
+			traversed)
+	}
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return v, fmt.Errorf("spew: path %q: nil value at %q", path,
+				traversed)
+		}
+		v = v.Elem()
+	}
+	return v, nil
+}
+
+// pathMapKey converts the passed path segment to a key of the passed map key
+// type.  Quoted segments are unquoted first.  Keys of interface types are
+// treated as integers when they parse as one and strings otherwise.
+func pathMapKey(kt reflect.Type, seg string) (reflect.Value, error) {
+	quoted := false
+	if len(seg) >= 2 && seg[0] == '"' {
+		s, err := strconv.Unquote(seg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		seg, quoted = s, true
+	}
+
+	switch kt.Kind() {
+	case reflect.String:
+		return reflect.ValueOf(seg).Convert(kt), nil
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		i, err := strconv.ParseInt(seg, 0, kt.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(i).Convert(kt), nil
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64, reflect.Uintptr:
+		u, err := strconv.ParseUint(seg, 0, kt.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(u).Convert(kt), nil
+
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(seg, kt.Bits())
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(f).Convert(kt), nil
+
+	case reflect.Bool:
+		b, err := strconv.ParseBool(seg)
+		if err != nil {
+			return reflect.Value{}, err
+		}
+		return reflect.ValueOf(b).Convert(kt), nil
+
+	case reflect.Interface:
+		if i, err := strconv.Atoi(seg); err == nil && !quoted {
+			return reflect.ValueOf(i), nil
+		}
+		return reflect.ValueOf(seg), nil
+	}
+	return reflect.Value{}, fmt.Errorf("unsupported key type %v", kt)
+}
+
+// pathSegment is a single element of a path, which is either a struct field
+// name or the text between the brackets of an index or map key.  The offset is
+// where the segment starts in the path and is used to report errors.
+type pathSegment struct {
+	name   string
+	index  bool
+	offset int
+}
+
+// parsePath splits the passed path into its segments.  Paths consist of dotted
+// field names and bracketed slice, array, and string indices or map keys, such
+// as "Config.Servers[0].Limits[\"conn\"]".
+func parsePath(path string) ([]pathSegment, error) {
+	var segments []pathSegment
+	rest := path
+	for rest != "" {
+		offset := len(path) - len(rest)
+		if rest[0] == '[' {
+			end := strings.IndexByte(rest, ']')
+			if strings.HasPrefix(rest, "[\"") {
+				// Allow closing brackets inside quoted keys.
+				if i := strings.Index(rest[2:], "\"]"); i >= 0 {
+					end = i + 3
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("spew: path %q: unterminated "+
+					"bracket at %q", path, path[:offset])
+			}
+			segments = append(segments, pathSegment{rest[1:end], true,
+				offset})
+			rest = rest[end+1:]
+			continue
+		}
+
+		if rest[0] == '.' {
+			rest = rest[1:]
+		}
+		end := strings.IndexAny(rest, ".[")
+		if end < 0 {
+			end = len(rest)
+		}
+		if end == 0 {
+			return nil, fmt.Errorf("spew: path %q: empty field name at %q",
+				path, path[:offset])
+		}
+		segments = append(segments, pathSegment{rest[:end], false, offset})
+		rest = rest[end:]
+	}
+	return segments, nil
+}
+
+// resolvePath navigates the passed value according to the passed path and
+// returns the value at that location.  Pointers and interfaces along the way
+// are followed automatically.
+func resolvePath(v reflect.Value, path string) (reflect.Value, error) {
+	segments, err := parsePath(path)
+	if err != nil {
+		return v, err
+	}
+	for _, seg := range segments {
+		traversed := path[:seg.offset]
+		if v, err = pathDeref(v, path, traversed); err != nil {
+			return v, err
+		}
+
+		if seg.index {
+			if v, err = pathIndex(v, seg.name); err != nil {
+				return v, fmt.Errorf("spew: path %q: %v at %q", path,
+					err, traversed)
+			}
+			continue
+		}
+
+		if v.Kind() != reflect.Struct {
+			return v, fmt.Errorf("spew: path %q: cannot select field %q "+
+				"of %v at %q", path, seg.name, v.Type(), traversed)
+		}
+		f := v.FieldByName(seg.name)
+		if !f.IsValid() {
+			return v, fmt.Errorf("spew: path %q: %v has no field %q at %q",
+				path, v.Type(), seg.name, traversed)
+		}
+		v = f
+	}
+
+	// Unpack non-nil interfaces so the dump shows the concrete type.
+	if v.Kind() == reflect.Interface && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v, nil
+}
+
+// pathIndex returns the element of the passed value selected by the passed
+// bracketed path segment.
+func pathIndex(v reflect.Value, seg string) (reflect.Value, error) {
+	switch v.Kind() {
+	case reflect.Array, reflect.Slice, reflect.String:
+		i, err := strconv.Atoi(seg)
+		if err != nil {
+			return v, fmt.Errorf("invalid index %q", seg)
+		}
+		if i < 0 || i >= v.Len() {
+			return v, fmt.Errorf("index %d out of range for %v of "+
+				"length %d", i, v.Type(), v.Len())
+		}
+		return v.Index(i), nil
+
+	case reflect.Map:
+		key, err := pathMapKey(v.Type().Key(), seg)
+		if err != nil {
+			return v, fmt.Errorf("invalid key %q for %v: %v", seg,
+				v.Type(), err)
+		}
+		e := v.MapIndex(key)
+		if !e.IsValid() {
+			return v, fmt.Errorf("key %q not found in %v", seg, v.Type())
+		}
+		return e, nil
+	}
+	return v, fmt.Errorf("cannot index %v", v.Type())
+}
+
+// fdumpPath is a helper function to consolidate the logic from the various
+// public methods which take varying writers and config states.
+func fdumpPath(cs *ConfigState, w io.Writer, v interface{}, path string) error {
+	if path == "" {
+		_, err := fdump(cs, w, v)
+		return err
+	}
+	rv, err := resolvePath(reflect.ValueOf(v), path)
+	if err != nil {
+		return err
+	}
+
+	_, err = fdumpValues(cs, w, []reflect.Value{rv})
+	return err
+}
+
+// FdumpPath displays only the portion of the passed value selected by path to
+// io.Writer w.  The selected value is formatted exactly the same as Dump.  See
+// DumpPath for the path syntax.
+func (c *ConfigState) FdumpPath(w io.Writer, v interface{}, path string) error {
+	return fdumpPath(c, w, v, path)
+}
+
+// SdumpPath returns a string with only the portion of the passed value selected
+// by path formatted exactly the same as Dump.  See DumpPath for the path syntax.
+func (c *ConfigState) SdumpPath(v interface{}, path string) (string, error) {
+	var buf bytes.Buffer
+	err := fdumpPath(c, &buf, v, path)
+	return buf.String(), err
+}
+
+// DumpPath displays only the portion of the passed value selected by path to
+// standard out.  See ConfigState.Dump and the package level DumpPath for
+// details.
+func (c *ConfigState) DumpPath(v interface{}, path string) error {
+	return fdumpPath(c, os.Stdout, v, path)
+}
+
+// FdumpPath displays only the portion of the passed value selected by path to
+// io.Writer w.  The selected value is formatted exactly the same as Dump.  See
+// DumpPath for the path syntax.
+func FdumpPath(w io.Writer, v interface{}, path string) error {
+	return fdumpPath(&Config, w, v, path)
+}
+
+// SdumpPath returns a string with only the portion of the passed value selected
+// by path formatted exactly the same as Dump.  See DumpPath for the path syntax.
+func SdumpPath(v interface{}, path string) (string, error) {
+	var buf bytes.Buffer
+	err := fdumpPath(&Config, &buf, v, path)
+	return buf.String(), err
+}
+
+/*
+DumpPath displays only the portion of the passed value selected by path to
+standard out.  The selected value is formatted exactly the same as Dump.
+
+The path consists of dotted struct field names and bracketed indices into
+arrays, slices, and strings or keys into maps.  Pointers and interfaces along
+the way are followed automatically.  For example:
+
+	spew.DumpPath(root, "Config.Database.Pool")
+	spew.DumpPath(root, "Servers[0].Limits[\"conn\"]")
+
+Map keys may optionally be quoted and are converted to the key type of the map.
+An empty path dumps the entire value.  An error describing the failing portion
+of the path is returned when the path does not resolve, for example due to a
+missing field, an out of range index, a missing map key, or a nil pointer.
+*/
+func DumpPath(v interface{}, path string) error {
+	return fdumpPath(&Config, os.Stdout, v, path)
+}
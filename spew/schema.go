@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+)
+
+var (
+	// errorType and stringerType are the types of the interfaces whose
+	// implementations are displayed as leaves of a schema.
+	errorType    = reflect.TypeOf((*error)(nil)).Elem()
+	stringerType = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+)
+
+// schemaState contains information about the state of a schema operation.
+type schemaState struct {
+	cs       *ConfigState
+	w        io.Writer
+	depth    int
+	visiting map[uintptr]bool
+}
+
+// indent performs indentation according to the depth level and cs.Indent
+// option.
+func (s *schemaState) indent() {
+	s.w.Write(bytes.Repeat([]byte(s.cs.Indent), s.depth))
+}
+
+// hasMethods returns whether values of the passed type implement the error or
+// Stringer interfaces, either directly or via a pointer receiver, unless
+// method invocation is disabled.  Such values are displayed by their methods
+// so their internals are not part of the schema.
+func (s *schemaState) hasMethods(t reflect.Type) bool {
+	if s.cs.DisableMethods || t.Kind() == reflect.Interface {
+		return false
+	}
+	pt := reflect.PtrTo(t)
+	return t.Implements(errorType) || t.Implements(stringerType) ||
+		pt.Implements(errorType) || pt.Implements(stringerType)
+}
+
+// open starts the block of a struct, array, slice, or map, returning false
+// after displaying a max depth marker instead when the block would exceed the
+// MaxDepth option.
+func (s *schemaState) open(delim []byte) bool {
+	s.w.Write(spaceBytes)
+	if s.cs.MaxDepth != 0 && s.depth+1 > s.cs.MaxDepth {
+		s.w.Write(maxDepthBytes)
+		return false
+	}
+	s.w.Write(delim)
+	s.w.Write(newlineBytes)
+	s.depth++
+	return true
+}
+
+// close ends the block started by open.
+func (s *schemaState) close(delim []byte) {
+	s.depth--
+	s.indent()
+	s.w.Write(delim)
+}
+
+// printLen outputs the passed length as a (len N) annotation.
+func (s *schemaState) printLen(n int) {
+	s.w.Write(schemaLenBytes)
+	printInt(s.w, int64(n), 10)
+	s.w.Write(closeParenBytes)
+}
+
+// schema outputs the shape of the passed value, which is of the passed static
+// type, with the value itself replaced by its type.
+func (s *schemaState) schema(t reflect.Type, v reflect.Value) {
+	// Interfaces are described by the value they hold, if any.
+	if t.Kind() == reflect.Interface {
+		if !v.IsValid() || v.IsNil() {
+			s.w.Write([]byte(typeName(s.cs, t)))
+			s.w.Write(spaceBytes)
+			s.w.Write(nilAngleBytes)
+			return
+		}
+		v = v.Elem()
+		t = v.Type()
+	}
+
+	s.w.Write([]byte(typeName(s.cs, t)))
+	if s.hasMethods(t) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if !v.IsValid() || v.IsNil() {
+			s.w.Write(spaceBytes)
+			s.w.Write(nilAngleBytes)
+			return
+		}
+		addr := v.Pointer()
+		if s.visiting[addr] {
+			s.w.Write(spaceBytes)
+			s.w.Write(circularBytes)
+			return
+		}
+		s.visiting[addr] = true
+		s.w.Write(spaceBytes)
+		s.w.Write(pointerChainBytes)
+		s.w.Write(spaceBytes)
+		s.schema(t.Elem(), v.Elem())
+		delete(s.visiting, addr)
+
+	case reflect.Struct:
+		if !s.open(openBraceBytes) {
+			return
+		}
+		fields := visibleFields(s.cs, v, true, nil)
+		for i, fieldIndex := range fields {
+			s.indent()
+			field := t.Field(fieldIndex)
+			s.w.Write([]byte(fieldName(s.cs, field)))
+			s.w.Write(colonSpaceBytes)
+			s.schema(field.Type, v.Field(fieldIndex))
+			if i < len(fields)-1 {
+				s.w.Write(commaBytes)
+			}
+			s.w.Write(newlineBytes)
+		}
+		s.close(closeBraceBytes)
+
+	case reflect.Array, reflect.Slice:
+		s.printLen(v.Len())
+		if v.Len() == 0 || !s.open(openBracketBytes) {
+			return
+		}
+		s.indent()
+		s.schema(t.Elem(), v.Index(0))
+		s.w.Write(newlineBytes)
+		s.close(closeBracketBytes)
+
+	case reflect.Map:
+		s.printLen(v.Len())
+		if v.Len() == 0 || !s.open(openBraceBytes) {
+			return
+		}
+		keys := v.MapKeys()
+		sortMapKeys(keys, s.cs)
+		s.indent()
+		s.schema(t.Key(), keys[0])
+		s.w.Write(colonSpaceBytes)
+		s.schema(t.Elem(), v.MapIndex(keys[0]))
+		s.w.Write(newlineBytes)
+		s.close(closeBraceBytes)
+	}
+}
+
+// fdumpSchema is a helper function to consolidate the logic from the various
+// public methods which take varying writers and config states.
+func fdumpSchema(cs *ConfigState, w io.Writer, v interface{}) {
+	out := wrapWriter(cs, w)
+	dw := newDumpWriter(cs, out)
+	s := schemaState{cs: cs, w: dw, visiting: make(map[uintptr]bool)}
+	rv := reflect.ValueOf(&v).Elem()
+	s.schema(rv.Type(), rv)
+	if !cs.NoTrailingNewline {
+		dw.Write(newlineBytes)
+	}
+	dw.finish()
+	autoFlush(cs, dw.err, out, w)
+}
+
+// FdumpSchema outputs the shape of the passed value to io.Writer w.  See
+// SdumpSchema for details.
+func (c *ConfigState) FdumpSchema(w io.Writer, v interface{}) {
+	fdumpSchema(c, w, v)
+}
+
+// SdumpSchema returns a string with the shape of the passed value.  See the
+// package level SdumpSchema for details.
+func (c *ConfigState) SdumpSchema(v interface{}) string {
+	var buf bytes.Buffer
+	fdumpSchema(c, &buf, v)
+	return buf.String()
+}
+
+// DumpSchema displays the shape of the passed value to standard out.  See
+// SdumpSchema for details.
+func (c *ConfigState) DumpSchema(v interface{}) {
+	fdumpSchema(c, os.Stdout, v)
+}
+
+// FdumpSchema outputs the shape of the passed value to io.Writer w.  See
+// SdumpSchema for details.
+func FdumpSchema(w io.Writer, v interface{}) {
+	fdumpSchema(&Config, w, v)
+}
+
+// DumpSchema displays the shape of the passed value to standard out.  See
+// SdumpSchema for details.
+func DumpSchema(v interface{}) {
+	fdumpSchema(&Config, os.Stdout, v)
+}
+
+/*
+SdumpSchema returns a string with the shape of the passed value, which is an
+outline of its types with the values themselves left out, rather than a full
+dump.  This is convenient for exploring the structure of unknown payloads, such
+as decoded API responses.  For example:
+
+	map[string]interface {} (len 2) {
+	 string: []interface {} (len 1000) [
+	  map[string]interface {} (len 3) {
+	   string: float64
+	  }
+	 ]
+	}
+
+Scalars are displayed as their types, structs list the types of their fields,
+and pointers are followed, such as *main.Item -> main.Item {.  Arrays, slices,
+and maps are displayed with their length and the shape of one sample element,
+which is the first element or the entry with the first key in the order of the
+SortKeys and MapKeyOrder options.  Since only one element is sampled, elements
+of interface types holding differing types are not all represented.  Interfaces
+are displayed as the type of the value they hold, and types which implement the
+error or Stringer interfaces are displayed as their type alone.  The MaxDepth
+option limits the levels of nesting which are displayed.
+*/
+func SdumpSchema(v interface{}) string {
+	var buf bytes.Buffer
+	fdumpSchema(&Config, &buf, v)
+	return buf.String()
+}
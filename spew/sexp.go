@@ -0,0 +1,241 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// sexpState contains information about the state of an s-expression
+// operation.
+type sexpState struct {
+	cs       *ConfigState
+	w        io.Writer
+	visiting map[interface{}]bool
+}
+
+// isSexpSymbol returns whether the passed string can be output as a symbol
+// without quoting, which is the case when it is not empty and contains no
+// whitespace, parentheses, quotes, or other characters with special meaning.
+func isSexpSymbol(s string) bool {
+	return s != "" && !strings.ContainsAny(s, " \t\r\n()\"';`#|\\")
+}
+
+// symbol outputs the passed string as a symbol when possible or as a quoted
+// string otherwise.
+func (s *sexpState) symbol(str string) {
+	if !isSexpSymbol(str) {
+		str = strconv.Quote(str)
+	}
+	io.WriteString(s.w, str)
+}
+
+// enter marks the passed reference, which is either a pointer address or a map
+// or slice identity from containerToken, as being displayed, returning false
+// when it is already being displayed by an ancestor which indicates a cycle.
+func (s *sexpState) enter(ref interface{}) bool {
+	if s.visiting[ref] {
+		io.WriteString(s.w, "#cyclic")
+		return false
+	}
+	s.visiting[ref] = true
+	return true
+}
+
+// sexp outputs the passed value as an s-expression.
+func (s *sexpState) sexp(v reflect.Value) {
+	if !v.IsValid() {
+		io.WriteString(s.w, "nil")
+		return
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface, reflect.Map, reflect.Slice,
+		reflect.Func, reflect.Chan:
+		if v.IsNil() {
+			io.WriteString(s.w, "nil")
+			return
+		}
+	}
+
+	// Display Stringer and error output as a symbol when enabled.
+	if !s.cs.DisableMethods && v.Kind() != reflect.Interface {
+		var buf bytes.Buffer
+		if handleMethods(s.cs, &buf, v, nil) {
+			s.symbol(buf.String())
+			return
+		}
+	}
+
+	// Maps and slices can contain themselves without any pointer in between.
+	if token, ok := containerToken(v); ok {
+		if !s.enter(token) {
+			return
+		}
+		defer delete(s.visiting, token)
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		s.sexp(v.Elem())
+
+	case reflect.Ptr:
+		if !s.enter(v.Pointer()) {
+			return
+		}
+		s.sexp(v.Elem())
+		delete(s.visiting, v.Pointer())
+
+	case reflect.Struct:
+		vt := v.Type()
+		name := "struct"
+		if vt.Name() != "" || s.cs.TypeNameFunc != nil {
+			name = structName(s.cs, vt)
+		}
+		io.WriteString(s.w, "(")
+		s.symbol(name)
+		for i := 0; i < v.NumField(); i++ {
+			if isHiddenField(s.cs, vt, i) {
+				continue
+			}
+			io.WriteString(s.w, " (")
+			s.symbol(fieldName(s.cs, vt.Field(i)))
+			io.WriteString(s.w, " ")
+			s.sexp(v.Field(i))
+			io.WriteString(s.w, ")")
+		}
+		io.WriteString(s.w, ")")
+
+	case reflect.Array, reflect.Slice:
+		io.WriteString(s.w, "(")
+		for i := 0; i < v.Len(); i++ {
+			if i > 0 {
+				io.WriteString(s.w, " ")
+			}
+			s.sexp(v.Index(i))
+		}
+		io.WriteString(s.w, ")")
+
+	case reflect.Map:
+		keys := v.MapKeys()
+		sortMapKeys(keys, s.cs)
+		io.WriteString(s.w, "(")
+		for i, key := range keys {
+			if i > 0 {
+				io.WriteString(s.w, " ")
+			}
+			io.WriteString(s.w, "(")
+			s.sexp(key)
+			io.WriteString(s.w, " . ")
+			s.sexp(v.MapIndex(key))
+			io.WriteString(s.w, ")")
+		}
+		io.WriteString(s.w, ")")
+
+	case reflect.String:
+		io.WriteString(s.w, strconv.Quote(v.String()))
+
+	case reflect.Bool:
+		io.WriteString(s.w, strconv.FormatBool(v.Bool()))
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32,
+		reflect.Int64:
+		io.WriteString(s.w, strconv.FormatInt(v.Int(), 10))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32,
+		reflect.Uint64:
+		io.WriteString(s.w, strconv.FormatUint(v.Uint(), 10))
+
+	case reflect.Float32, reflect.Float64:
+		io.WriteString(s.w, strconv.FormatFloat(v.Float(), 'g', -1,
+			v.Type().Bits()))
+
+	case reflect.Complex64, reflect.Complex128:
+		c := v.Complex()
+		bits := v.Type().Bits() / 2
+		io.WriteString(s.w, "(complex ")
+		io.WriteString(s.w, strconv.FormatFloat(real(c), 'g', -1, bits))
+		io.WriteString(s.w, " ")
+		io.WriteString(s.w, strconv.FormatFloat(imag(c), 'g', -1, bits))
+		io.WriteString(s.w, ")")
+
+	case reflect.Uintptr:
+		printHexPtr(s.w, uintptr(v.Uint()))
+
+	case reflect.UnsafePointer, reflect.Chan, reflect.Func:
+		printHexPtr(s.w, v.Pointer())
+	}
+}
+
+// fdumpSexp is a helper function to consolidate the logic from the various
+// public methods which take varying writers and config states.
+func fdumpSexp(cs *ConfigState, w io.Writer, v interface{}) {
+	out := wrapWriter(cs, w)
+	dw := newDumpWriter(cs, out)
+	s := sexpState{cs: cs, w: dw, visiting: make(map[interface{}]bool)}
+	s.sexp(reflect.ValueOf(v))
+	if !cs.NoTrailingNewline {
+		dw.Write(newlineBytes)
+	}
+	dw.finish()
+	autoFlush(cs, dw.err, out, w)
+}
+
+// FdumpSexp outputs the passed value to io.Writer w as an s-expression.  See
+// SdumpSexp for details.
+func (c *ConfigState) FdumpSexp(w io.Writer, v interface{}) {
+	fdumpSexp(c, w, v)
+}
+
+// SdumpSexp returns a string with the passed value formatted as an
+// s-expression.  See the package level SdumpSexp for details.
+func (c *ConfigState) SdumpSexp(v interface{}) string {
+	var buf bytes.Buffer
+	fdumpSexp(c, &buf, v)
+	return buf.String()
+}
+
+// FdumpSexp outputs the passed value to io.Writer w as an s-expression.  See
+// SdumpSexp for details.
+func FdumpSexp(w io.Writer, v interface{}) {
+	fdumpSexp(&Config, w, v)
+}
+
+/*
+SdumpSexp returns a string with the passed value formatted as an s-expression
+on a single line, which is a parseable alternative to Dump for tools which
+consume s-expressions.  For example:
+
+	(Foo (flag flagTwo) (data nil) (tags ("a" "b")) (limits (("conn" . 5))))
+
+Structs are lists headed by the name of their type followed by a list for each
+field, arrays and slices are plain lists, and maps are association lists of
+dotted pairs.  Strings are quoted, nil values are displayed as nil, Stringer and
+error output is displayed as a symbol, or quoted when it contains characters
+which aren't allowed in symbols, and circular references are displayed as
+#cyclic.  Map keys are ordered according to the SortKeys and MapKeyOrder
+options so the output is deterministic.
+*/
+func SdumpSexp(v interface{}) string {
+	var buf bytes.Buffer
+	fdumpSexp(&Config, &buf, v)
+	return buf.String()
+}
@@ -0,0 +1,258 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// defaultSideBySideWidth is the width of each column of a side by side dump
+// when the SideBySideWidth option is not set.
+const defaultSideBySideWidth = 40
+
+// Markers displayed between the columns of a side by side dump which indicate
+// whether the lines are the same, differ, or only exist on one side.
+const (
+	sideSameMarker  = "   "
+	sideDiffMarker  = " | "
+	sideLeftMarker  = " < "
+	sideRightMarker = " > "
+)
+
+// dumpLines returns the lines of the dump of the passed value without line
+// terminators.
+func dumpLines(cs *ConfigState, v interface{}) []string {
+	var buf bytes.Buffer
+	fdump(cs, &buf, v)
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
+
+// lcsLengths returns the lengths of the longest common subsequences of a and
+// each prefix of b, or of a and each suffix of b when reverse is set, indexed
+// by the length of the prefix or suffix.  Only two rows are kept so the space
+// used is linear in the length of b.
+func lcsLengths(a, b []string, reverse bool) []int {
+	prev, cur := make([]int, len(b)+1), make([]int, len(b)+1)
+	for i := range a {
+		ai := a[i]
+		if reverse {
+			ai = a[len(a)-1-i]
+		}
+		for j := 1; j <= len(b); j++ {
+			bj := b[j-1]
+			if reverse {
+				bj = b[len(b)-j]
+			}
+			switch {
+			case ai == bj:
+				cur[j] = prev[j-1] + 1
+			case prev[j] >= cur[j-1]:
+				cur[j] = prev[j]
+			default:
+				cur[j] = cur[j-1]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// lcsMatches appends the index pairs of the lines of a longest common
+// subsequence of a and b, offset by ai and bi, to matches.  It uses
+// Hirschberg's algorithm, which splits a in half and finds where the
+// subsequence crosses the split from the lengths computed in both directions,
+// so the space used stays linear rather than proportional to the product of
+// the lengths.
+func lcsMatches(a, b []string, ai, bi int, matches [][2]int) [][2]int {
+	if len(a) == 0 || len(b) == 0 {
+		return matches
+	}
+	if len(a) == 1 {
+		for j := range b {
+			if a[0] == b[j] {
+				return append(matches, [2]int{ai, bi + j})
+			}
+		}
+		return matches
+	}
+
+	mid := len(a) / 2
+	head := lcsLengths(a[:mid], b, false)
+	tail := lcsLengths(a[mid:], b, true)
+	split, best := 0, -1
+	for k := 0; k <= len(b); k++ {
+		if n := head[k] + tail[len(b)-k]; n > best {
+			split, best = k, n
+		}
+	}
+	matches = lcsMatches(a[:mid], b[:split], ai, bi, matches)
+	return lcsMatches(a[mid:], b[split:], ai+mid, bi+split, matches)
+}
+
+// alignLines pairs up the passed lines by their longest common subsequence.
+// Lines which are the same on both sides are paired with each other and the
+// other lines between them are paired in order, with any remaining lines
+// paired with -1 to indicate they only exist on one side.
+func alignLines(a, b []string) [][2]int {
+	matches := lcsMatches(a, b, 0, 0, nil)
+
+	var pairs [][2]int
+	var left, right []int
+	flush := func() {
+		for k := 0; k < len(left) || k < len(right); k++ {
+			pair := [2]int{-1, -1}
+			if k < len(left) {
+				pair[0] = left[k]
+			}
+			if k < len(right) {
+				pair[1] = right[k]
+			}
+			pairs = append(pairs, pair)
+		}
+		left, right = left[:0], right[:0]
+	}
+	i, j := 0, 0
+	for _, m := range matches {
+		for ; i < m[0]; i++ {
+			left = append(left, i)
+		}
+		for ; j < m[1]; j++ {
+			right = append(right, j)
+		}
+		flush()
+		pairs = append(pairs, m)
+		i, j = m[0]+1, m[1]+1
+	}
+	for ; i < len(a); i++ {
+		left = append(left, i)
+	}
+	for ; j < len(b); j++ {
+		right = append(right, j)
+	}
+	flush()
+	return pairs
+}
+
+// sideColumn returns the passed line padded or cut off to the passed width.
+// Lines which are cut off end with an ellipsis.
+func sideColumn(line string, width int) string {
+	n := utf8.RuneCountInString(line)
+	if n <= width {
+		return line + strings.Repeat(" ", width-n)
+	}
+	if width <= 3 {
+		return strings.Repeat(".", width)
+	}
+	cut, count := 0, 0
+	for i := range line {
+		if count == width-3 {
+			cut = i
+			break
+		}
+		count++
+	}
+	return line[:cut] + "..."
+}
+
+// fdumpSideBySide is a helper function to consolidate the logic from the
+// various public methods which take varying writers and config states.
+func fdumpSideBySide(cs *ConfigState, w io.Writer, a, b interface{}) {
+	scs := *cs
+	scs.NoTrailingNewline = false
+	scs.IncludeCaller = false
+	scs.WriterWrapper = nil
+	scs.AutoFlush = false
+	left, right := dumpLines(&scs, a), dumpLines(&scs, b)
+
+	width := cs.SideBySideWidth
+	if width <= 0 {
+		width = defaultSideBySideWidth
+	}
+	var buf bytes.Buffer
+	for _, pair := range alignLines(left, right) {
+		var l, r, marker string
+		switch {
+		case pair[0] < 0:
+			r, marker = right[pair[1]], sideRightMarker
+		case pair[1] < 0:
+			l, marker = left[pair[0]], sideLeftMarker
+		default:
+			l, r = left[pair[0]], right[pair[1]]
+			marker = sideSameMarker
+			if l != r {
+				marker = sideDiffMarker
+			}
+		}
+		line := sideColumn(l, width) + marker + sideColumn(r, width)
+		buf.WriteString(strings.TrimRight(line, " "))
+		buf.WriteByte('\n')
+	}
+
+	out := wrapWriter(cs, w)
+	_, err := out.Write(buf.Bytes())
+	autoFlush(cs, err, out, w)
+}
+
+// FdumpSideBySide displays the dumps of the passed values to io.Writer w in
+// two columns.  See SdumpSideBySide for details.
+func (c *ConfigState) FdumpSideBySide(w io.Writer, a, b interface{}) {
+	fdumpSideBySide(c, w, a, b)
+}
+
+// SdumpSideBySide returns a string with the dumps of the passed values in two
+// columns.  See the package level SdumpSideBySide for details.
+func (c *ConfigState) SdumpSideBySide(a, b interface{}) string {
+	var buf bytes.Buffer
+	fdumpSideBySide(c, &buf, a, b)
+	return buf.String()
+}
+
+// FdumpSideBySide displays the dumps of the passed values to io.Writer w in
+// two columns.  See SdumpSideBySide for details.
+func FdumpSideBySide(w io.Writer, a, b interface{}) {
+	fdumpSideBySide(&Config, w, a, b)
+}
+
+/*
+SdumpSideBySide returns a string with the dumps of the passed values formatted
+exactly the same as Dump in two columns, which is convenient for reviewing
+test failures:
+
+	(main.Foo) {                      (main.Foo) {
+	 Name: (string) (len=3) "bar",  |  Name: (string) (len=3) "baz",
+	 Tags: ([]string) <nil>,        |  Tags: ([]string) (len=1 cap...
+	                                >   (string) (len=1) "a"
+	                                >  },
+	 N: (int) 1                        N: (int) 1
+	}                                 }
+
+The lines of both dumps are aligned by their longest common subsequence so
+lines which are the same on both sides are displayed next to each other, while
+the lines between them are padded with blank lines when one side has more of
+them.  Lines which differ are marked with |, and lines which only exist on the
+left or right side are marked with < or > respectively.  The width of each
+column is set by the SideBySideWidth option and longer lines are cut off with
+an ellipsis.
+*/
+func SdumpSideBySide(a, b interface{}) string {
+	var buf bytes.Buffer
+	fdumpSideBySide(&Config, &buf, a, b)
+	return buf.String()
+}
@@ -0,0 +1,366 @@
+/*
+ * Copyright (c) 2013 Dave Collins <dave@davec.name>
+ *
+ * Permission to use, copy, modify, and distribute this software for any
+ * purpose with or without fee is hereby granted, provided that the above
+ * copyright notice and this permission notice appear in all copies.
+ *
+ * THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+ * WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+ * MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+ * ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+ * WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+ * ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+ * OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+ */
+
+package spew
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+)
+
+// copyKey identifies a reference which has already been copied so shared and
+// circular references are preserved in the copy.  The length distinguishes
+// slices of different lengths sharing the same backing array.
+type copyKey struct {
+	addr uintptr
+	vt   reflect.Type
+	len  int
+}
+
+// setValue sets dst to src while bypassing the restrictions on unexported
+// fields when the unsafe package is available.  The value is left unset when
+// it can't be bypassed.
+func setValue(dst, src reflect.Value) {
+	if !dst.CanSet() {
+		if UnsafeDisabled {
+			return
+		}
+		dst = unsafeReflectValue(dst)
+	}
+	if !src.CanInterface() {
+		if UnsafeDisabled {
+			return
+		}
+		src = unsafeReflectValue(src)
+	}
+	dst.Set(src)
+}
+
+// deepCopy returns a copy of the passed value which doesn't share any memory
+// reachable through pointers, slices, maps, and interfaces with the original.
+// Channels and functions are copied by reference.
+func deepCopy(v reflect.Value, copies map[copyKey]reflect.Value) reflect.Value {
+	if !v.IsValid() {
+		return v
+	}
+	vt := v.Type()
+	c := reflect.New(vt).Elem()
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return c
+		}
+		key := copyKey{addr: v.Pointer(), vt: vt}
+		if v.Kind() == reflect.Slice {
+			key.len = v.Len()
+		}
+		if dup, ok := copies[key]; ok {
+			return dup
+		}
+
+		switch v.Kind() {
+		case reflect.Ptr:
+			c = reflect.New(vt.Elem())
+			copies[key] = c
+			setValue(c.Elem(), deepCopy(v.Elem(), copies))
+
+		case reflect.Map:
+			c = reflect.MakeMapWithSize(vt, v.Len())
+			copies[key] = c
+			for _, mk := range v.MapKeys() {
+				val := deepCopy(v.MapIndex(mk), copies)
+				if !mk.CanInterface() || !val.CanInterface() {
+					if UnsafeDisabled {
+						continue
+					}
+					mk = unsafeReflectValue(mk)
+					val = unsafeReflectValue(val)
+				}
+				c.SetMapIndex(mk, val)
+			}
+
+		case reflect.Slice:
+			c = reflect.MakeSlice(vt, v.Len(), v.Len())
+			copies[key] = c
+			for i := 0; i < v.Len(); i++ {
+				setValue(c.Index(i), deepCopy(v.Index(i), copies))
+			}
+		}
+
+	case reflect.Interface:
+		if !v.IsNil() {
+			setValue(c, deepCopy(v.Elem(), copies))
+		}
+
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			setValue(c.Index(i), deepCopy(v.Index(i), copies))
+		}
+
+	case reflect.Struct:
+		// Start from a shallow copy so fields which can't be deep copied
+		// without the unsafe package still hold their values.
+		setValue(c, v)
+		for i := 0; i < v.NumField(); i++ {
+			setValue(c.Field(i), deepCopy(v.Field(i), copies))
+		}
+
+	default:
+		setValue(c, v)
+	}
+	return c
+}
+
+// SnapshotState houses a deep copy of a value taken by Snapshot along with the
+// configuration used to display changes to it.
+type SnapshotState struct {
+	cs    *ConfigState
+	value reflect.Value
+}
+
+// changeState contains information about the state of a changes operation.
+type changeState struct {
+	cs      *ConfigState
+	w       io.Writer
+	visited map[visitKey]bool
+	changes int
+}
+
+// text returns the single line representation of the passed value which is
+// the same as the %#v verb of the custom formatter.
+func (c *changeState) text(v reflect.Value) string {
+	if !v.IsValid() {
+		return "<missing>"
+	}
+	if !v.CanInterface() {
+		if UnsafeDisabled {
+			return fmt.Sprint(v)
+		}
+		v = unsafeReflectValue(v)
+	}
+	return c.cs.Sprintf("%#v", v.Interface())
+}
+
+// report outputs a single change from the old to the new value at the passed
+// path.
+func (c *changeState) report(path string, old, new reflect.Value) {
+	c.changes++
+	if path != "" {
+		fmt.Fprintf(c.w, "%s: ", path)
+	}
+	fmt.Fprintf(c.w, "%s => %s\n", c.text(old), c.text(new))
+}
+
+// visit marks the passed pair of references as compared, returning false when
+// they were already compared which avoids following circular data structures
+// forever.
+func (c *changeState) visit(old, new reflect.Value) bool {
+	key := visitKey{old.Pointer(), new.Pointer(), old.Type()}
+	if c.visited[key] {
+		return false
+	}
+	c.visited[key] = true
+	return true
+}
+
+// isLeaf returns whether the passed struct, array, slice, or map is displayed
+// as a whole rather than by its elements, such as times formatted according to
+// the TimeLayout option and values with Stringer or error output.
+func (c *changeState) isLeaf(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Struct, reflect.Array, reflect.Slice, reflect.Map:
+	default:
+		return false
+	}
+	var buf bytes.Buffer
+	if handleTime(c.cs, &buf, v) || handleImage(c.cs, &buf, v) ||
+		handleNetAddr(c.cs, &buf, v) {
+
+		return true
+	}
+	return !c.cs.DisableMethods && !isExpandedProto(c.cs, v.Type()) &&
+		handleMethods(c.cs, &buf, v, nil)
+}
+
+// diff outputs the changes between the old and new values at the passed path.
+// Values which changed shape, such as a slice with a different length or an
+// interface holding a different type, are reported as a whole, as are values
+// which are displayed as a whole.
+func (c *changeState) diff(path string, old, new reflect.Value) {
+	if !old.IsValid() || !new.IsValid() || old.Type() != new.Type() {
+		if old.IsValid() || new.IsValid() {
+			c.report(path, old, new)
+		}
+		return
+	}
+	if c.isLeaf(old) {
+		e := equalState{visited: make(map[visitKey]bool)}
+		if !e.equal(old, new) {
+			c.report(path, old, new)
+		}
+		return
+	}
+
+	switch old.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if old.IsNil() || new.IsNil() {
+			if old.IsNil() != new.IsNil() {
+				c.report(path, old, new)
+			}
+			return
+		}
+		if old.Kind() == reflect.Ptr && !c.visit(old, new) {
+			return
+		}
+		c.diff(path, old.Elem(), new.Elem())
+
+	case reflect.Struct:
+		vt := old.Type()
+		for i := 0; i < old.NumField(); i++ {
+			if isHiddenField(c.cs, vt, i) {
+				continue
+			}
+			name := fieldName(c.cs, vt.Field(i))
+			if path != "" {
+				name = path + "." + name
+			}
+			c.diff(name, old.Field(i), new.Field(i))
+		}
+
+	case reflect.Slice, reflect.Array:
+		if old.Kind() == reflect.Slice && (old.IsNil() != new.IsNil() ||
+			old.Len() != new.Len()) {
+
+			c.report(path, old, new)
+			return
+		}
+		if old.Kind() == reflect.Slice && !c.visit(old, new) {
+			return
+		}
+		for i := 0; i < old.Len(); i++ {
+			c.diff(path+"["+strconv.Itoa(i)+"]", old.Index(i), new.Index(i))
+		}
+
+	case reflect.Map:
+		if old.IsNil() != new.IsNil() {
+			c.report(path, old, new)
+			return
+		}
+		if !c.visit(old, new) {
+			return
+		}
+		keys := old.MapKeys()
+		for _, mk := range new.MapKeys() {
+			if !old.MapIndex(mk).IsValid() {
+				keys = append(keys, mk)
+			}
+		}
+		sortMapKeys(keys, c.cs)
+		for _, mk := range keys {
+			name := keyName(mk)
+			if mk.Kind() == reflect.String {
+				name = strconv.Quote(name)
+			}
+			c.diff(path+"["+name+"]", old.MapIndex(mk), new.MapIndex(mk))
+		}
+
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+		if old.Pointer() != new.Pointer() {
+			c.report(path, old, new)
+		}
+
+	default:
+		e := equalState{visited: make(map[visitKey]bool)}
+		if !e.equal(old, new) {
+			c.report(path, old, new)
+		}
+	}
+}
+
+// fdumpChanges is a helper function to consolidate the logic from the various
+// public methods which take varying writers.
+func (s *SnapshotState) fdumpChanges(w io.Writer, v interface{}) {
+	c := changeState{cs: s.cs, w: w, visited: make(map[visitKey]bool)}
+	c.diff("", s.value, reflect.ValueOf(v))
+	if c.changes == 0 {
+		fmt.Fprintln(w, "(no changes)")
+	}
+}
+
+// FdumpChanges displays the changes of the passed value since the snapshot was
+// taken to io.Writer w.  See DumpChanges for details.
+func (s *SnapshotState) FdumpChanges(w io.Writer, v interface{}) {
+	s.fdumpChanges(w, v)
+}
+
+// SdumpChanges returns a string with the changes of the passed value since the
+// snapshot was taken.  See DumpChanges for details.
+func (s *SnapshotState) SdumpChanges(v interface{}) string {
+	var buf bytes.Buffer
+	s.fdumpChanges(&buf, v)
+	return buf.String()
+}
+
+/*
+DumpChanges displays only the portions of the passed value which changed since
+the snapshot was taken to standard out.  Each change is displayed on its own
+line with the path to the changed value, using the same syntax as DumpPath,
+followed by the old and new values formatted the same as the %#v verb of the
+custom formatter.  For example:
+
+	Limits["conn"]: (int)5 => (int)10
+	Servers: ([]string)[a] => ([]string)[a b]
+
+Values which changed shape, such as slices with a different length, interfaces
+holding a different type, or an entirely different type of value, are displayed
+as a whole.  Map entries which were added or removed are displayed as
+<missing> on the side where they don't exist.  When nothing changed, the
+output is "(no changes)".
+*/
+func (s *SnapshotState) DumpChanges(v interface{}) {
+	s.fdumpChanges(os.Stdout, v)
+}
+
+// Snapshot returns a deep copy of the passed value which can later be compared
+// against the value to display what changed.  See the package level Snapshot
+// for details.
+func (c *ConfigState) Snapshot(v interface{}) *SnapshotState {
+	return &SnapshotState{cs: c, value: deepCopy(reflect.ValueOf(v),
+		make(map[copyKey]reflect.Value))}
+}
+
+/*
+Snapshot returns a deep copy of the passed value which can later be compared
+against the value with DumpChanges to display only what changed.  This is
+useful for observing the state transitions of long-running services without the
+noise of unchanged fields.  For example:
+
+	snap := spew.Snapshot(svc)
+	svc.HandleRequest(req)
+	snap.DumpChanges(svc)
+
+Everything reachable through pointers, slices, maps, and interfaces is copied,
+while channels and functions are copied by reference.  Unexported fields are
+only deep copied when the unsafe package is available.
+*/
+func Snapshot(v interface{}) *SnapshotState {
+	return Config.Snapshot(v)
+}
@@ -137,12 +137,15 @@ func Sprintln(a ...interface{}) string {
 	return fmt.Sprintln(convertArgs(a)...)
 }
 
+// AllowUnexported registers the struct types of the passed values as types
+// whose unexported fields are displayed by the global configuration even when
+// its ExportedOnly option is set.  See ConfigState.AllowUnexported for details.
+func AllowUnexported(types ...interface{}) {
+	Config.AllowUnexported(types...)
+}
+
 // convertArgs accepts a slice of arguments and returns a slice of the same
 // length with each argument converted to a default spew Formatter interface.
 func convertArgs(args []interface{}) (formatters []interface{}) {
-	formatters = make([]interface{}, len(args))
-	for index, arg := range args {
-		formatters[index] = NewFormatter(arg)
-	}
-	return formatters
+	return Config.convertArgs(args)
 }
@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/dvln/go-spew/spew"
 )
@@ -32,6 +34,7 @@ type spewFunc int
 
 const (
 	fCSFdump spewFunc = iota
+	fCSFdumpTo
 	fCSFprint
 	fCSFprintf
 	fCSFprintln
@@ -49,6 +52,7 @@ const (
 	fPrint
 	fPrintln
 	fSdump
+	fFdumpTo
 	fSprint
 	fSprintf
 	fSprintln
@@ -57,6 +61,7 @@ const (
 // Map of spewFunc values to names for pretty printing.
 var spewFuncStrings = map[spewFunc]string{
 	fCSFdump:        "ConfigState.Fdump",
+	fCSFdumpTo:      "ConfigState.FdumpTo",
 	fCSFprint:       "ConfigState.Fprint",
 	fCSFprintf:      "ConfigState.Fprintf",
 	fCSFprintln:     "ConfigState.Fprintln",
@@ -74,6 +79,7 @@ var spewFuncStrings = map[spewFunc]string{
 	fPrint:          "spew.Print",
 	fPrintln:        "spew.Println",
 	fSdump:          "spew.Sdump",
+	fFdumpTo:        "spew.FdumpTo",
 	fSprint:         "spew.Sprint",
 	fSprintf:        "spew.Sprintf",
 	fSprintln:       "spew.Sprintln",
@@ -130,6 +136,12 @@ func initSpewTests() {
 	scsNoPmethods := &spew.ConfigState{Indent: " ", DisablePointerMethods: true}
 	scsMaxDepth := &spew.ConfigState{Indent: " ", MaxDepth: 1}
 	scsContinue := &spew.ConfigState{Indent: " ", ContinueOnMethod: true}
+	scsDurations := &spew.ConfigState{Indent: " ", DisableMethods: true,
+		FormatDurations: true}
+	scsNoNewline := &spew.ConfigState{Indent: " ", NoTrailingNewline: true}
+	scsFuncNames := &spew.ConfigState{Indent: " ", ResolveFuncNames: true}
+	scsMaxElements := &spew.ConfigState{Indent: " ", MaxElements: 2,
+		SortKeys: true}
 
 	// Variables for tests on types which implement Stringer interface with and
 	// without a pointer receiver.
@@ -150,8 +162,15 @@ func initSpewTests() {
 	// Variable for tests on types which implement error interface.
 	te := customError(10)
 
+	// Variable for tests on function name resolution.
+	ft := func() {}
+
+	// Variable for tests on duration formatting.
+	td := 90 * time.Minute
+
 	spewTests = []spewTest{
 		{scsDefault, fCSFdump, "", int8(127), "(int8) 127\n"},
+		{scsDefault, fCSFdumpTo, "", []int8{127}, "([]int8) (len=1 cap=1) {\n (int8) 127\n}\n"},
 		{scsDefault, fCSFprint, "", int16(32767), "32767"},
 		{scsDefault, fCSFprintf, "%v", int32(2147483647), "2147483647"},
 		{scsDefault, fCSFprintln, "", int(2147483647), "2147483647\n"},
@@ -169,6 +188,7 @@ func initSpewTests() {
 		{scsDefault, fPrint, "", true, "true"},
 		{scsDefault, fPrintln, "", false, "false\n"},
 		{scsDefault, fSdump, "", complex(-10, -20), "(complex128) (-10-20i)\n"},
+		{scsDefault, fFdumpTo, "", [1]bool{true}, "([1]bool) (len=1 cap=1) {\n (bool) true\n}\n"},
 		{scsDefault, fSprint, "", complex(-1, -2), "(-1-2i)"},
 		{scsDefault, fSprintf, "%v", complex(float32(-3), -4), "(-3-4i)"},
 		{scsDefault, fSprintln, "", complex(float64(-5), -6), "(-5-6i)\n"},
@@ -180,18 +200,41 @@ func initSpewTests() {
 		{scsNoPmethods, fCSFprint, "", &ts, "<*>stringer test"},
 		{scsNoPmethods, fCSFprint, "", tps, "test"},
 		{scsNoPmethods, fCSFprint, "", &tps, "<*>stringer test"},
-		{scsMaxDepth, fCSFprint, "", dt, "{{<max>} [<max>] [<max>] map[<max>]}"},
+		{scsMaxDepth, fCSFprint, "", dt, "{{... (1 more)} [... (1 more)] [... (1 more)] map[... (1 more)]}"},
 		{scsMaxDepth, fCSFdump, "", dt, "(spew_test.depthTester) {\n" +
-			" ic: (spew_test.indirCir1) {\n  <max depth reached>\n },\n" +
-			" arr: ([1]string) (len=1 cap=1) {\n  <max depth reached>\n },\n" +
-			" slice: ([]string) (len=1 cap=1) {\n  <max depth reached>\n },\n" +
-			" m: (map[string]int) (len=1) {\n  <max depth reached>\n }\n}\n"},
+			" ic: (spew_test.indirCir1) {\n  ... (1 more)\n },\n" +
+			" arr: ([1]string) (len=1 cap=1) {\n  ... (1 more)\n },\n" +
+			" slice: ([]string) (len=1 cap=1) {\n  ... (1 more)\n },\n" +
+			" m: (map[string]int) (len=1) {\n  ... (1 more)\n }\n}\n"},
 		{scsContinue, fCSFprint, "", ts, "(stringer test) test"},
 		{scsContinue, fCSFdump, "", ts, "(spew_test.stringer) " +
 			"(len=4) (stringer test) \"test\"\n"},
 		{scsContinue, fCSFprint, "", te, "(error: 10) 10"},
 		{scsContinue, fCSFdump, "", te, "(spew_test.customError) " +
 			"(error: 10) 10\n"},
+		{scsDefault, fCSFdump, "", td, "(time.Duration) 1h30m0s\n"},
+		{scsNoMethods, fCSFdump, "", td, "(time.Duration) 5400000000000\n"},
+		{scsDurations, fCSFdump, "", td, "(time.Duration) 1h30m0s\n"},
+		{scsDurations, fCSFprint, "", td, "1h30m0s"},
+		{scsDurations, fCSFprint, "", &td, "<*>1h30m0s"},
+		{scsNoNewline, fCSFdump, "", int8(127), "(int8) 127"},
+		{scsNoNewline, fCSSdump, "", []int8{1}, "([]int8) (len=1 cap=1) {\n (int8) 1\n}"},
+		{scsNoNewline, fCSSdump, "", nil, "(interface {}) <nil>"},
+		{scsFuncNames, fCSFdump, "", TestSpew, "(func(*testing.T)) " +
+			"github.com/dvln/go-spew/spew_test.TestSpew\n"},
+		{scsFuncNames, fCSFprint, "", ft, "github.com/dvln/go-spew/spew_test.initSpewTests.func1"},
+		{scsFuncNames, fCSFprint, "", (func())(nil), "<nil>"},
+		{scsMaxElements, fCSFprint, "", []int{1, 2, 3, 4}, "[1 2 ... (2 more)]"},
+		{scsMaxElements, fCSFprint, "", [2]int{1, 2}, "[1 2]"},
+		{scsMaxElements, fCSFprint, "", map[int]int{1: 1, 2: 2, 3: 3},
+			"map[1:1 2:2 ... (1 more)]"},
+		{scsMaxElements, fCSFdump, "", []int{1, 2, 3}, "([]int) (len=3 cap=3) {\n" +
+			" (int) 1,\n (int) 2,\n ... (1 more)\n}\n"},
+		{scsMaxElements, fCSFdump, "", []byte{1, 2, 3}, "([]uint8) (len=3 cap=3) {\n" +
+			" 00000000  01 02 03                                          |...|\n}\n"},
+		{scsMaxElements, fCSFdump, "", map[string]int{"a": 1, "b": 2, "c": 3},
+			"(map[string]int) (len=3) {\n (string) (len=1) \"a\": (int) 1,\n" +
+				" (string) (len=1) \"b\": (int) 2,\n ... (1 more)\n}\n"},
 	}
 }
 
@@ -206,6 +249,12 @@ func TestSpew(t *testing.T) {
 		case fCSFdump:
 			test.cs.Fdump(buf, test.in)
 
+		case fCSFdumpTo:
+			var sb strings.Builder
+			sb.WriteString("prefix")
+			test.cs.FdumpTo(&sb, test.in)
+			buf.WriteString(strings.TrimPrefix(sb.String(), "prefix"))
+
 		case fCSFprint:
 			test.cs.Fprint(buf, test.in)
 
@@ -284,6 +333,11 @@ func TestSpew(t *testing.T) {
 			str := spew.Sdump(test.in)
 			buf.WriteString(str)
 
+		case fFdumpTo:
+			var sb strings.Builder
+			spew.FdumpTo(&sb, test.in)
+			buf.WriteString(sb.String())
+
 		case fSprint:
 			str := spew.Sprint(test.in)
 			buf.WriteString(str)
@@ -0,0 +1,74 @@
+// Copyright (c) 2015 Dave Collins <dave@davec.name>
+//
+// Permission to use, copy, modify, and distribute this software for any
+// purpose with or without fee is hereby granted, provided that the above
+// copyright notice and this permission notice appear in all copies.
+//
+// THE SOFTWARE IS PROVIDED "AS IS" AND THE AUTHOR DISCLAIMS ALL WARRANTIES
+// WITH REGARD TO THIS SOFTWARE INCLUDING ALL IMPLIED WARRANTIES OF
+// MERCHANTABILITY AND FITNESS. IN NO EVENT SHALL THE AUTHOR BE LIABLE FOR
+// ANY SPECIAL, DIRECT, INDIRECT, OR CONSEQUENTIAL DAMAGES OR ANY DAMAGES
+// WHATSOEVER RESULTING FROM LOSS OF USE, DATA OR PROFITS, WHETHER IN AN
+// ACTION OF CONTRACT, NEGLIGENCE OR OTHER TORTIOUS ACTION, ARISING OUT OF
+// OR IN CONNECTION WITH THE USE OR PERFORMANCE OF THIS SOFTWARE.
+
+// NOTE: Due to the following build constraints, this file will only be compiled
+// when the code is built with Go 1.24 or newer which introduced weak pointers.
+// +build go1.24
+
+package spew_test
+
+import (
+	"runtime"
+	"strings"
+	"testing"
+	"weak"
+
+	"github.com/dvln/go-spew/spew"
+)
+
+// weakEntry is used to test the ShowRuntimeState option with weak pointers.
+type weakEntry struct {
+	Name string
+}
+
+// TestDumpShowRuntimeState ensures the ShowRuntimeState option displays weak
+// pointers as the value they point to or <collected>.
+func TestDumpShowRuntimeState(t *testing.T) {
+	live := &weakEntry{"live"}
+	cs := spew.ConfigState{Indent: " ", ShowRuntimeState: true,
+		StableAddresses: true}
+
+	// The names of generic types include the full import path of their type
+	// arguments, so only the displayed values are checked.
+	want := ") (*spew_test.weakEntry)(0x1)({\n Name: (string) (len=4) " +
+		"\"live\"\n})\n"
+	if s := cs.Sdump(weak.Make(live)); !strings.HasSuffix(s, want) {
+		t.Errorf("ShowRuntimeState dump\n got: %q\nwant suffix: %q", s, want)
+	}
+	if s := cs.Sprintf("%v", weak.Make(live)); s != "<*>{live}" {
+		t.Errorf("ShowRuntimeState format got: %q", s)
+	}
+	var zero weak.Pointer[weakEntry]
+	if s := cs.Sdump(zero); !strings.Contains(s, "u: (unsafe.Pointer) <nil>") &&
+		!spew.UnsafeDisabled {
+
+		t.Errorf("ShowRuntimeState zero got: %q", s)
+	}
+	runtime.KeepAlive(live)
+
+	// Only check collected pointers when the garbage collector actually
+	// collected the value.
+	collected := weak.Make(&weakEntry{"gone"})
+	for i := 0; i < 5 && collected.Value() != nil; i++ {
+		runtime.GC()
+	}
+	if collected.Value() != nil {
+		t.Skip("value was not garbage collected")
+	}
+	want = ") <collected>\n"
+	if s := cs.Sdump(collected); !strings.HasSuffix(s, want) {
+		t.Errorf("ShowRuntimeState collected\n got: %q\nwant suffix: %q", s,
+			want)
+	}
+}